@@ -0,0 +1,112 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	// Import all Kubernetes client auth plugins (e.g. Azure, GCP, OIDC, etc.)
+	_ "k8s.io/client-go/plugin/pkg/client/auth"
+
+	"github.com/CrowdfoxGmbH/external-service-operator/pkg/apis"
+	"github.com/CrowdfoxGmbH/external-service-operator/pkg/plugin"
+
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/clientcmd"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const usage = `kubectl eso inspects and manages the addresses of ExternalServices.
+
+Usage:
+  kubectl eso status NAME            show every address with its state and last probe
+  kubectl eso probe NAME [IP...]     run the readiness probe once from this machine
+  kubectl eso drain NAME IP          drain an address
+  kubectl eso undrain NAME IP        undrain an address
+  kubectl eso why NAME IP            explain why an address is ready or not
+
+Flags:
+`
+
+func main() {
+	flags := flag.NewFlagSet("kubectl-eso", flag.ContinueOnError)
+	namespace := flags.String("namespace", "", "namespace of the ExternalService, defaults to the one of the current context")
+	flags.StringVar(namespace, "n", "", "shorthand for --namespace")
+	user := flags.String("user", os.Getenv("USER"), "who drains or undrains the address, recorded on the ExternalService")
+	flags.Usage = func() {
+		fmt.Fprint(os.Stderr, usage)
+		flags.PrintDefaults()
+	}
+
+	args, err := parseArgs(flags, os.Args[1:])
+	if err == flag.ErrHelp {
+		os.Exit(0)
+	} else if err != nil {
+		os.Exit(2)
+	}
+	if len(args) < 2 {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	loader := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(clientcmd.NewDefaultClientConfigLoadingRules(), &clientcmd.ConfigOverrides{})
+	if *namespace == "" {
+		if *namespace, _, err = loader.Namespace(); err != nil {
+			exit(err)
+		}
+	}
+	cfg, err := loader.ClientConfig()
+	if err != nil {
+		exit(err)
+	}
+	if err := apis.AddToScheme(scheme.Scheme); err != nil {
+		exit(err)
+	}
+	c, err := client.New(cfg, client.Options{Scheme: scheme.Scheme})
+	if err != nil {
+		exit(err)
+	}
+
+	p := plugin.NewPlugin(c, *namespace, os.Stdout)
+	command, name, ips := args[0], args[1], args[2:]
+	switch {
+	case command == "status" && len(ips) == 0:
+		err = p.Status(name)
+	case command == "probe":
+		err = p.Probe(name, ips)
+	case command == "drain" && len(ips) == 1:
+		err = p.Drain(name, ips[0], *user)
+	case command == "undrain" && len(ips) == 1:
+		err = p.Undrain(name, ips[0], *user)
+	case command == "why" && len(ips) == 1:
+		err = p.Why(name, ips[0])
+	default:
+		flags.Usage()
+		os.Exit(2)
+	}
+	if err != nil {
+		exit(err)
+	}
+}
+
+// parseArgs parses the flags wherever they are given and returns the other arguments, as
+// kubectl users are used to put flags after the arguments
+func parseArgs(flags *flag.FlagSet, args []string) ([]string, error) {
+	positional := []string{}
+	for {
+		if err := flags.Parse(args); err != nil {
+			return nil, err
+		}
+		args = flags.Args()
+		if len(args) == 0 {
+			return positional, nil
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
+func exit(err error) {
+	fmt.Fprintln(os.Stderr, "error:", err)
+	os.Exit(1)
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/CrowdfoxGmbH/external-service-operator/pkg/apis"
+	"github.com/CrowdfoxGmbH/external-service-operator/pkg/importer"
+
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/config"
+)
+
+// runImport prints an ExternalService for every selector-less Service of a namespace. With
+// --dry-run it prints what applying them would change instead. Returns the exit code.
+func runImport(args []string, out io.Writer) int {
+	flags := flag.NewFlagSet("import", flag.ContinueOnError)
+	namespace := flags.String("namespace", "default", "namespace to scan for selector-less Services")
+	dryRun := flags.Bool("dry-run", false, "print a diff against the existing ExternalServices instead of the manifests")
+	if err := flags.Parse(args); err == flag.ErrHelp {
+		return 0
+	} else if err != nil {
+		return 2
+	}
+
+	cfg, err := config.GetConfig()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	if err := apis.AddToScheme(scheme.Scheme); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	c, err := client.New(cfg, client.Options{Scheme: scheme.Scheme})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	i := importer.NewImporter(c)
+	externalServices, err := i.Import(*namespace)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	if *dryRun {
+		diff, err := i.Diff(externalServices)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		fmt.Fprint(out, diff)
+		return 0
+	}
+
+	for _, externalService := range externalServices {
+		manifest, err := importer.ToYAML(externalService)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		fmt.Fprint(out, "---\n"+manifest)
+	}
+	return 0
+}
@@ -0,0 +1,222 @@
+package v1alpha1
+
+import (
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	// DrainAnnotation takes a comma separated list of IPs which should be drained
+	DrainAnnotation = "eso.crowdfox.com/drain"
+	// DrainedByAnnotation names who drained the addresses of DrainAnnotation
+	DrainedByAnnotation = "eso.crowdfox.com/drained-by"
+)
+
+// GetAddresses returns all addresses of the spec, followed by the ones of its ranges. Plain
+// Ips are returned with the default priority. If an IP is listed more than once, the first
+// definition wins.
+func (s *ExternalServiceSpec) GetAddresses() []ExternalServiceAddress {
+	known := map[string]bool{}
+	addresses := appendAddresses(nil, known, s.Addresses, s.Ips)
+	return appendRanges(addresses, known, s.Ranges)
+}
+
+// GetAddresses returns the addresses which are served: the active addresses, followed by the
+// terminating ones.
+func (e *ExternalService) GetAddresses() []ExternalServiceAddress {
+	known := map[string]bool{}
+	addresses := appendAddresses(nil, known, e.GetActiveAddresses(), nil)
+	for _, terminating := range e.Status.TerminatingAddresses {
+		addresses = appendAddresses(addresses, known, []ExternalServiceAddress{terminating.ExternalServiceAddress}, nil)
+	}
+	return addresses
+}
+
+// GetActiveAddresses returns the wanted addresses, followed by the old addresses a rollout
+// still keeps. New addresses of an aborted rollout are left out.
+func (e *ExternalService) GetActiveAddresses() []ExternalServiceAddress {
+	if e.Status.Rollout == nil {
+		return e.GetWantedAddresses()
+	}
+
+	heldBack := e.Status.Rollout.heldBackIps()
+	known := map[string]bool{}
+	addresses := []ExternalServiceAddress{}
+	for _, address := range e.GetWantedAddresses() {
+		if !heldBack[address.IP] {
+			known[address.IP] = true
+			addresses = append(addresses, address)
+		}
+	}
+	return appendAddresses(addresses, known, e.Status.Rollout.OldAddresses, nil)
+}
+
+// GetWantedAddresses returns the addresses of the spec followed by the ones discovered by its
+// sources. If an IP is listed more than once, the first definition wins.
+func (e *ExternalService) GetWantedAddresses() []ExternalServiceAddress {
+	known := map[string]bool{}
+	addresses := appendAddresses(nil, known, e.Spec.Addresses, e.Spec.Ips)
+	addresses = appendRanges(addresses, known, e.Spec.Ranges)
+
+	for _, source := range e.Status.Sources {
+		addresses = appendAddresses(addresses, known, source.Addresses, nil)
+	}
+	return addresses
+}
+
+func appendAddresses(addresses []ExternalServiceAddress, known map[string]bool, definitions []ExternalServiceAddress, ips []string) []ExternalServiceAddress {
+	if addresses == nil {
+		addresses = []ExternalServiceAddress{}
+	}
+
+	for _, ip := range ips {
+		if !known[ip] {
+			known[ip] = true
+			addresses = append(addresses, ExternalServiceAddress{IP: ip})
+		}
+	}
+
+	for _, address := range definitions {
+		if !known[address.IP] {
+			known[address.IP] = true
+			addresses = append(addresses, address)
+		}
+	}
+
+	return addresses
+}
+
+// GetIps returns the IPs of all addresses, including the discovered ones
+func (e *ExternalService) GetIps() []string {
+	ips := []string{}
+	for _, address := range e.GetAddresses() {
+		ips = append(ips, address.IP)
+	}
+	return ips
+}
+
+// GetAddress returns the address with the given IP, including the discovered ones
+func (e *ExternalService) GetAddress(ip string) (ExternalServiceAddress, bool) {
+	for _, address := range e.GetAddresses() {
+		if address.IP == ip {
+			return address, true
+		}
+	}
+	return ExternalServiceAddress{}, false
+}
+
+// HideNotReadyAddresses removes the not ready addresses which should be hidden from the
+// subsets. Subsets left without any address are removed.
+func (e *ExternalService) HideNotReadyAddresses(subsets []corev1.EndpointSubset) []corev1.EndpointSubset {
+	hidden := map[string]bool{}
+	for _, address := range e.GetAddresses() {
+		hidden[address.IP] = address.HideNotReady
+	}
+
+	result := []corev1.EndpointSubset{}
+	for _, subset := range subsets {
+		notReady := []corev1.EndpointAddress{}
+		for _, address := range subset.NotReadyAddresses {
+			if !hidden[address.IP] {
+				notReady = append(notReady, address)
+			}
+		}
+		if len(subset.Addresses) == 0 && len(notReady) == 0 {
+			continue
+		}
+		subset.NotReadyAddresses = notReady
+		result = append(result, subset)
+	}
+	return result
+}
+
+// ToEndpointAddress returns the address as it is listed in the Endpoints
+func (a ExternalServiceAddress) ToEndpointAddress() corev1.EndpointAddress {
+	endpointAddress := corev1.EndpointAddress{
+		IP:       a.IP,
+		Hostname: a.Hostname,
+	}
+
+	if a.NodeName != "" {
+		nodeName := a.NodeName
+		endpointAddress.NodeName = &nodeName
+	}
+
+	return endpointAddress
+}
+
+// GetPort returns the port traffic for the address is sent to
+func (s *ExternalServiceSpec) GetPort(address ExternalServiceAddress) int32 {
+	if address.Port != 0 {
+		return address.Port
+	}
+	return s.Port
+}
+
+// IsExternalName reports whether the Service is an ExternalName Service, which needs no Endpoints
+func (s *ExternalServiceSpec) IsExternalName() bool {
+	return s.Service != nil && s.Service.Type == corev1.ServiceTypeExternalName
+}
+
+// IsDrained reports whether the address is drained, either in the spec or by the DrainAnnotation
+func (e *ExternalService) IsDrained(ip string) bool {
+	if address, found := e.GetAddress(ip); found && address.Drain {
+		return true
+	}
+
+	for _, drained := range strings.Split(e.Annotations[DrainAnnotation], ",") {
+		if strings.TrimSpace(drained) == ip {
+			return true
+		}
+	}
+	return false
+}
+
+// IsTerminating reports whether the address is removed, but kept until spec.drainSeconds passed
+func (e *ExternalService) IsTerminating(ip string) bool {
+	for _, terminating := range e.Status.TerminatingAddresses {
+		if terminating.IP == ip {
+			return true
+		}
+	}
+	return false
+}
+
+// GetDrainedIps returns the IPs of all drained addresses
+func (e *ExternalService) GetDrainedIps() []string {
+	ips := []string{}
+	for _, ip := range e.GetIps() {
+		if e.IsDrained(ip) {
+			ips = append(ips, ip)
+		}
+	}
+	return ips
+}
+
+// GetReadinessOverride returns the override of the IP which is active at the given time
+func (e *ExternalService) GetReadinessOverride(ip string, now time.Time) *ReadinessOverride {
+	for i, override := range e.Spec.ReadinessOverrides {
+		if override.IP == ip && !override.IsExpired(now) {
+			return &e.Spec.ReadinessOverrides[i]
+		}
+	}
+	return nil
+}
+
+// IsExpired reports whether the override is expired at the given time
+func (o *ReadinessOverride) IsExpired(now time.Time) bool {
+	return o.ExpiresAt != nil && !now.Before(o.ExpiresAt.Time)
+}
+
+// Name returns ForceNotReady or ForceReady depending on which one is set
+func (o *ReadinessOverride) Name() string {
+	if o.ForceNotReady {
+		return "ForceNotReady"
+	}
+	if o.ForceReady {
+		return "ForceReady"
+	}
+	return ""
+}
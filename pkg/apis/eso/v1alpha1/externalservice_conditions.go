@@ -0,0 +1,83 @@
+package v1alpha1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+type ExternalServiceConditionType string
+
+const (
+	// PriorityTierActive is true as long as one priority tier has healthy addresses
+	PriorityTierActive ExternalServiceConditionType = "PriorityTierActive"
+	// SourcesSynced is true as long as the last discovery of every address source succeeded
+	SourcesSynced ExternalServiceConditionType = "SourcesSynced"
+	// RangesValid is false if a range can not be expanded, e.g. because it is too large
+	RangesValid ExternalServiceConditionType = "RangesValid"
+	// TrafficSplitActive is true as long as the traffic is split between the address groups
+	TrafficSplitActive ExternalServiceConditionType = "TrafficSplitActive"
+	// RolloutProgressing is true while a rollout progresses or completed, it is false if the
+	// rollout was aborted
+	RolloutProgressing ExternalServiceConditionType = "RolloutProgressing"
+	// OwnershipConflict is true while an object named like the ExternalService is not owned by it
+	OwnershipConflict ExternalServiceConditionType = "OwnershipConflict"
+)
+
+// ExternalServiceCondition describes the state of an ExternalService at a certain point
+type ExternalServiceCondition struct {
+	Type               ExternalServiceConditionType `json:"type"`
+	Status             corev1.ConditionStatus       `json:"status"`
+	LastTransitionTime metav1.Time                  `json:"lastTransitionTime,omitempty"`
+	Reason             string                       `json:"reason,omitempty"`
+	Message            string                       `json:"message,omitempty"`
+}
+
+// GetCondition returns the condition with the given type or nil if it does not exist
+func (s *ExternalServiceStatus) GetCondition(conditionType ExternalServiceConditionType) *ExternalServiceCondition {
+	for i := range s.Conditions {
+		if s.Conditions[i].Type == conditionType {
+			return &s.Conditions[i]
+		}
+	}
+	return nil
+}
+
+// SetCondition adds or updates the condition. LastTransitionTime is only touched
+// when the status of the condition changes. Returns true if anything changed.
+func (s *ExternalServiceStatus) SetCondition(condition ExternalServiceCondition) bool {
+	existing := s.GetCondition(condition.Type)
+	if existing == nil {
+		if condition.LastTransitionTime.IsZero() {
+			condition.LastTransitionTime = metav1.Now()
+		}
+		s.Conditions = append(s.Conditions, condition)
+		return true
+	}
+
+	if existing.Status == condition.Status && existing.Reason == condition.Reason && existing.Message == condition.Message {
+		return false
+	}
+
+	if existing.Status != condition.Status {
+		existing.LastTransitionTime = metav1.Now()
+	}
+	existing.Status = condition.Status
+	existing.Reason = condition.Reason
+	existing.Message = condition.Message
+	return true
+}
+
+// RemoveCondition removes the condition with the given type. Returns true if it existed.
+func (s *ExternalServiceStatus) RemoveCondition(conditionType ExternalServiceConditionType) bool {
+	conditions := []ExternalServiceCondition{}
+	for _, condition := range s.Conditions {
+		if condition.Type != conditionType {
+			conditions = append(conditions, condition)
+		}
+	}
+	if len(conditions) == len(s.Conditions) {
+		return false
+	}
+	s.Conditions = conditions
+	return true
+}
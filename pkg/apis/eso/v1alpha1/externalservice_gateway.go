@@ -0,0 +1,84 @@
+package v1alpha1
+
+// GatewayRouteProtocol selects the kind of Gateway API route created for an ExternalService
+type GatewayRouteProtocol string
+
+const (
+	// GatewayProtocolHTTP creates an HTTPRoute
+	GatewayProtocolHTTP GatewayRouteProtocol = "HTTP"
+	// GatewayProtocolTLS creates a TLSRoute, which routes TLS passthrough traffic by SNI
+	GatewayProtocolTLS GatewayRouteProtocol = "TLS"
+	// GatewayProtocolTCP creates a TCPRoute
+	GatewayProtocolTCP GatewayRouteProtocol = "TCP"
+)
+
+// GatewayConfig configures the Gateway API route created for the ExternalService.
+// Every route sends its traffic to the Service created for the ExternalService.
+type GatewayConfig struct {
+	// Protocol is HTTP, TLS or TCP. Defaults to HTTP
+	Protocol GatewayRouteProtocol `json:"protocol,omitempty"`
+	// ParentRefs are the Gateways the route attaches to
+	ParentRefs []GatewayParentRef `json:"parentRefs"`
+	// Hostnames of HTTP and TLS routes. Defaults to the hosts of spec.hosts
+	Hostnames []string `json:"hostnames,omitempty"`
+	// Rules of an HTTP route. Defaults to one rule per path of spec.hosts
+	Rules []GatewayHTTPRouteRule `json:"rules,omitempty"`
+}
+
+// GatewayParentRef references a Gateway or one of its listeners
+type GatewayParentRef struct {
+	Name string `json:"name"`
+	// Namespace of the Gateway. Defaults to the namespace of the ExternalService
+	Namespace   string `json:"namespace,omitempty"`
+	SectionName string `json:"sectionName,omitempty"`
+	Port        int32  `json:"port,omitempty"`
+}
+
+// GatewayHTTPRouteRule matches requests and optionally modifies them before they are sent to the Service
+type GatewayHTTPRouteRule struct {
+	// Matches of the rule. A request has to fulfill one of them. Defaults to all requests
+	Matches []GatewayHTTPRouteMatch  `json:"matches,omitempty"`
+	Filters []GatewayHTTPRouteFilter `json:"filters,omitempty"`
+}
+
+// GatewayHTTPRouteMatch matches requests by path and headers
+type GatewayHTTPRouteMatch struct {
+	Path    *GatewayHTTPPathMatch    `json:"path,omitempty"`
+	Headers []GatewayHTTPHeaderMatch `json:"headers,omitempty"`
+}
+
+// GatewayHTTPPathMatch matches the path of a request
+type GatewayHTTPPathMatch struct {
+	// Type is PathPrefix, Exact or RegularExpression. Defaults to PathPrefix
+	Type  string `json:"type,omitempty"`
+	Value string `json:"value"`
+}
+
+// GatewayHTTPHeaderMatch matches a header of a request
+type GatewayHTTPHeaderMatch struct {
+	// Type is Exact or RegularExpression. Defaults to Exact
+	Type  string `json:"type,omitempty"`
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// GatewayHTTPRouteFilter modifies requests or responses
+type GatewayHTTPRouteFilter struct {
+	// Type is RequestHeaderModifier or ResponseHeaderModifier
+	Type                   string                   `json:"type"`
+	RequestHeaderModifier  *GatewayHTTPHeaderFilter `json:"requestHeaderModifier,omitempty"`
+	ResponseHeaderModifier *GatewayHTTPHeaderFilter `json:"responseHeaderModifier,omitempty"`
+}
+
+// GatewayHTTPHeaderFilter sets, adds and removes headers
+type GatewayHTTPHeaderFilter struct {
+	Set    []GatewayHTTPHeader `json:"set,omitempty"`
+	Add    []GatewayHTTPHeader `json:"add,omitempty"`
+	Remove []string            `json:"remove,omitempty"`
+}
+
+// GatewayHTTPHeader is a header name and value
+type GatewayHTTPHeader struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
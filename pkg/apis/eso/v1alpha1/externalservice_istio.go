@@ -0,0 +1,42 @@
+package v1alpha1
+
+// IstioResolution is the way Istio resolves the addresses of a ServiceEntry
+type IstioResolution string
+
+const (
+	// IstioResolutionStatic uses the ready addresses of the ExternalService
+	IstioResolutionStatic IstioResolution = "STATIC"
+	// IstioResolutionDNS resolves the ExternalName or the hosts of the ServiceEntry
+	IstioResolutionDNS IstioResolution = "DNS"
+)
+
+// IstioConfig configures the Istio resources created for the ExternalService, so sidecars
+// may send traffic to it
+type IstioConfig struct {
+	// Hosts of the ServiceEntry. Defaults to the hosts of spec.hosts or, if there are none,
+	// to the cluster domain name of the Service
+	Hosts []string `json:"hosts,omitempty"`
+	// Resolution is STATIC or DNS. Defaults to STATIC
+	Resolution IstioResolution `json:"resolution,omitempty"`
+	// Protocol of the port, e.g. HTTP, HTTPS, TLS or TCP. Defaults to TCP
+	Protocol string `json:"protocol,omitempty"`
+	// DestinationRule is created if set
+	DestinationRule *IstioDestinationRule `json:"destinationRule,omitempty"`
+}
+
+// IstioDestinationRule configures the traffic policy for the hosts of the ServiceEntry
+type IstioDestinationRule struct {
+	TLS *IstioTLSSettings `json:"tls,omitempty"`
+}
+
+// IstioTLSSettings configures TLS origination by the sidecars
+type IstioTLSSettings struct {
+	// Mode is DISABLE, SIMPLE, MUTUAL or ISTIO_MUTUAL
+	Mode string `json:"mode"`
+	// SNI sent to the addresses. Defaults to the first host of the ServiceEntry
+	SNI string `json:"sni,omitempty"`
+	// CredentialName is the Secret holding the client certificate of MUTUAL or the CA of SIMPLE
+	CredentialName     string   `json:"credentialName,omitempty"`
+	SubjectAltNames    []string `json:"subjectAltNames,omitempty"`
+	InsecureSkipVerify bool     `json:"insecureSkipVerify,omitempty"`
+}
@@ -0,0 +1,204 @@
+package v1alpha1
+
+import (
+	"fmt"
+	"math/big"
+	"net"
+	"strings"
+)
+
+// DefaultMaxRangeAddresses caps the number of addresses a range expands to unless it sets MaxAddresses
+const DefaultMaxRangeAddresses = 256
+
+// AddressRange expands to an address for every IP of a CIDR or of a range from one IP to
+// another. Either CIDR or From and To have to be set.
+type AddressRange struct {
+	// CIDR like 10.20.0.16/28. The network and broadcast addresses of IPv4 networks larger
+	// than /31 are left out
+	CIDR string `json:"cidr,omitempty"`
+	// From is the first IP of the range, e.g. 10.20.0.10
+	From string `json:"from,omitempty"`
+	// To is the last IP of the range, e.g. 10.20.0.40
+	To string `json:"to,omitempty"`
+	// Exclude lists IPs and CIDRs which are left out
+	Exclude []string `json:"exclude,omitempty"`
+	// MaxAddresses the range may have. A larger range is rejected. Defaults to 256
+	MaxAddresses int32 `json:"maxAddresses,omitempty"`
+	// Port overrides spec.port for the addresses of the range
+	Port int32 `json:"port,omitempty"`
+	// Priority tier of the addresses of the range
+	Priority int32 `json:"priority,omitempty"`
+	// HideNotReady keeps the addresses of the range out of NotReadyAddresses while they are not ready
+	HideNotReady bool `json:"hideNotReady,omitempty"`
+}
+
+// String returns the CIDR or the from-to notation of the range
+func (r *AddressRange) String() string {
+	if r.CIDR != "" {
+		return r.CIDR
+	}
+	return r.From + "-" + r.To
+}
+
+// Expand returns the IPs of the range in ascending order without the excluded ones
+func (r *AddressRange) Expand() ([]string, error) {
+	first, last, err := r.bounds()
+	if err != nil {
+		return nil, err
+	}
+
+	excluded, err := r.excludedNetworks()
+	if err != nil {
+		return nil, err
+	}
+
+	maxAddresses := int64(DefaultMaxRangeAddresses)
+	if r.MaxAddresses > 0 {
+		maxAddresses = int64(r.MaxAddresses)
+	}
+	size := new(big.Int).Sub(last, first)
+	size.Add(size, big.NewInt(1))
+	if size.Cmp(big.NewInt(maxAddresses)) > 0 {
+		return nil, fmt.Errorf("range %v has %v addresses, more than the maximum of %d", r.String(), size, maxAddresses)
+	}
+
+	length := net.IPv6len
+	if r.isIPv4() {
+		length = net.IPv4len
+	}
+
+	ips := []string{}
+	for current := new(big.Int).Set(first); current.Cmp(last) <= 0; current.Add(current, big.NewInt(1)) {
+		ip := intToIP(current, length)
+		if !containedIn(ip, excluded) {
+			ips = append(ips, ip.String())
+		}
+	}
+	return ips, nil
+}
+
+// bounds returns the first and the last IP of the range as numbers
+func (r *AddressRange) bounds() (*big.Int, *big.Int, error) {
+	if (r.CIDR != "") == (r.From != "" || r.To != "") {
+		return nil, nil, fmt.Errorf("range %v needs either a cidr or from and to", r.String())
+	}
+
+	if r.CIDR != "" {
+		_, network, err := net.ParseCIDR(r.CIDR)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid cidr %q", r.CIDR)
+		}
+
+		first := ipToInt(network.IP)
+		last := new(big.Int).Set(first)
+		ones, bits := network.Mask.Size()
+		hostBits := new(big.Int).Lsh(big.NewInt(1), uint(bits-ones))
+		last.Add(last, hostBits.Sub(hostBits, big.NewInt(1)))
+
+		if bits == 8*net.IPv4len && ones < 31 {
+			first.Add(first, big.NewInt(1))
+			last.Sub(last, big.NewInt(1))
+		}
+		return first, last, nil
+	}
+
+	from := net.ParseIP(r.From)
+	to := net.ParseIP(r.To)
+	if from == nil || to == nil || (from.To4() == nil) != (to.To4() == nil) {
+		return nil, nil, fmt.Errorf("invalid range %v", r.String())
+	}
+
+	first, last := ipToInt(from), ipToInt(to)
+	if first.Cmp(last) > 0 {
+		return nil, nil, fmt.Errorf("range %v ends before it starts", r.String())
+	}
+	return first, last, nil
+}
+
+func (r *AddressRange) isIPv4() bool {
+	if r.CIDR != "" {
+		ip, _, _ := net.ParseCIDR(r.CIDR)
+		return ip.To4() != nil
+	}
+	return net.ParseIP(r.From).To4() != nil
+}
+
+// excludedNetworks parses the exclusions, plain IPs become single address networks
+func (r *AddressRange) excludedNetworks() ([]*net.IPNet, error) {
+	networks := []*net.IPNet{}
+	for _, exclude := range r.Exclude {
+		exclude = strings.TrimSpace(exclude)
+		if ip := net.ParseIP(exclude); ip != nil {
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip = ip.To4()
+				bits = 8 * net.IPv4len
+			}
+			networks = append(networks, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+
+		_, network, err := net.ParseCIDR(exclude)
+		if err != nil {
+			return nil, fmt.Errorf("invalid exclusion %q", exclude)
+		}
+		networks = append(networks, network)
+	}
+	return networks, nil
+}
+
+func containedIn(ip net.IP, networks []*net.IPNet) bool {
+	for _, network := range networks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func ipToInt(ip net.IP) *big.Int {
+	if v4 := ip.To4(); v4 != nil {
+		ip = v4
+	}
+	return new(big.Int).SetBytes(ip)
+}
+
+func intToIP(value *big.Int, length int) net.IP {
+	bytes := value.Bytes()
+	ip := make(net.IP, length)
+	copy(ip[length-len(bytes):], bytes)
+	return ip
+}
+
+// GetRangeErrors returns why ranges of the spec could not be expanded. Such ranges contribute no addresses.
+func (s *ExternalServiceSpec) GetRangeErrors() []string {
+	errors := []string{}
+	for i := range s.Ranges {
+		if _, err := s.Ranges[i].Expand(); err != nil {
+			errors = append(errors, err.Error())
+		}
+	}
+	return errors
+}
+
+// appendRanges appends the addresses of all valid ranges
+func appendRanges(addresses []ExternalServiceAddress, known map[string]bool, ranges []AddressRange) []ExternalServiceAddress {
+	for i := range ranges {
+		ips, err := ranges[i].Expand()
+		if err != nil {
+			continue
+		}
+
+		definitions := make([]ExternalServiceAddress, 0, len(ips))
+		for _, ip := range ips {
+			definitions = append(definitions, ExternalServiceAddress{
+				IP:           ip,
+				Port:         ranges[i].Port,
+				Priority:     ranges[i].Priority,
+				HideNotReady: ranges[i].HideNotReady,
+			})
+		}
+		addresses = appendAddresses(addresses, known, definitions, nil)
+	}
+	return addresses
+}
@@ -0,0 +1,76 @@
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// DefaultRolloutPauseSeconds is the pause between the removal of two old addresses
+	DefaultRolloutPauseSeconds = 30
+	// DefaultRolloutDeadlineSeconds is the time new addresses have to pass their probes
+	DefaultRolloutDeadlineSeconds = 600
+)
+
+// RolloutStrategy replaces addresses gradually. Removed addresses are kept until all new
+// addresses pass their probes, then they are removed one at a time.
+type RolloutStrategy struct {
+	// PauseSeconds between the removal of two old addresses. Defaults to 30
+	PauseSeconds int32 `json:"pauseSeconds,omitempty"`
+	// DeadlineSeconds the new addresses have to pass their probes, otherwise the rollout is
+	// aborted. Defaults to 600
+	DeadlineSeconds int32 `json:"deadlineSeconds,omitempty"`
+}
+
+// RolloutPhase is the state of a rollout
+type RolloutPhase string
+
+const (
+	// RolloutPhaseProgressing waits for the new addresses or removes the old ones
+	RolloutPhaseProgressing RolloutPhase = "Progressing"
+	// RolloutPhaseComplete removed all old addresses
+	RolloutPhaseComplete RolloutPhase = "Complete"
+	// RolloutPhaseAborted keeps the old addresses and leaves out the new ones, because the new
+	// ones did not pass their probes in time. Changing the addresses starts a new rollout.
+	RolloutPhaseAborted RolloutPhase = "Aborted"
+)
+
+// RolloutStatus is the progress of the last rollout
+type RolloutStatus struct {
+	// Phase is Progressing, Complete or Aborted
+	Phase     RolloutPhase `json:"phase"`
+	StartTime metav1.Time  `json:"startTime"`
+	// NewAddresses are the IPs added by the rollout
+	NewAddresses []string `json:"newAddresses,omitempty"`
+	// OldAddresses are removed from the spec, but still kept until it is their turn
+	OldAddresses []ExternalServiceAddress `json:"oldAddresses,omitempty"`
+	// LastRemovalTime is when the last old address was removed
+	LastRemovalTime *metav1.Time `json:"lastRemovalTime,omitempty"`
+}
+
+// GetPause returns the pause between the removal of two old addresses in seconds
+func (r *RolloutStrategy) GetPause() int32 {
+	if r.PauseSeconds <= 0 {
+		return DefaultRolloutPauseSeconds
+	}
+	return r.PauseSeconds
+}
+
+// GetDeadline returns the time new addresses have to pass their probes in seconds
+func (r *RolloutStrategy) GetDeadline() int32 {
+	if r.DeadlineSeconds <= 0 {
+		return DefaultRolloutDeadlineSeconds
+	}
+	return r.DeadlineSeconds
+}
+
+// heldBackIps returns the new IPs an aborted rollout leaves out
+func (s *RolloutStatus) heldBackIps() map[string]bool {
+	ips := map[string]bool{}
+	if s == nil || s.Phase != RolloutPhaseAborted {
+		return ips
+	}
+	for _, ip := range s.NewAddresses {
+		ips[ip] = true
+	}
+	return ips
+}
@@ -0,0 +1,195 @@
+package v1alpha1
+
+import (
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// DNSSRVSourceKind discovers addresses from DNS SRV records
+	DNSSRVSourceKind = "dnsSRV"
+	// ConsulSourceKind discovers addresses from the Consul catalog
+	ConsulSourceKind = "consul"
+	// HTTPInventorySourceKind discovers addresses from a JSON or YAML document served over HTTP
+	HTTPInventorySourceKind = "httpInventory"
+	// NodesSourceKind discovers addresses from the Nodes of the cluster
+	NodesSourceKind = "nodes"
+	// FileSourceKind discovers addresses from a key of a ConfigMap or a Secret
+	FileSourceKind = "file"
+	// RemoteClusterSourceKind mirrors a Service of another Kubernetes cluster
+	RemoteClusterSourceKind = "remoteCluster"
+)
+
+// AddressSource discovers addresses of the ExternalService in addition to Ips and Addresses.
+// Exactly one kind of source has to be set.
+type AddressSource struct {
+	// Name identifies the source in the status. Defaults to <kind>-<index>
+	Name string `json:"name,omitempty"`
+	// IntervalSeconds between two discoveries. Defaults to 30
+	IntervalSeconds int32                `json:"intervalSeconds,omitempty"`
+	DNSSRV          *DNSSRVSource        `json:"dnsSRV,omitempty"`
+	Consul          *ConsulSource        `json:"consul,omitempty"`
+	HTTPInventory   *HTTPInventorySource `json:"httpInventory,omitempty"`
+	Nodes           *NodesSource         `json:"nodes,omitempty"`
+	File            *FileSource          `json:"file,omitempty"`
+	RemoteCluster   *RemoteClusterSource `json:"remoteCluster,omitempty"`
+}
+
+// DNSSRVSource resolves the targets of a SRV record. Every IP of a target becomes an address
+// with the port and the priority of its record.
+type DNSSRVSource struct {
+	// Name of the SRV record, e.g. _ldap._tcp.example.com
+	Name string `json:"name"`
+	// Server is the DNS server as host:port. Defaults to the resolver of the operator
+	Server string `json:"server,omitempty"`
+}
+
+// ConsulSource discovers the instances of a service registered in Consul. The catalog is
+// watched with blocking queries, so changes show up immediately.
+type ConsulSource struct {
+	// Address of the Consul HTTP API, e.g. http://consul-server.consul:8500
+	Address string `json:"address"`
+	// Service name in the catalog
+	Service string `json:"service"`
+	// Datacenter to query. Defaults to the datacenter of the agent
+	Datacenter string `json:"datacenter,omitempty"`
+	// Tags an instance must have all of
+	Tags []string `json:"tags,omitempty"`
+	// TokenSecretRef selects the ACL token in a Secret of the namespace of the ExternalService
+	TokenSecretRef *corev1.SecretKeySelector `json:"tokenSecretRef,omitempty"`
+	// PassingOnly only discovers instances whose Consul health checks pass. Without a
+	// ReadinessProbe this replaces probing, with one both have to pass
+	PassingOnly bool `json:"passingOnly,omitempty"`
+}
+
+// HTTPInventorySource polls a JSON or YAML document, e.g. the host inventory of a CMDB, and
+// extracts the addresses with either JSONPath or a Go template
+type HTTPInventorySource struct {
+	URL string `json:"url"`
+	// HeadersSecretRef names a Secret in the namespace of the ExternalService. Each of its
+	// keys is sent as a header with its value, e.g. Authorization
+	HeadersSecretRef *corev1.LocalObjectReference `json:"headersSecretRef,omitempty"`
+	JSONPath         *HTTPInventoryJSONPath       `json:"jsonPath,omitempty"`
+	// Template is a Go template printing one address per line as <ip>[:<port>] [<hostname>]
+	Template string `json:"template,omitempty"`
+}
+
+// HTTPInventoryJSONPath extracts the addresses with JSONPath expressions
+type HTTPInventoryJSONPath struct {
+	// Items selects the entries of the inventory, e.g. {.hosts[?(@.env=="prod")]}
+	Items string `json:"items"`
+	// IP is evaluated for every entry, e.g. {.ip}
+	IP string `json:"ip"`
+	// Port is evaluated for every entry. Defaults to spec.port
+	Port string `json:"port,omitempty"`
+	// Hostname is evaluated for every entry
+	Hostname string `json:"hostname,omitempty"`
+}
+
+// NodesSource uses the Nodes matching the selector, e.g. for daemons running in the host
+// network outside of any Pod. Nodes which are not Ready are left out.
+type NodesSource struct {
+	// Selector of the Nodes. Defaults to all Nodes
+	Selector *metav1.LabelSelector `json:"selector,omitempty"`
+	// AddressType is InternalIP or ExternalIP. Defaults to InternalIP
+	AddressType corev1.NodeAddressType `json:"addressType,omitempty"`
+}
+
+// FileFormat is the format of the list of addresses of a FileSource
+type FileFormat string
+
+const (
+	// FileFormatLines lists one <ip>[:<port>] per line. Empty lines and lines starting with # are ignored
+	FileFormatLines FileFormat = "lines"
+	// FileFormatCSV separates <ip>[:<port>] values by commas or new lines
+	FileFormatCSV FileFormat = "csv"
+	// FileFormatJSON is an array of "<ip>[:<port>]" strings or of objects with ip, port and hostname
+	FileFormatJSON FileFormat = "json"
+)
+
+// FileSource reads a list of addresses from a key of a ConfigMap or a Secret in the namespace
+// of the ExternalService, e.g. written from Terraform outputs. Exactly one of both has to be set.
+type FileSource struct {
+	ConfigMapKeyRef *corev1.ConfigMapKeySelector `json:"configMapKeyRef,omitempty"`
+	SecretKeyRef    *corev1.SecretKeySelector    `json:"secretKeyRef,omitempty"`
+	// Format is lines, csv or json. Defaults to json if the list starts with [, to csv if it
+	// contains a comma and to lines otherwise
+	Format FileFormat `json:"format,omitempty"`
+}
+
+// RemoteClusterMode selects what of the remote Service is mirrored
+type RemoteClusterMode string
+
+const (
+	// RemoteClusterModeEndpoints mirrors the ready addresses of the Endpoints of the remote Service
+	RemoteClusterModeEndpoints RemoteClusterMode = "Endpoints"
+	// RemoteClusterModeLoadBalancer mirrors the ingress IPs of the remote LoadBalancer Service
+	RemoteClusterModeLoadBalancer RemoteClusterMode = "LoadBalancer"
+)
+
+// RemoteClusterSource mirrors a Service of another cluster, so it can be reached as if it was
+// local. The remote object is watched, so changes show up immediately.
+type RemoteClusterSource struct {
+	// KubeconfigSecretRef selects the kubeconfig of the remote cluster in a Secret of the
+	// namespace of the ExternalService. It needs get and watch on the remote Service or Endpoints
+	KubeconfigSecretRef corev1.SecretKeySelector `json:"kubeconfigSecretRef"`
+	// Service in the remote cluster
+	Service string `json:"service"`
+	// Namespace of the remote Service. Defaults to the namespace of the ExternalService
+	Namespace string `json:"namespace,omitempty"`
+	// Mode is Endpoints or LoadBalancer. Defaults to Endpoints
+	Mode RemoteClusterMode `json:"mode,omitempty"`
+	// PortName selects the remote port the addresses use. Without it the port is only taken
+	// over if the remote Service has a single one, otherwise spec.port is used
+	PortName string `json:"portName,omitempty"`
+}
+
+// AddressSourceStatus is the state of an address source
+type AddressSourceStatus struct {
+	Name string `json:"name"`
+	// Addresses discovered by the last successful discovery. They are kept while the source fails
+	Addresses []ExternalServiceAddress `json:"addresses,omitempty"`
+	// LastUpdateTime is when the addresses changed the last time
+	LastUpdateTime *metav1.Time `json:"lastUpdateTime,omitempty"`
+	// Error of the last discovery, empty if it succeeded
+	Error string `json:"error,omitempty"`
+}
+
+// Kind returns the kind of the source or an empty string if no kind is set
+func (s *AddressSource) Kind() string {
+	switch {
+	case s.DNSSRV != nil:
+		return DNSSRVSourceKind
+	case s.Consul != nil:
+		return ConsulSourceKind
+	case s.HTTPInventory != nil:
+		return HTTPInventorySourceKind
+	case s.Nodes != nil:
+		return NodesSourceKind
+	case s.File != nil:
+		return FileSourceKind
+	case s.RemoteCluster != nil:
+		return RemoteClusterSourceKind
+	}
+	return ""
+}
+
+// GetName returns the name of the source with the given index in spec.sources
+func (s *AddressSource) GetName(index int) string {
+	if s.Name != "" {
+		return s.Name
+	}
+	return fmt.Sprintf("%v-%d", s.Kind(), index)
+}
+
+// GetSourceStatus returns the status of the named source or nil if it does not exist
+func (s *ExternalServiceStatus) GetSourceStatus(name string) *AddressSourceStatus {
+	for i := range s.Sources {
+		if s.Sources[i].Name == name {
+			return &s.Sources[i]
+		}
+	}
+	return nil
+}
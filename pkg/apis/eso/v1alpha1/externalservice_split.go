@@ -0,0 +1,40 @@
+package v1alpha1
+
+// TrafficSplitConfig shifts traffic gradually between groups of addresses, e.g. while moving
+// from an old datacenter to new machines. Every group gets a Service of its own and the
+// routes send the traffic to these Services by weight.
+type TrafficSplitConfig struct {
+	Groups []AddressGroup `json:"groups"`
+}
+
+// AddressGroup is a set of addresses which receives a share of the traffic
+type AddressGroup struct {
+	// Name of the group. Its Service is named <externalservice>-<name>
+	Name string `json:"name"`
+	// Ips of the group. Only addresses of the ExternalService are used
+	Ips []string `json:"ips"`
+	// Weight of the group relative to the weights of the other groups
+	Weight int32 `json:"weight"`
+}
+
+// AddressGroupStatus is the share of the traffic an address group effectively receives
+type AddressGroupStatus struct {
+	Name string `json:"name"`
+	// Service the traffic of the group is sent to
+	Service string `json:"service"`
+	// Weight is the share of the traffic in percent. Groups without ready addresses receive
+	// nothing, unless no group has ready addresses
+	Weight         int32 `json:"weight"`
+	ReadyAddresses int32 `json:"readyAddresses"`
+}
+
+// GetIps returns the IPs of the group which are addresses of the ExternalService
+func (g *AddressGroup) GetIps(e *ExternalService) map[string]bool {
+	ips := map[string]bool{}
+	for _, ip := range g.Ips {
+		if _, found := e.GetAddress(ip); found {
+			ips[ip] = true
+		}
+	}
+	return ips
+}
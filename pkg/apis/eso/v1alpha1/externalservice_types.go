@@ -10,16 +10,252 @@ type ExternalServiceHostPath struct {
 	Path string `json:"path"`
 }
 
+// ExternalServiceAddress describes a single backend address of an ExternalService
+type ExternalServiceAddress struct {
+	IP string `json:"ip"`
+	// Priority tier of the address. Only the tier with the lowest number which has healthy
+	// addresses receives traffic, all other tiers are kept in NotReadyAddresses. Defaults to 0
+	Priority int32 `json:"priority,omitempty"`
+	// Port overrides spec.port for this address
+	Port int32 `json:"port,omitempty"`
+	// Hostname is published on the Endpoints, so headless Services resolve <hostname>.<service>.<namespace>.svc
+	Hostname string `json:"hostname,omitempty"`
+	NodeName string `json:"nodeName,omitempty"`
+	// Zone is a topology hint. Endpoints have no field for it, it is only carried for
+	// consumers reading the ExternalService directly until EndpointSlices are supported
+	Zone   string            `json:"zone,omitempty"`
+	Labels map[string]string `json:"labels,omitempty"`
+	// ProbeHost overrides the Host header of HTTP readiness probes for this address
+	ProbeHost string `json:"probeHost,omitempty"`
+	// Drain keeps the address in NotReadyAddresses no matter what its probe says
+	Drain bool `json:"drain,omitempty"`
+	// HideNotReady keeps the address out of NotReadyAddresses while it is not ready, so
+	// Endpoints of large ranges only list the live addresses
+	HideNotReady bool `json:"hideNotReady,omitempty"`
+}
+
+// ReadinessOverride forces an address ready or not ready, no matter what its probe says.
+// If both are set, ForceNotReady wins. The override is removed once it expired.
+type ReadinessOverride struct {
+	IP            string       `json:"ip"`
+	ForceReady    bool         `json:"forceReady,omitempty"`
+	ForceNotReady bool         `json:"forceNotReady,omitempty"`
+	ExpiresAt     *metav1.Time `json:"expiresAt,omitempty"`
+}
+
+// OutlierDetection ejects addresses based on error rates scraped from a Prometheus metrics
+// endpoint, e.g. the per upstream request counters of an ingress controller
+type OutlierDetection struct {
+	// MetricsURL serves metrics in the Prometheus text format
+	MetricsURL string `json:"metricsUrl"`
+	// TotalMetric is a counter of all requests per address
+	TotalMetric string `json:"totalMetric"`
+	// ErrorMetric is a counter of failed requests per address. Defaults to TotalMetric
+	ErrorMetric string `json:"errorMetric,omitempty"`
+	// ErrorLabels select the failed requests of ErrorMetric. Values are regular expressions, e.g. status: "5.."
+	ErrorLabels map[string]string `json:"errorLabels,omitempty"`
+	// AddressLabel is the label holding the IP of the address. A port suffix is ignored. Defaults to upstream
+	AddressLabel string `json:"addressLabel,omitempty"`
+	// MaxErrorPercent is the highest error rate of an interval which is not a violation. Defaults to 50
+	MaxErrorPercent int32 `json:"maxErrorPercent,omitempty"`
+	// MinRequests an address must receive in an interval to be evaluated. Defaults to 1
+	MinRequests int32 `json:"minRequests,omitempty"`
+	// ConsecutiveViolations before an address gets ejected. Defaults to 3
+	ConsecutiveViolations int32 `json:"consecutiveViolations,omitempty"`
+	// IntervalSeconds between two scrapes. Defaults to 10
+	IntervalSeconds int32 `json:"intervalSeconds,omitempty"`
+	// EjectionSeconds an address stays ejected. Defaults to 30
+	EjectionSeconds int32 `json:"ejectionSeconds,omitempty"`
+}
+
+// ServiceConfig configures the Service created for the ExternalService
+type ServiceConfig struct {
+	// Type of the Service: ClusterIP, NodePort, LoadBalancer or ExternalName. Defaults to ClusterIP
+	Type corev1.ServiceType `json:"type,omitempty"`
+	// ClusterIP requests a specific virtual IP, "None" creates a headless Service.
+	// If empty, a virtual IP is allocated when Type is set, otherwise the Service stays
+	// headless. Changing it recreates the Service
+	ClusterIP             string                                  `json:"clusterIP,omitempty"`
+	SessionAffinity       corev1.ServiceAffinity                  `json:"sessionAffinity,omitempty"`
+	ExternalTrafficPolicy corev1.ServiceExternalTrafficPolicyType `json:"externalTrafficPolicy,omitempty"`
+	// ExternalName is the DNS name the Service points to if Type is ExternalName.
+	// No Endpoints are created and no addresses are probed in this mode
+	ExternalName string `json:"externalName,omitempty"`
+	// Annotations and Labels are set on the Service. Labels are set on the Endpoints, too
+	Annotations map[string]string `json:"annotations,omitempty"`
+	Labels      map[string]string `json:"labels,omitempty"`
+}
+
+// IngressKind selects the kind of object which routes the hosts of an ExternalService to its Service
+type IngressKind string
+
+const (
+	// IngressKindIngress creates a plain Ingress
+	IngressKindIngress IngressKind = "Ingress"
+	// IngressKindTraefik creates a Traefik IngressRoute
+	IngressKindTraefik IngressKind = "TraefikIngressRoute"
+	// IngressKindContour creates a Contour HTTPProxy per host
+	IngressKindContour IngressKind = "ContourHTTPProxy"
+)
+
+// IngressConfig configures the Ingress created for the hosts of the ExternalService
+type IngressConfig struct {
+	// Kind is Ingress, TraefikIngressRoute or ContourHTTPProxy. Defaults to Ingress
+	Kind        IngressKind       `json:"kind,omitempty"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+	Labels      map[string]string `json:"labels,omitempty"`
+	// CopyAnnotations copies the annotations of the ExternalService to the Ingress like earlier
+	// versions did. Annotations of kubectl, helm and the operator itself are left out
+	CopyAnnotations bool `json:"copyAnnotations,omitempty"`
+	// TLS terminates TLS for the hosts with the certificates of the Secrets
+	TLS     []IngressTLS   `json:"tls,omitempty"`
+	Traefik *TraefikConfig `json:"traefik,omitempty"`
+	Contour *ContourConfig `json:"contour,omitempty"`
+}
+
+// IngressTLS names the Secret holding the certificate of some hosts
+type IngressTLS struct {
+	// Hosts covered by the certificate. Defaults to all hosts
+	Hosts      []string `json:"hosts,omitempty"`
+	SecretName string   `json:"secretName"`
+}
+
+// TraefikConfig configures the Traefik IngressRoute
+type TraefikConfig struct {
+	EntryPoints []string               `json:"entryPoints,omitempty"`
+	Middlewares []TraefikMiddlewareRef `json:"middlewares,omitempty"`
+}
+
+// TraefikMiddlewareRef references a Traefik Middleware, e.g. for retries or rate limits
+type TraefikMiddlewareRef struct {
+	Name string `json:"name"`
+	// Namespace of the Middleware. Defaults to the namespace of the ExternalService
+	Namespace string `json:"namespace,omitempty"`
+}
+
+// ContourConfig configures the Contour HTTPProxies
+type ContourConfig struct {
+	IngressClassName string              `json:"ingressClassName,omitempty"`
+	RetryPolicy      *ContourRetryPolicy `json:"retryPolicy,omitempty"`
+}
+
+// ContourRetryPolicy retries failed requests
+type ContourRetryPolicy struct {
+	Count int32 `json:"count,omitempty"`
+	// PerTryTimeout is a duration like 150ms
+	PerTryTimeout string `json:"perTryTimeout,omitempty"`
+}
+
+// ExternalServiceAddressStatus is the state of a single address as seen by the prober
+type ExternalServiceAddressStatus struct {
+	IP    string `json:"ip"`
+	Ready bool   `json:"ready"`
+	// Healthy is the last settled probe result. It is not set as long as it is unknown
+	Healthy *bool `json:"healthy,omitempty"`
+	// Override is ForceReady or ForceNotReady while a readiness override is active
+	Override string `json:"override,omitempty"`
+	// EjectedUntil is set while the outlier detection ejected the address
+	EjectedUntil *metav1.Time `json:"ejectedUntil,omitempty"`
+	// LastProbe is the probe which settled the current health of the address
+	LastProbe *ProbeResult `json:"lastProbe,omitempty"`
+}
+
+// ProbeResult is the outcome of a single probe of an address
+type ProbeResult struct {
+	// Result is Success, Failure or Unknown
+	Result string `json:"result"`
+	// Message explains the result, e.g. the error of a failed connection
+	Message string `json:"message,omitempty"`
+	// LatencyMilliseconds is how long the probe took
+	LatencyMilliseconds int64 `json:"latencyMilliseconds"`
+}
+
+// DrainedAddress records since when and by whom an address is drained
+type DrainedAddress struct {
+	IP        string      `json:"ip"`
+	DrainedBy string      `json:"drainedBy,omitempty"`
+	Since     metav1.Time `json:"since"`
+}
+
+// DeletionPolicy decides what happens to the objects created for an ExternalService when it is deleted
+type DeletionPolicy string
+
+const (
+	// DeletionPolicyDelete lets the garbage collector delete the created objects
+	DeletionPolicyDelete DeletionPolicy = "Delete"
+	// DeletionPolicyOrphan keeps the created objects, their owner references are removed
+	DeletionPolicyOrphan DeletionPolicy = "Orphan"
+)
+
+// AdoptionPolicy decides what happens to an existing Service, Endpoints or Ingress named like
+// the ExternalService which it does not own yet
+type AdoptionPolicy string
+
+const (
+	// AdoptionPolicyAdopt takes the object over, its addresses are kept until they are reconciled
+	AdoptionPolicyAdopt AdoptionPolicy = "Adopt"
+	// AdoptionPolicyFail stops reconciling the ExternalService until the object is removed
+	AdoptionPolicyFail AdoptionPolicy = "Fail"
+	// AdoptionPolicyIgnore leaves the object alone and reconciles everything else
+	AdoptionPolicyIgnore AdoptionPolicy = "Ignore"
+)
+
+// TerminatingAddress is an address removed from the ExternalService. It is kept in
+// NotReadyAddresses until spec.drainSeconds passed since its removal.
+type TerminatingAddress struct {
+	ExternalServiceAddress `json:",inline"`
+	RemovedAt              metav1.Time `json:"removedAt"`
+}
+
 // ExternalServiceSpec defines the desired state of ExternalService
 // +k8s:openapi-gen=true
 type ExternalServiceSpec struct {
 	// INSERT ADDITIONAL SPEC FIELDS - desired state of cluster
 	// Important: Run "operator-sdk generate k8s" to regenerate code after modifying this file
 	// Add custom validation using kubebuilder tags: https://book.kubebuilder.io/beyond_basics/generating_crd.html
-	Port           int32                     `json:"port"`
-	Ips            []string                  `json:"ips"`
+	Port      int32                    `json:"port"`
+	Ips       []string                 `json:"ips,omitempty"`
+	Addresses []ExternalServiceAddress `json:"addresses,omitempty"`
+	// Ranges expand to an address for every IP of a CIDR or IP range
+	Ranges []AddressRange `json:"ranges,omitempty"`
+	// Sources discover further addresses, e.g. from DNS
+	Sources        []AddressSource           `json:"sources,omitempty"`
 	Hosts          []ExternalServiceHostPath `json:"hosts"`
 	ReadinessProbe corev1.Probe              `json:"readinessProbe"`
+	// ReadinessOverrides take precedence over the results of the ReadinessProbe
+	ReadinessOverrides []ReadinessOverride `json:"readinessOverrides,omitempty"`
+	// OutlierDetection ejects addresses based on passive signals in addition to the ReadinessProbe
+	OutlierDetection *OutlierDetection `json:"outlierDetection,omitempty"`
+	// Service configures the created Service. Without it a headless ClusterIP Service is created
+	Service *ServiceConfig `json:"service,omitempty"`
+	// Ingress configures the Ingress created for the Hosts
+	Ingress *IngressConfig `json:"ingress,omitempty"`
+	// Gateway creates a Gateway API route for the ExternalService in addition to the Ingress
+	Gateway *GatewayConfig `json:"gateway,omitempty"`
+	// Istio creates a ServiceEntry and optionally a DestinationRule for the ExternalService
+	Istio *IstioConfig `json:"istio,omitempty"`
+	// TrafficSplit sends the traffic of the routes to groups of addresses by weight
+	TrafficSplit *TrafficSplitConfig `json:"trafficSplit,omitempty"`
+	// Rollout replaces removed addresses gradually instead of all at once
+	Rollout *RolloutStrategy `json:"rollout,omitempty"`
+	// DrainSeconds keeps removed addresses in NotReadyAddresses before they are deleted from
+	// the Endpoints, so open connections can finish. Defaults to 0, which deletes them right away
+	DrainSeconds int32 `json:"drainSeconds,omitempty"`
+	// DeletionPolicy is Delete or Orphan. With Orphan the Service, Endpoints, Ingress and routes
+	// are kept when the ExternalService is deleted, e.g. while migrating the operator.
+	// Defaults to Delete
+	DeletionPolicy DeletionPolicy `json:"deletionPolicy,omitempty"`
+	// AdoptionPolicy is Adopt, Fail or Ignore. It decides what happens to an existing Service,
+	// Endpoints or Ingress named like the ExternalService which it does not own. Defaults to Adopt
+	AdoptionPolicy AdoptionPolicy `json:"adoptionPolicy,omitempty"`
+}
+
+// GetAdoptionPolicy returns the configured adoption policy or Adopt
+func (s *ExternalServiceSpec) GetAdoptionPolicy() AdoptionPolicy {
+	if s.AdoptionPolicy == "" {
+		return AdoptionPolicyAdopt
+	}
+	return s.AdoptionPolicy
 }
 
 // ExternalServiceStatus defines the observed state of ExternalService
@@ -28,6 +264,20 @@ type ExternalServiceStatus struct {
 	// INSERT ADDITIONAL STATUS FIELD - define observed state of cluster
 	// Important: Run "operator-sdk generate k8s" to regenerate code after modifying this file
 	// Add custom validation using kubebuilder tags: https://book.kubebuilder.io/beyond_basics/generating_crd.html
+
+	// ActivePriority is the priority tier which currently receives traffic
+	ActivePriority   *int32                         `json:"activePriority,omitempty"`
+	Conditions       []ExternalServiceCondition     `json:"conditions,omitempty"`
+	Addresses        []ExternalServiceAddressStatus `json:"addresses,omitempty"`
+	DrainedAddresses []DrainedAddress               `json:"drainedAddresses,omitempty"`
+	// Sources are the addresses discovered by spec.sources
+	Sources []AddressSourceStatus `json:"sources,omitempty"`
+	// TrafficSplit is the effective share of the traffic of every address group
+	TrafficSplit []AddressGroupStatus `json:"trafficSplit,omitempty"`
+	// Rollout is the progress of the last rollout of spec.rollout
+	Rollout *RolloutStatus `json:"rollout,omitempty"`
+	// TerminatingAddresses are removed, but kept not ready until spec.drainSeconds passed
+	TerminatingAddresses []TerminatingAddress `json:"terminatingAddresses,omitempty"`
 }
 
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
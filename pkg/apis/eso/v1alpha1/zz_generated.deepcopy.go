@@ -5,16 +5,245 @@
 package v1alpha1
 
 import (
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	runtime "k8s.io/apimachinery/pkg/runtime"
 )
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AddressGroup) DeepCopyInto(out *AddressGroup) {
+	*out = *in
+	if in.Ips != nil {
+		in, out := &in.Ips, &out.Ips
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AddressGroup.
+func (in *AddressGroup) DeepCopy() *AddressGroup {
+	if in == nil {
+		return nil
+	}
+	out := new(AddressGroup)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AddressGroupStatus) DeepCopyInto(out *AddressGroupStatus) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AddressGroupStatus.
+func (in *AddressGroupStatus) DeepCopy() *AddressGroupStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(AddressGroupStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AddressRange) DeepCopyInto(out *AddressRange) {
+	*out = *in
+	if in.Exclude != nil {
+		in, out := &in.Exclude, &out.Exclude
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AddressRange.
+func (in *AddressRange) DeepCopy() *AddressRange {
+	if in == nil {
+		return nil
+	}
+	out := new(AddressRange)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AddressSource) DeepCopyInto(out *AddressSource) {
+	*out = *in
+	if in.DNSSRV != nil {
+		in, out := &in.DNSSRV, &out.DNSSRV
+		*out = new(DNSSRVSource)
+		**out = **in
+	}
+	if in.Consul != nil {
+		in, out := &in.Consul, &out.Consul
+		*out = new(ConsulSource)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.HTTPInventory != nil {
+		in, out := &in.HTTPInventory, &out.HTTPInventory
+		*out = new(HTTPInventorySource)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Nodes != nil {
+		in, out := &in.Nodes, &out.Nodes
+		*out = new(NodesSource)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.File != nil {
+		in, out := &in.File, &out.File
+		*out = new(FileSource)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.RemoteCluster != nil {
+		in, out := &in.RemoteCluster, &out.RemoteCluster
+		*out = new(RemoteClusterSource)
+		(*in).DeepCopyInto(*out)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AddressSource.
+func (in *AddressSource) DeepCopy() *AddressSource {
+	if in == nil {
+		return nil
+	}
+	out := new(AddressSource)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AddressSourceStatus) DeepCopyInto(out *AddressSourceStatus) {
+	*out = *in
+	if in.Addresses != nil {
+		in, out := &in.Addresses, &out.Addresses
+		*out = make([]ExternalServiceAddress, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.LastUpdateTime != nil {
+		in, out := &in.LastUpdateTime, &out.LastUpdateTime
+		*out = (*in).DeepCopy()
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AddressSourceStatus.
+func (in *AddressSourceStatus) DeepCopy() *AddressSourceStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(AddressSourceStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ConsulSource) DeepCopyInto(out *ConsulSource) {
+	*out = *in
+	if in.Tags != nil {
+		in, out := &in.Tags, &out.Tags
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.TokenSecretRef != nil {
+		in, out := &in.TokenSecretRef, &out.TokenSecretRef
+		*out = new(v1.SecretKeySelector)
+		(*in).DeepCopyInto(*out)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ConsulSource.
+func (in *ConsulSource) DeepCopy() *ConsulSource {
+	if in == nil {
+		return nil
+	}
+	out := new(ConsulSource)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ContourConfig) DeepCopyInto(out *ContourConfig) {
+	*out = *in
+	if in.RetryPolicy != nil {
+		in, out := &in.RetryPolicy, &out.RetryPolicy
+		*out = new(ContourRetryPolicy)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ContourConfig.
+func (in *ContourConfig) DeepCopy() *ContourConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(ContourConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ContourRetryPolicy) DeepCopyInto(out *ContourRetryPolicy) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ContourRetryPolicy.
+func (in *ContourRetryPolicy) DeepCopy() *ContourRetryPolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(ContourRetryPolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DNSSRVSource) DeepCopyInto(out *DNSSRVSource) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DNSSRVSource.
+func (in *DNSSRVSource) DeepCopy() *DNSSRVSource {
+	if in == nil {
+		return nil
+	}
+	out := new(DNSSRVSource)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DrainedAddress) DeepCopyInto(out *DrainedAddress) {
+	*out = *in
+	in.Since.DeepCopyInto(&out.Since)
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DrainedAddress.
+func (in *DrainedAddress) DeepCopy() *DrainedAddress {
+	if in == nil {
+		return nil
+	}
+	out := new(DrainedAddress)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ExternalService) DeepCopyInto(out *ExternalService) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
 	in.Spec.DeepCopyInto(&out.Spec)
-	out.Status = in.Status
+	in.Status.DeepCopyInto(&out.Status)
 	return
 }
 
@@ -36,6 +265,76 @@ func (in *ExternalService) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ExternalServiceAddress) DeepCopyInto(out *ExternalServiceAddress) {
+	*out = *in
+	if in.Labels != nil {
+		in, out := &in.Labels, &out.Labels
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ExternalServiceAddress.
+func (in *ExternalServiceAddress) DeepCopy() *ExternalServiceAddress {
+	if in == nil {
+		return nil
+	}
+	out := new(ExternalServiceAddress)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ExternalServiceAddressStatus) DeepCopyInto(out *ExternalServiceAddressStatus) {
+	*out = *in
+	if in.Healthy != nil {
+		in, out := &in.Healthy, &out.Healthy
+		*out = new(bool)
+		**out = **in
+	}
+	if in.EjectedUntil != nil {
+		in, out := &in.EjectedUntil, &out.EjectedUntil
+		*out = (*in).DeepCopy()
+	}
+	if in.LastProbe != nil {
+		in, out := &in.LastProbe, &out.LastProbe
+		*out = new(ProbeResult)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ExternalServiceAddressStatus.
+func (in *ExternalServiceAddressStatus) DeepCopy() *ExternalServiceAddressStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(ExternalServiceAddressStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ExternalServiceCondition) DeepCopyInto(out *ExternalServiceCondition) {
+	*out = *in
+	in.LastTransitionTime.DeepCopyInto(&out.LastTransitionTime)
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ExternalServiceCondition.
+func (in *ExternalServiceCondition) DeepCopy() *ExternalServiceCondition {
+	if in == nil {
+		return nil
+	}
+	out := new(ExternalServiceCondition)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ExternalServiceHostPath) DeepCopyInto(out *ExternalServiceHostPath) {
 	*out = *in
@@ -93,12 +392,75 @@ func (in *ExternalServiceSpec) DeepCopyInto(out *ExternalServiceSpec) {
 		*out = make([]string, len(*in))
 		copy(*out, *in)
 	}
+	if in.Addresses != nil {
+		in, out := &in.Addresses, &out.Addresses
+		*out = make([]ExternalServiceAddress, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Ranges != nil {
+		in, out := &in.Ranges, &out.Ranges
+		*out = make([]AddressRange, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Sources != nil {
+		in, out := &in.Sources, &out.Sources
+		*out = make([]AddressSource, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 	if in.Hosts != nil {
 		in, out := &in.Hosts, &out.Hosts
 		*out = make([]ExternalServiceHostPath, len(*in))
 		copy(*out, *in)
 	}
 	in.ReadinessProbe.DeepCopyInto(&out.ReadinessProbe)
+	if in.ReadinessOverrides != nil {
+		in, out := &in.ReadinessOverrides, &out.ReadinessOverrides
+		*out = make([]ReadinessOverride, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.OutlierDetection != nil {
+		in, out := &in.OutlierDetection, &out.OutlierDetection
+		*out = new(OutlierDetection)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Service != nil {
+		in, out := &in.Service, &out.Service
+		*out = new(ServiceConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Ingress != nil {
+		in, out := &in.Ingress, &out.Ingress
+		*out = new(IngressConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Gateway != nil {
+		in, out := &in.Gateway, &out.Gateway
+		*out = new(GatewayConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Istio != nil {
+		in, out := &in.Istio, &out.Istio
+		*out = new(IstioConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.TrafficSplit != nil {
+		in, out := &in.TrafficSplit, &out.TrafficSplit
+		*out = new(TrafficSplitConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Rollout != nil {
+		in, out := &in.Rollout, &out.Rollout
+		*out = new(RolloutStrategy)
+		**out = **in
+	}
 	return
 }
 
@@ -115,6 +477,56 @@ func (in *ExternalServiceSpec) DeepCopy() *ExternalServiceSpec {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ExternalServiceStatus) DeepCopyInto(out *ExternalServiceStatus) {
 	*out = *in
+	if in.ActivePriority != nil {
+		in, out := &in.ActivePriority, &out.ActivePriority
+		*out = new(int32)
+		**out = **in
+	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]ExternalServiceCondition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Addresses != nil {
+		in, out := &in.Addresses, &out.Addresses
+		*out = make([]ExternalServiceAddressStatus, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.DrainedAddresses != nil {
+		in, out := &in.DrainedAddresses, &out.DrainedAddresses
+		*out = make([]DrainedAddress, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Sources != nil {
+		in, out := &in.Sources, &out.Sources
+		*out = make([]AddressSourceStatus, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.TrafficSplit != nil {
+		in, out := &in.TrafficSplit, &out.TrafficSplit
+		*out = make([]AddressGroupStatus, len(*in))
+		copy(*out, *in)
+	}
+	if in.Rollout != nil {
+		in, out := &in.Rollout, &out.Rollout
+		*out = new(RolloutStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.TerminatingAddresses != nil {
+		in, out := &in.TerminatingAddresses, &out.TerminatingAddresses
+		*out = make([]TerminatingAddress, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 	return
 }
 
@@ -127,3 +539,676 @@ func (in *ExternalServiceStatus) DeepCopy() *ExternalServiceStatus {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *FileSource) DeepCopyInto(out *FileSource) {
+	*out = *in
+	if in.ConfigMapKeyRef != nil {
+		in, out := &in.ConfigMapKeyRef, &out.ConfigMapKeyRef
+		*out = new(v1.ConfigMapKeySelector)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.SecretKeyRef != nil {
+		in, out := &in.SecretKeyRef, &out.SecretKeyRef
+		*out = new(v1.SecretKeySelector)
+		(*in).DeepCopyInto(*out)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new FileSource.
+func (in *FileSource) DeepCopy() *FileSource {
+	if in == nil {
+		return nil
+	}
+	out := new(FileSource)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GatewayConfig) DeepCopyInto(out *GatewayConfig) {
+	*out = *in
+	if in.ParentRefs != nil {
+		in, out := &in.ParentRefs, &out.ParentRefs
+		*out = make([]GatewayParentRef, len(*in))
+		copy(*out, *in)
+	}
+	if in.Hostnames != nil {
+		in, out := &in.Hostnames, &out.Hostnames
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Rules != nil {
+		in, out := &in.Rules, &out.Rules
+		*out = make([]GatewayHTTPRouteRule, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GatewayConfig.
+func (in *GatewayConfig) DeepCopy() *GatewayConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(GatewayConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GatewayHTTPHeader) DeepCopyInto(out *GatewayHTTPHeader) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GatewayHTTPHeader.
+func (in *GatewayHTTPHeader) DeepCopy() *GatewayHTTPHeader {
+	if in == nil {
+		return nil
+	}
+	out := new(GatewayHTTPHeader)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GatewayHTTPHeaderFilter) DeepCopyInto(out *GatewayHTTPHeaderFilter) {
+	*out = *in
+	if in.Set != nil {
+		in, out := &in.Set, &out.Set
+		*out = make([]GatewayHTTPHeader, len(*in))
+		copy(*out, *in)
+	}
+	if in.Add != nil {
+		in, out := &in.Add, &out.Add
+		*out = make([]GatewayHTTPHeader, len(*in))
+		copy(*out, *in)
+	}
+	if in.Remove != nil {
+		in, out := &in.Remove, &out.Remove
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GatewayHTTPHeaderFilter.
+func (in *GatewayHTTPHeaderFilter) DeepCopy() *GatewayHTTPHeaderFilter {
+	if in == nil {
+		return nil
+	}
+	out := new(GatewayHTTPHeaderFilter)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GatewayHTTPHeaderMatch) DeepCopyInto(out *GatewayHTTPHeaderMatch) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GatewayHTTPHeaderMatch.
+func (in *GatewayHTTPHeaderMatch) DeepCopy() *GatewayHTTPHeaderMatch {
+	if in == nil {
+		return nil
+	}
+	out := new(GatewayHTTPHeaderMatch)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GatewayHTTPPathMatch) DeepCopyInto(out *GatewayHTTPPathMatch) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GatewayHTTPPathMatch.
+func (in *GatewayHTTPPathMatch) DeepCopy() *GatewayHTTPPathMatch {
+	if in == nil {
+		return nil
+	}
+	out := new(GatewayHTTPPathMatch)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GatewayHTTPRouteFilter) DeepCopyInto(out *GatewayHTTPRouteFilter) {
+	*out = *in
+	if in.RequestHeaderModifier != nil {
+		in, out := &in.RequestHeaderModifier, &out.RequestHeaderModifier
+		*out = new(GatewayHTTPHeaderFilter)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ResponseHeaderModifier != nil {
+		in, out := &in.ResponseHeaderModifier, &out.ResponseHeaderModifier
+		*out = new(GatewayHTTPHeaderFilter)
+		(*in).DeepCopyInto(*out)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GatewayHTTPRouteFilter.
+func (in *GatewayHTTPRouteFilter) DeepCopy() *GatewayHTTPRouteFilter {
+	if in == nil {
+		return nil
+	}
+	out := new(GatewayHTTPRouteFilter)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GatewayHTTPRouteMatch) DeepCopyInto(out *GatewayHTTPRouteMatch) {
+	*out = *in
+	if in.Path != nil {
+		in, out := &in.Path, &out.Path
+		*out = new(GatewayHTTPPathMatch)
+		**out = **in
+	}
+	if in.Headers != nil {
+		in, out := &in.Headers, &out.Headers
+		*out = make([]GatewayHTTPHeaderMatch, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GatewayHTTPRouteMatch.
+func (in *GatewayHTTPRouteMatch) DeepCopy() *GatewayHTTPRouteMatch {
+	if in == nil {
+		return nil
+	}
+	out := new(GatewayHTTPRouteMatch)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GatewayHTTPRouteRule) DeepCopyInto(out *GatewayHTTPRouteRule) {
+	*out = *in
+	if in.Matches != nil {
+		in, out := &in.Matches, &out.Matches
+		*out = make([]GatewayHTTPRouteMatch, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Filters != nil {
+		in, out := &in.Filters, &out.Filters
+		*out = make([]GatewayHTTPRouteFilter, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GatewayHTTPRouteRule.
+func (in *GatewayHTTPRouteRule) DeepCopy() *GatewayHTTPRouteRule {
+	if in == nil {
+		return nil
+	}
+	out := new(GatewayHTTPRouteRule)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GatewayParentRef) DeepCopyInto(out *GatewayParentRef) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GatewayParentRef.
+func (in *GatewayParentRef) DeepCopy() *GatewayParentRef {
+	if in == nil {
+		return nil
+	}
+	out := new(GatewayParentRef)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HTTPInventoryJSONPath) DeepCopyInto(out *HTTPInventoryJSONPath) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HTTPInventoryJSONPath.
+func (in *HTTPInventoryJSONPath) DeepCopy() *HTTPInventoryJSONPath {
+	if in == nil {
+		return nil
+	}
+	out := new(HTTPInventoryJSONPath)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HTTPInventorySource) DeepCopyInto(out *HTTPInventorySource) {
+	*out = *in
+	if in.HeadersSecretRef != nil {
+		in, out := &in.HeadersSecretRef, &out.HeadersSecretRef
+		*out = new(v1.LocalObjectReference)
+		**out = **in
+	}
+	if in.JSONPath != nil {
+		in, out := &in.JSONPath, &out.JSONPath
+		*out = new(HTTPInventoryJSONPath)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HTTPInventorySource.
+func (in *HTTPInventorySource) DeepCopy() *HTTPInventorySource {
+	if in == nil {
+		return nil
+	}
+	out := new(HTTPInventorySource)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *IngressConfig) DeepCopyInto(out *IngressConfig) {
+	*out = *in
+	if in.Annotations != nil {
+		in, out := &in.Annotations, &out.Annotations
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.Labels != nil {
+		in, out := &in.Labels, &out.Labels
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.TLS != nil {
+		in, out := &in.TLS, &out.TLS
+		*out = make([]IngressTLS, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Traefik != nil {
+		in, out := &in.Traefik, &out.Traefik
+		*out = new(TraefikConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Contour != nil {
+		in, out := &in.Contour, &out.Contour
+		*out = new(ContourConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new IngressConfig.
+func (in *IngressConfig) DeepCopy() *IngressConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(IngressConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *IngressTLS) DeepCopyInto(out *IngressTLS) {
+	*out = *in
+	if in.Hosts != nil {
+		in, out := &in.Hosts, &out.Hosts
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new IngressTLS.
+func (in *IngressTLS) DeepCopy() *IngressTLS {
+	if in == nil {
+		return nil
+	}
+	out := new(IngressTLS)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *IstioConfig) DeepCopyInto(out *IstioConfig) {
+	*out = *in
+	if in.Hosts != nil {
+		in, out := &in.Hosts, &out.Hosts
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.DestinationRule != nil {
+		in, out := &in.DestinationRule, &out.DestinationRule
+		*out = new(IstioDestinationRule)
+		(*in).DeepCopyInto(*out)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new IstioConfig.
+func (in *IstioConfig) DeepCopy() *IstioConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(IstioConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *IstioDestinationRule) DeepCopyInto(out *IstioDestinationRule) {
+	*out = *in
+	if in.TLS != nil {
+		in, out := &in.TLS, &out.TLS
+		*out = new(IstioTLSSettings)
+		(*in).DeepCopyInto(*out)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new IstioDestinationRule.
+func (in *IstioDestinationRule) DeepCopy() *IstioDestinationRule {
+	if in == nil {
+		return nil
+	}
+	out := new(IstioDestinationRule)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *IstioTLSSettings) DeepCopyInto(out *IstioTLSSettings) {
+	*out = *in
+	if in.SubjectAltNames != nil {
+		in, out := &in.SubjectAltNames, &out.SubjectAltNames
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new IstioTLSSettings.
+func (in *IstioTLSSettings) DeepCopy() *IstioTLSSettings {
+	if in == nil {
+		return nil
+	}
+	out := new(IstioTLSSettings)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NodesSource) DeepCopyInto(out *NodesSource) {
+	*out = *in
+	if in.Selector != nil {
+		in, out := &in.Selector, &out.Selector
+		*out = new(metav1.LabelSelector)
+		(*in).DeepCopyInto(*out)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NodesSource.
+func (in *NodesSource) DeepCopy() *NodesSource {
+	if in == nil {
+		return nil
+	}
+	out := new(NodesSource)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *OutlierDetection) DeepCopyInto(out *OutlierDetection) {
+	*out = *in
+	if in.ErrorLabels != nil {
+		in, out := &in.ErrorLabels, &out.ErrorLabels
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OutlierDetection.
+func (in *OutlierDetection) DeepCopy() *OutlierDetection {
+	if in == nil {
+		return nil
+	}
+	out := new(OutlierDetection)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ProbeResult) DeepCopyInto(out *ProbeResult) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ProbeResult.
+func (in *ProbeResult) DeepCopy() *ProbeResult {
+	if in == nil {
+		return nil
+	}
+	out := new(ProbeResult)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ReadinessOverride) DeepCopyInto(out *ReadinessOverride) {
+	*out = *in
+	if in.ExpiresAt != nil {
+		in, out := &in.ExpiresAt, &out.ExpiresAt
+		*out = (*in).DeepCopy()
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ReadinessOverride.
+func (in *ReadinessOverride) DeepCopy() *ReadinessOverride {
+	if in == nil {
+		return nil
+	}
+	out := new(ReadinessOverride)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RemoteClusterSource) DeepCopyInto(out *RemoteClusterSource) {
+	*out = *in
+	in.KubeconfigSecretRef.DeepCopyInto(&out.KubeconfigSecretRef)
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RemoteClusterSource.
+func (in *RemoteClusterSource) DeepCopy() *RemoteClusterSource {
+	if in == nil {
+		return nil
+	}
+	out := new(RemoteClusterSource)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RolloutStatus) DeepCopyInto(out *RolloutStatus) {
+	*out = *in
+	in.StartTime.DeepCopyInto(&out.StartTime)
+	if in.NewAddresses != nil {
+		in, out := &in.NewAddresses, &out.NewAddresses
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.OldAddresses != nil {
+		in, out := &in.OldAddresses, &out.OldAddresses
+		*out = make([]ExternalServiceAddress, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.LastRemovalTime != nil {
+		in, out := &in.LastRemovalTime, &out.LastRemovalTime
+		*out = (*in).DeepCopy()
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RolloutStatus.
+func (in *RolloutStatus) DeepCopy() *RolloutStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(RolloutStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RolloutStrategy) DeepCopyInto(out *RolloutStrategy) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RolloutStrategy.
+func (in *RolloutStrategy) DeepCopy() *RolloutStrategy {
+	if in == nil {
+		return nil
+	}
+	out := new(RolloutStrategy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ServiceConfig) DeepCopyInto(out *ServiceConfig) {
+	*out = *in
+	if in.Annotations != nil {
+		in, out := &in.Annotations, &out.Annotations
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.Labels != nil {
+		in, out := &in.Labels, &out.Labels
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ServiceConfig.
+func (in *ServiceConfig) DeepCopy() *ServiceConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(ServiceConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TerminatingAddress) DeepCopyInto(out *TerminatingAddress) {
+	*out = *in
+	in.ExternalServiceAddress.DeepCopyInto(&out.ExternalServiceAddress)
+	in.RemovedAt.DeepCopyInto(&out.RemovedAt)
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TerminatingAddress.
+func (in *TerminatingAddress) DeepCopy() *TerminatingAddress {
+	if in == nil {
+		return nil
+	}
+	out := new(TerminatingAddress)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TraefikConfig) DeepCopyInto(out *TraefikConfig) {
+	*out = *in
+	if in.EntryPoints != nil {
+		in, out := &in.EntryPoints, &out.EntryPoints
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Middlewares != nil {
+		in, out := &in.Middlewares, &out.Middlewares
+		*out = make([]TraefikMiddlewareRef, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TraefikConfig.
+func (in *TraefikConfig) DeepCopy() *TraefikConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(TraefikConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TraefikMiddlewareRef) DeepCopyInto(out *TraefikMiddlewareRef) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TraefikMiddlewareRef.
+func (in *TraefikMiddlewareRef) DeepCopy() *TraefikMiddlewareRef {
+	if in == nil {
+		return nil
+	}
+	out := new(TraefikMiddlewareRef)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TrafficSplitConfig) DeepCopyInto(out *TrafficSplitConfig) {
+	*out = *in
+	if in.Groups != nil {
+		in, out := &in.Groups, &out.Groups
+		*out = make([]AddressGroup, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TrafficSplitConfig.
+func (in *TrafficSplitConfig) DeepCopy() *TrafficSplitConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(TrafficSplitConfig)
+	in.DeepCopyInto(out)
+	return out
+}
@@ -0,0 +1,121 @@
+package externalservice
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	esov1alpha1 "github.com/CrowdfoxGmbH/external-service-operator/pkg/apis/eso/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	extv1 "k8s.io/api/extensions/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+// conflictRequeueInterval is how often a conflict is checked again. Conflicting objects are
+// not owned by the ExternalService, so their deletion triggers no reconcile.
+const conflictRequeueInterval = 30 * time.Second
+
+// reconcileAdoption looks for an existing Service, Endpoints or Ingress named like the
+// ExternalService which it does not own. With the Adopt policy they are taken over as they
+// are, so the ready addresses of adopted Endpoints stay ready. Objects owned by another
+// controller, and all objects with the Fail or Ignore policy, are conflicts reported by the
+// OwnershipConflict condition. Returns the kinds of the conflicting objects.
+func (r *ReconcileExternalService) reconcileAdoption(instance *esov1alpha1.ExternalService, reqLogger logr.Logger) (map[string]bool, error) {
+	objects := map[string]runtime.Object{"Service": &corev1.Service{}}
+	if !instance.Spec.IsExternalName() {
+		objects["Endpoints"] = &corev1.Endpoints{}
+	}
+	if len(instance.Spec.Hosts) > 0 {
+		objects["Ingress"] = &extv1.Ingress{}
+	}
+
+	policy := instance.Spec.GetAdoptionPolicy()
+	conflicts := map[string]bool{}
+	ownedByOther := false
+	for _, kind := range []string{"Service", "Endpoints", "Ingress"} {
+		object, ok := objects[kind]
+		if !ok {
+			continue
+		}
+		err := r.client.Get(context.TODO(), types.NamespacedName{Name: instance.Name, Namespace: instance.Namespace}, object)
+		if errors.IsNotFound(err) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+
+		accessor, err := meta.Accessor(object)
+		if err != nil {
+			return nil, err
+		}
+		if metav1.IsControlledBy(accessor, instance) {
+			continue
+		}
+		if metav1.GetControllerOf(accessor) != nil {
+			ownedByOther = true
+			conflicts[kind] = true
+			continue
+		}
+		if policy != esov1alpha1.AdoptionPolicyAdopt {
+			conflicts[kind] = true
+			continue
+		}
+
+		if err := controllerutil.SetControllerReference(instance, accessor, r.scheme); err != nil {
+			return nil, err
+		}
+		reqLogger.Info("Adopting existing "+kind, "namespace", instance.Namespace, "name", instance.Name)
+		if err := r.client.Update(context.TODO(), object); err != nil {
+			return nil, err
+		}
+		r.recorder.Event(instance, corev1.EventTypeNormal, "Adopted", fmt.Sprintf("Adopted existing %v %v", kind, instance.Name))
+	}
+
+	if !setOwnershipConflictCondition(instance, conflicts, policy, ownedByOther) {
+		return conflicts, nil
+	}
+	reqLogger.Info("Updating ownership conflicts", "conflicts", conflicts)
+	return conflicts, nil
+}
+
+// setOwnershipConflictCondition sets the OwnershipConflict condition for the conflicting
+// kinds and removes it if there are none. Returns whether the status changed.
+func setOwnershipConflictCondition(instance *esov1alpha1.ExternalService, conflicts map[string]bool, policy esov1alpha1.AdoptionPolicy, ownedByOther bool) bool {
+	if len(conflicts) == 0 {
+		return instance.Status.RemoveCondition(esov1alpha1.OwnershipConflict)
+	}
+
+	objects := []string{}
+	for _, kind := range []string{"Service", "Endpoints", "Ingress"} {
+		if conflicts[kind] {
+			objects = append(objects, kind+" "+instance.Name)
+		}
+	}
+
+	condition := esov1alpha1.ExternalServiceCondition{
+		Type:    esov1alpha1.OwnershipConflict,
+		Status:  corev1.ConditionTrue,
+		Message: fmt.Sprintf("%v is not owned by the ExternalService", strings.Join(objects, ", ")),
+	}
+	if len(objects) > 1 {
+		condition.Message = strings.Replace(condition.Message, " is not ", " are not ", 1)
+	}
+	switch {
+	case policy == esov1alpha1.AdoptionPolicyFail:
+		condition.Reason = "AdoptionFailed"
+		condition.Message += ", reconciling is stopped"
+	case ownedByOther:
+		condition.Reason = "OwnedByOtherController"
+	default:
+		condition.Reason = "AdoptionIgnored"
+	}
+	return instance.Status.SetCondition(condition)
+}
@@ -0,0 +1,150 @@
+package externalservice
+
+import (
+	"context"
+	"testing"
+
+	esov1alpha1 "github.com/CrowdfoxGmbH/external-service-operator/pkg/apis/eso/v1alpha1"
+	"github.com/CrowdfoxGmbH/external-service-operator/pkg/testutils"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// getTestHandMadeEndpoints returns Endpoints created without the operator, serving two of the
+// addresses of the test ExternalService as ready
+func getTestHandMadeEndpoints() *corev1.Endpoints {
+	return &corev1.Endpoints{
+		ObjectMeta: metav1.ObjectMeta{Name: "TestService", Namespace: "external-services"},
+		Subsets:    getTestRolloutSubsets("10.0.100.10", "10.0.100.11"),
+	}
+}
+
+func TestReconcileAdoptionKeepsAddressesOfAdoptedEndpoints(t *testing.T) {
+	// Given hand-made Endpoints named like the ExternalService
+	instance := getTestExternalServiceCR()
+	client := testutils.InitFakeClient(instance, getTestHandMadeEndpoints())
+	reconciler := newTestReconciler(client)
+
+	// When they are adopted and reconciled
+	conflicts, err := reconciler.reconcileAdoption(instance, log)
+	if err != nil {
+		t.Fatalf("reconcile adoption: (%v)", err)
+	}
+	if _, err := reconciler.reconcileEndpoints(instance, log); err != nil {
+		t.Fatalf("reconcile endpoints: (%v)", err)
+	}
+
+	// Then the ExternalService owns them and their ready addresses stay ready
+	testutils.ExpectEqInt(int32(len(conflicts)), 0, t)
+	endpoint, _ := getRuntimeEndpoint(client, instance.Name, instance.Namespace)
+	testutils.ExpectTrue(metav1.IsControlledBy(endpoint, instance), t)
+	testutils.ExpectEqInt(int32(len(endpoint.Subsets[0].Addresses)), 2, t)
+	testutils.ExpectEqStr(endpoint.Subsets[0].Addresses[0].IP, "10.0.100.10", t)
+	testutils.ExpectEqInt(int32(len(endpoint.Subsets[0].NotReadyAddresses)), 1, t)
+	testutils.ExpectEqStr(endpoint.Subsets[0].NotReadyAddresses[0].IP, "10.0.100.12", t)
+}
+
+func TestReconcileAdoptionFailStopsReconciling(t *testing.T) {
+	// Given a hand-made Service and the Fail policy
+	instance := getTestExternalServiceCR()
+	instance.Spec.AdoptionPolicy = esov1alpha1.AdoptionPolicyFail
+	service := &corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: instance.Name, Namespace: instance.Namespace}}
+	client := testutils.InitFakeClient(instance, service)
+
+	if _, err := runTestReconcile(client, instance.Name, instance.Namespace); err != nil {
+		t.Fatalf("reconcile: (%v)", err)
+	}
+
+	// Then nothing is created and the conflict is reported
+	if _, err := getRuntimeEndpoint(client, instance.Name, instance.Namespace); !errors.IsNotFound(err) {
+		t.Errorf("Expected no Endpoints to be created, got (%v)", err)
+	}
+	found, _ := getRuntimeService(client, instance.Name, instance.Namespace)
+	testutils.ExpectEqInt(int32(len(found.OwnerReferences)), 0, t)
+	externalService, _ := getRuntimeExternalService(client, instance.Name, instance.Namespace)
+	condition := externalService.Status.GetCondition(esov1alpha1.OwnershipConflict)
+	testutils.ExpectEqStr(condition.Reason, "AdoptionFailed", t)
+	testutils.ExpectEqStr(condition.Message, "Service TestService is not owned by the ExternalService, reconciling is stopped", t)
+}
+
+func TestReconcileAdoptionFailResumesAfterConflictIsDeleted(t *testing.T) {
+	// Given a hand-made Service and the Fail policy
+	instance := getTestExternalServiceCR()
+	instance.Spec.AdoptionPolicy = esov1alpha1.AdoptionPolicyFail
+	service := &corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: instance.Name, Namespace: instance.Namespace}}
+	client := testutils.InitFakeClient(instance, service)
+
+	// Then the conflict is checked again later, its deletion triggers no reconcile
+	result, err := runTestReconcile(client, instance.Name, instance.Namespace)
+	if err != nil {
+		t.Fatalf("reconcile: (%v)", err)
+	}
+	if result.RequeueAfter != conflictRequeueInterval {
+		t.Errorf("Expected a requeue after %v, got %v", conflictRequeueInterval, result.RequeueAfter)
+	}
+
+	// When the conflicting Service is deleted
+	if err := client.Delete(context.TODO(), service); err != nil {
+		t.Fatalf("delete Service: (%v)", err)
+	}
+	if _, err := runTestReconcile(client, instance.Name, instance.Namespace); err != nil {
+		t.Fatalf("reconcile: (%v)", err)
+	}
+
+	// Then reconciling resumes and the conflict is cleared
+	found, err := getRuntimeService(client, instance.Name, instance.Namespace)
+	if err != nil {
+		t.Fatalf("get Service: (%v)", err)
+	}
+	testutils.ExpectTrue(metav1.IsControlledBy(found, instance), t)
+	if _, err := getRuntimeEndpoint(client, instance.Name, instance.Namespace); err != nil {
+		t.Errorf("Expected Endpoints to be created, got (%v)", err)
+	}
+	externalService, _ := getRuntimeExternalService(client, instance.Name, instance.Namespace)
+	if externalService.Status.GetCondition(esov1alpha1.OwnershipConflict) != nil {
+		t.Errorf("Expected the OwnershipConflict condition to be removed")
+	}
+}
+
+func TestReconcileAdoptionIgnoreLeavesObjectAlone(t *testing.T) {
+	// Given hand-made Endpoints and the Ignore policy
+	instance := getTestExternalServiceCR()
+	instance.Spec.AdoptionPolicy = esov1alpha1.AdoptionPolicyIgnore
+	client := testutils.InitFakeClient(instance, getTestHandMadeEndpoints())
+
+	if _, err := runTestReconcile(client, instance.Name, instance.Namespace); err != nil {
+		t.Fatalf("reconcile: (%v)", err)
+	}
+
+	// Then the Endpoints are untouched while the Service is created
+	endpoint, _ := getRuntimeEndpoint(client, instance.Name, instance.Namespace)
+	testutils.ExpectEqInt(int32(len(endpoint.OwnerReferences)), 0, t)
+	testutils.ExpectEqInt(int32(len(endpoint.Subsets[0].Addresses)), 2, t)
+	testutils.ExpectEqInt(int32(len(endpoint.Subsets[0].NotReadyAddresses)), 0, t)
+	service, _ := getRuntimeService(client, instance.Name, instance.Namespace)
+	testutils.ExpectTrue(metav1.IsControlledBy(service, instance), t)
+	externalService, _ := getRuntimeExternalService(client, instance.Name, instance.Namespace)
+	testutils.ExpectEqStr(externalService.Status.GetCondition(esov1alpha1.OwnershipConflict).Reason, "AdoptionIgnored", t)
+}
+
+func TestReconcileAdoptionDoesNotTakeObjectsOfOtherControllers(t *testing.T) {
+	// Given an Ingress controlled by another object
+	instance := getTestExternalServiceCR()
+	ingress := createIngressCr(instance)
+	controller := true
+	ingress.OwnerReferences = []metav1.OwnerReference{metav1.OwnerReference{APIVersion: "v1", Kind: "ConfigMap", Name: "other", UID: "other", Controller: &controller}}
+	client := testutils.InitFakeClient(instance, ingress)
+
+	if _, err := runTestReconcile(client, instance.Name, instance.Namespace); err != nil {
+		t.Fatalf("reconcile: (%v)", err)
+	}
+
+	// Then the Ingress is kept as it is
+	found, _ := getRuntimeIngress(client, instance.Name, instance.Namespace)
+	testutils.ExpectEqStr(string(found.OwnerReferences[0].UID), "other", t)
+	externalService, _ := getRuntimeExternalService(client, instance.Name, instance.Namespace)
+	condition := externalService.Status.GetCondition(esov1alpha1.OwnershipConflict)
+	testutils.ExpectEqStr(condition.Reason, "OwnedByOtherController", t)
+	testutils.ExpectEqStr(condition.Message, "Ingress TestService is not owned by the ExternalService", t)
+}
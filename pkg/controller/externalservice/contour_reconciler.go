@@ -0,0 +1,76 @@
+package externalservice
+
+import (
+	esov1alpha1 "github.com/CrowdfoxGmbH/external-service-operator/pkg/apis/eso/v1alpha1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+var contourHTTPProxyGVK = schema.GroupVersionKind{Group: "projectcontour.io", Version: "v1", Kind: "HTTPProxy"}
+
+// createContourHTTPProxyCrs creates one HTTPProxy per host, as an HTTPProxy has a single virtual host
+func createContourHTTPProxyCrs(i *esov1alpha1.ExternalService) []*unstructured.Unstructured {
+	config := i.Spec.Ingress.Contour
+	if config == nil {
+		config = &esov1alpha1.ContourConfig{}
+	}
+
+	proxies := []*unstructured.Unstructured{}
+	for index, host := range distinctHosts(i) {
+		virtualhost := map[string]interface{}{"fqdn": host}
+		if secretName := tlsSecretName(i, host); secretName != "" {
+			virtualhost["tls"] = map[string]interface{}{"secretName": secretName}
+		}
+
+		routes := []interface{}{}
+		for _, hostpath := range i.Spec.Hosts {
+			if hostpath.Host == host {
+				routes = append(routes, contourRoute(i, config, hostpath.Path))
+			}
+		}
+
+		spec := map[string]interface{}{
+			"virtualhost": virtualhost,
+			"routes":      routes,
+		}
+		if config.IngressClassName != "" {
+			spec["ingressClassName"] = config.IngressClassName
+		}
+
+		proxies = append(proxies, newNamedUnstructured(i, contourHTTPProxyGVK, routeName(i, index), spec, ingressLabels(i), ingressAnnotations(i)))
+	}
+	return proxies
+}
+
+func contourRoute(i *esov1alpha1.ExternalService, config *esov1alpha1.ContourConfig, path string) map[string]interface{} {
+	if path == "" {
+		path = "/"
+	}
+
+	services := []interface{}{
+		map[string]interface{}{"name": i.Name, "port": int64(i.Spec.Port)},
+	}
+	if backends := splitBackends(i); backends != nil {
+		services = []interface{}{}
+		for _, backend := range backends {
+			services = append(services, map[string]interface{}{"name": backend.service, "port": int64(i.Spec.Port), "weight": backend.weight})
+		}
+	}
+
+	route := map[string]interface{}{
+		"conditions": []interface{}{map[string]interface{}{"prefix": path}},
+		"services":   services,
+	}
+
+	if retry := config.RetryPolicy; retry != nil {
+		retryPolicy := map[string]interface{}{}
+		if retry.Count != 0 {
+			retryPolicy["count"] = int64(retry.Count)
+		}
+		if retry.PerTryTimeout != "" {
+			retryPolicy["perTryTimeout"] = retry.PerTryTimeout
+		}
+		route["retryPolicy"] = retryPolicy
+	}
+	return route
+}
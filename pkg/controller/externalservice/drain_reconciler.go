@@ -0,0 +1,80 @@
+package externalservice
+
+import (
+	"fmt"
+	"reflect"
+
+	esov1alpha1 "github.com/CrowdfoxGmbH/external-service-operator/pkg/apis/eso/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/go-logr/logr"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// reconcileDrainedAddresses keeps track of drained addresses in the status. Moving drained
+// addresses to NotReadyAddresses is done by the prober.
+func (r *ReconcileExternalService) reconcileDrainedAddresses(instance *esov1alpha1.ExternalService, reqLogger logr.Logger) (reconcile.Result, error) {
+	known := map[string]esov1alpha1.DrainedAddress{}
+	for _, drained := range instance.Status.DrainedAddresses {
+		known[drained.IP] = drained
+	}
+
+	drainedAddresses := []esov1alpha1.DrainedAddress{}
+	for _, ip := range instance.GetDrainedIps() {
+		if drained, found := known[ip]; found {
+			drainedAddresses = append(drainedAddresses, drained)
+			continue
+		}
+
+		drainedAddresses = append(drainedAddresses, esov1alpha1.DrainedAddress{
+			IP:        ip,
+			DrainedBy: instance.Annotations[esov1alpha1.DrainedByAnnotation],
+			Since:     metav1.Now(),
+		})
+	}
+
+	if len(drainedAddresses) == 0 && len(instance.Status.DrainedAddresses) == 0 {
+		return reconcile.Result{}, nil
+	}
+	if reflect.DeepEqual(drainedAddresses, instance.Status.DrainedAddresses) {
+		return reconcile.Result{}, nil
+	}
+
+	instance.Status.DrainedAddresses = drainedAddresses
+	return reconcile.Result{}, nil
+}
+
+// recordDrainEvents records an Event for every address drained or undrained since the given
+// status. It is called once the status is written, so a failed update records nothing and the
+// next reconcile records the Events instead.
+func (r *ReconcileExternalService) recordDrainEvents(instance *esov1alpha1.ExternalService, before []esov1alpha1.DrainedAddress, reqLogger logr.Logger) {
+	wasDrained := map[string]bool{}
+	for _, drained := range before {
+		wasDrained[drained.IP] = true
+	}
+	isDrained := map[string]bool{}
+	for _, drained := range instance.Status.DrainedAddresses {
+		isDrained[drained.IP] = true
+		if wasDrained[drained.IP] {
+			continue
+		}
+
+		reqLogger.Info("Address drained", "ip", drained.IP, "drainedBy", drained.DrainedBy)
+		r.recorder.Event(instance, corev1.EventTypeNormal, "AddressDrained", fmt.Sprintf("Address %v drained%v", drained.IP, byWhom(drained.DrainedBy)))
+	}
+
+	for _, drained := range before {
+		if !isDrained[drained.IP] {
+			reqLogger.Info("Address undrained", "ip", drained.IP)
+			r.recorder.Event(instance, corev1.EventTypeNormal, "AddressUndrained", fmt.Sprintf("Address %v undrained%v", drained.IP, byWhom(instance.Annotations[esov1alpha1.DrainedByAnnotation])))
+		}
+	}
+}
+
+func byWhom(drainedBy string) string {
+	if drainedBy == "" {
+		return ""
+	}
+	return " by " + drainedBy
+}
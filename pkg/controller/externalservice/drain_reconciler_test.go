@@ -0,0 +1,107 @@
+package externalservice
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	esov1alpha1 "github.com/CrowdfoxGmbH/external-service-operator/pkg/apis/eso/v1alpha1"
+	"github.com/CrowdfoxGmbH/external-service-operator/pkg/testutils"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func expectEvent(recorder *record.FakeRecorder, prefix string, t *testing.T) {
+	select {
+	case event := <-recorder.Events:
+		if !strings.HasPrefix(event, prefix) {
+			t.Errorf("Expected Event starting with '%v' but got '%v'", prefix, event)
+		}
+	default:
+		t.Errorf("Expected Event starting with '%v' but got none", prefix)
+	}
+}
+
+func TestReconcileDrainAnnotation(t *testing.T) {
+	instance := getTestExternalServiceCR()
+	instance.Annotations[esov1alpha1.DrainAnnotation] = "10.0.100.11"
+	instance.Annotations[esov1alpha1.DrainedByAnnotation] = "alice"
+	client := testutils.InitFakeClient(instance)
+	r := newTestReconciler(client)
+	request := reconcile.Request{NamespacedName: types.NamespacedName{Name: instance.Name, Namespace: instance.Namespace}}
+
+	res, err := r.Reconcile(request)
+	testutils.ExpectNoErrorsAndRequeue(res, err, t)
+
+	recorder := r.recorder.(*record.FakeRecorder)
+	expectEvent(recorder, "Normal AddressDrained Address 10.0.100.11 drained by alice", t)
+
+	actual := &esov1alpha1.ExternalService{}
+	if err := client.Get(context.TODO(), request.NamespacedName, actual); err != nil {
+		t.Fatalf("get ExternalService: (%v)", err)
+	}
+	testutils.ExpectEqInt(int32(len(actual.Status.DrainedAddresses)), 1, t)
+	testutils.ExpectEqStr(actual.Status.DrainedAddresses[0].IP, "10.0.100.11", t)
+	testutils.ExpectEqStr(actual.Status.DrainedAddresses[0].DrainedBy, "alice", t)
+
+	// a second reconcile must not record the drain again
+	res, err = r.Reconcile(request)
+	testutils.ExpectNoErrorsAndRequeue(res, err, t)
+	if len(recorder.Events) != 0 {
+		t.Errorf("Expected no further Events but got %v", <-recorder.Events)
+	}
+
+	// removing the annotation undrains the address
+	delete(actual.Annotations, esov1alpha1.DrainAnnotation)
+	updateObject(client, actual)
+	res, err = r.Reconcile(request)
+	testutils.ExpectNoErrorsAndRequeue(res, err, t)
+	expectEvent(recorder, "Normal AddressUndrained Address 10.0.100.11 undrained", t)
+}
+
+// failingStatusClient fails every status update
+type failingStatusClient struct {
+	client.Client
+}
+
+func (c failingStatusClient) Status() client.StatusWriter {
+	return failingStatusWriter{}
+}
+
+type failingStatusWriter struct{}
+
+func (failingStatusWriter) Update(ctx context.Context, obj runtime.Object) error {
+	return fmt.Errorf("conflict")
+}
+
+func TestReconcileDrainRecordsNoEventWhenStatusUpdateFails(t *testing.T) {
+	// Given a drained address whose status can not be written
+	instance := getTestExternalServiceCR()
+	instance.Annotations[esov1alpha1.DrainAnnotation] = "10.0.100.11"
+	fakeClient := testutils.InitFakeClient(instance)
+	r := newTestReconciler(failingStatusClient{fakeClient})
+	request := reconcile.Request{NamespacedName: types.NamespacedName{Name: instance.Name, Namespace: instance.Namespace}}
+
+	_, err := r.Reconcile(request)
+	if err == nil {
+		t.Fatalf("Expected the failed status update to be returned")
+	}
+
+	// Then no Event claims the address got drained
+	recorder := r.recorder.(*record.FakeRecorder)
+	if len(recorder.Events) != 0 {
+		t.Errorf("Expected no Events but got %v", <-recorder.Events)
+	}
+
+	// When the status can be written again
+	r.client = fakeClient
+	res, err := r.Reconcile(request)
+	testutils.ExpectNoErrorsAndRequeue(res, err, t)
+
+	// Then the drain is recorded
+	expectEvent(recorder, "Normal AddressDrained Address 10.0.100.11 drained", t)
+}
@@ -39,6 +39,11 @@ func (r *ReconcileExternalService) reconcileEndpoints(instance *esov1alpha1.Exte
 		return reconcile.Result{}, err
 	}
 
+	if !metav1.IsControlledBy(found, instance) {
+		reqLogger.Info("Skip reconcile: Endpoint is not owned by the ExternalService", "Endpoint.Namespace", found.Namespace, "Endpoint.Name", found.Name)
+		return reconcile.Result{}, nil
+	}
+
 	reconciledEndpoint, changed := mergeEndpointWithExternalServiceDef(instance, found)
 	if !changed {
 		reqLogger.Info("Skip reconcile: Endpoint already exists", "Endpoint.Namespace", found.Namespace, "Endpoint.Name", found.Name)
@@ -60,26 +65,48 @@ func (r *ReconcileExternalService) reconcileEndpoints(instance *esov1alpha1.Exte
 	}, err
 }
 
-func filterRemovedIps(externalService *esov1alpha1.ExternalService, addresses []corev1.EndpointAddress) []corev1.EndpointAddress {
+// removeEndpoints deletes the Endpoint of the ExternalService if it owns one
+func (r *ReconcileExternalService) removeEndpoints(instance *esov1alpha1.ExternalService, reqLogger logr.Logger) (reconcile.Result, error) {
+	found := &corev1.Endpoints{}
+	err := r.client.Get(context.TODO(), types.NamespacedName{Name: instance.Name, Namespace: instance.Namespace}, found)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return reconcile.Result{}, nil
+		}
+		return reconcile.Result{}, err
+	}
+
+	if !metav1.IsControlledBy(found, instance) {
+		return reconcile.Result{}, nil
+	}
+
+	reqLogger.Info("Deleting Endpoint", "Endpoint.Namespace", found.Namespace, "Endpoint.Name", found.Name)
+	if err := r.client.Delete(context.TODO(), found); err != nil && !errors.IsNotFound(err) {
+		return reconcile.Result{}, err
+	}
+	return reconcile.Result{}, nil
+}
+
+// filterRemovedIps returns the addresses which are still wanted, in their existing order.
+// The wanted definition replaces the existing one, so changed hostnames are picked up.
+func filterRemovedIps(wanted map[string]corev1.EndpointAddress, addresses []corev1.EndpointAddress) []corev1.EndpointAddress {
 	filteredList := []corev1.EndpointAddress{}
 	for _, address := range addresses {
-		for _, ip := range externalService.Spec.Ips {
-			if address.IP == ip {
-				filteredList = append(filteredList, address)
-			}
+		if wantedAddress, ok := wanted[address.IP]; ok {
+			filteredList = append(filteredList, wantedAddress)
 		}
 	}
 
 	return filteredList
 }
 
-func addMissingIps(readyAddresses []corev1.EndpointAddress, notReadyAddresses []corev1.EndpointAddress, ips []string) []corev1.EndpointAddress {
+func addMissingIps(readyAddresses []corev1.EndpointAddress, notReadyAddresses []corev1.EndpointAddress, addresses []corev1.EndpointAddress) []corev1.EndpointAddress {
 	newNotReadyAddresses := notReadyAddresses
 
-	for _, ip := range ips {
+	for _, wanted := range addresses {
 		found := false
 		for _, address := range readyAddresses {
-			if address.IP == ip {
+			if address.IP == wanted.IP {
 				found = true
 				break
 			}
@@ -87,7 +114,7 @@ func addMissingIps(readyAddresses []corev1.EndpointAddress, notReadyAddresses []
 
 		if !found {
 			for _, address := range notReadyAddresses {
-				if address.IP == ip {
+				if address.IP == wanted.IP {
 					found = true
 					break
 				}
@@ -97,7 +124,7 @@ func addMissingIps(readyAddresses []corev1.EndpointAddress, notReadyAddresses []
 		// If Ips are not known to the Endpoints yet, add them to NotReady to prevent unready
 		// Services getting traffic
 		if !found {
-			newNotReadyAddresses = append(newNotReadyAddresses, corev1.EndpointAddress{IP: ip})
+			newNotReadyAddresses = append(newNotReadyAddresses, wanted)
 		}
 	}
 
@@ -106,14 +133,40 @@ func addMissingIps(readyAddresses []corev1.EndpointAddress, notReadyAddresses []
 
 func mergeEndpointWithExternalServiceDef(externalService *esov1alpha1.ExternalService, endpoint *corev1.Endpoints) (mergedEndpoint *corev1.Endpoints, changed bool) {
 	mergedEndpoint = endpoint.DeepCopy()
+	// the standard labels are only set on creation, the configured labels follow changes
+	mergeManagedMetadata(&mergedEndpoint.ObjectMeta, managedMetadata(endpointLabels(externalService), nil))
+
+	existingReady := []corev1.EndpointAddress{}
+	existingNotReady := []corev1.EndpointAddress{}
+	for _, subset := range endpoint.Subsets {
+		existingReady = append(existingReady, subset.Addresses...)
+		existingNotReady = append(existingNotReady, subset.NotReadyAddresses...)
+	}
+
+	mergedEndpoint.Subsets = []corev1.EndpointSubset{}
+	for _, subset := range createEndpointSubsets(externalService) {
+		wanted := map[string]corev1.EndpointAddress{}
+		for _, address := range subset.NotReadyAddresses {
+			wanted[address.IP] = address
+		}
+
+		// Keep the ready state of known IPs, then add new IPs
+		newReadyAddresses := filterRemovedIps(wanted, existingReady)
+		newNotReadyAddresses := filterRemovedIps(wanted, existingNotReady)
+		subset.NotReadyAddresses = addMissingIps(newReadyAddresses, newNotReadyAddresses, subset.NotReadyAddresses)
+		subset.Addresses = newReadyAddresses
 
-	newReadyAddresses := filterRemovedIps(externalService, endpoint.Subsets[0].Addresses)
-	newNotReadyAddresses := filterRemovedIps(externalService, endpoint.Subsets[0].NotReadyAddresses)
+		// Keep server defaulted fields like the protocol of unchanged ports
+		for _, existing := range endpoint.Subsets {
+			if len(existing.Ports) > 0 && existing.Ports[0].Port == subset.Ports[0].Port {
+				subset.Ports = existing.Ports
+				break
+			}
+		}
 
-	// Then add new IPs
-	mergedEndpoint.Subsets[0].Addresses = newReadyAddresses
-	mergedEndpoint.Subsets[0].NotReadyAddresses = addMissingIps(newReadyAddresses, newNotReadyAddresses, externalService.Spec.Ips)
-	mergedEndpoint.Subsets[0].Ports[0].Port = externalService.Spec.Port
+		mergedEndpoint.Subsets = append(mergedEndpoint.Subsets, subset)
+	}
+	mergedEndpoint.Subsets = externalService.HideNotReadyAddresses(mergedEndpoint.Subsets)
 
 	return mergedEndpoint, !equalIgnoreReady(mergedEndpoint, endpoint)
 }
@@ -123,37 +176,36 @@ func equalIgnoreReady(a *corev1.Endpoints, b *corev1.Endpoints) bool {
 		return false
 	}
 
-	if a.Subsets[0].Ports[0] != b.Subsets[0].Ports[0] {
+	if len(a.Subsets) != len(b.Subsets) {
 		return false
 	}
 
-	// This check ignores the difference between Ready and not Ready
-	aAddresses := a.Subsets[0].Addresses
-	aAddresses = append(aAddresses, a.Subsets[0].NotReadyAddresses...)
+	for i := range a.Subsets {
+		if !reflect.DeepEqual(a.Subsets[i].Ports, b.Subsets[i].Ports) {
+			return false
+		}
 
-	bAddresses := b.Subsets[0].Addresses
-	bAddresses = append(bAddresses, b.Subsets[0].NotReadyAddresses...)
+		// This check ignores the difference between Ready and not Ready
+		aAddresses := a.Subsets[i].Addresses
+		aAddresses = append(aAddresses, a.Subsets[i].NotReadyAddresses...)
 
-	//check if b has every ip a has
-	for _, aAddress := range aAddresses {
-		found := false
-		for _, bAddress := range bAddresses {
-			if aAddress == bAddress {
-				found = true
-				break
-			}
-		}
+		bAddresses := b.Subsets[i].Addresses
+		bAddresses = append(bAddresses, b.Subsets[i].NotReadyAddresses...)
 
-		if !found {
+		if !containsAllAddresses(aAddresses, bAddresses) || !containsAllAddresses(bAddresses, aAddresses) {
 			return false
 		}
 	}
 
-	// check if a has every ip b has
-	for _, bAddress := range bAddresses {
+	return true
+}
+
+// containsAllAddresses checks if a has every address b has
+func containsAllAddresses(a []corev1.EndpointAddress, b []corev1.EndpointAddress) bool {
+	for _, bAddress := range b {
 		found := false
-		for _, aAddress := range aAddresses {
-			if aAddress == bAddress {
+		for _, aAddress := range a {
+			if reflect.DeepEqual(aAddress, bAddress) {
 				found = true
 				break
 			}
@@ -167,36 +219,52 @@ func equalIgnoreReady(a *corev1.Endpoints, b *corev1.Endpoints) bool {
 	return true
 }
 
-func CreateEndpointsCr(i *esov1alpha1.ExternalService) *corev1.Endpoints {
+// createEndpointSubsets groups the addresses of the ExternalService by their port. All
+// addresses are not ready. The subset of the first address comes first.
+func createEndpointSubsets(i *esov1alpha1.ExternalService) []corev1.EndpointSubset {
+	subsets := []corev1.EndpointSubset{}
+	subsetIndex := map[int32]int{}
 
-	labels := map[string]string{
-		"app":         i.Name,
-		"serviceType": "external",
-	}
+	for _, address := range i.GetAddresses() {
+		port := i.Spec.GetPort(address)
+
+		index, found := subsetIndex[port]
+		if !found {
+			index = len(subsets)
+			subsetIndex[port] = index
+			subsets = append(subsets, corev1.EndpointSubset{
+				NotReadyAddresses: []corev1.EndpointAddress{},
+				Ports: []corev1.EndpointPort{
+					corev1.EndpointPort{
+						Port: port,
+					},
+				},
+			})
+		}
 
-	endpointAddresses := []corev1.EndpointAddress{}
-	for _, ip := range i.Spec.Ips {
-		endpointAddresses = append(endpointAddresses, corev1.EndpointAddress{
-			IP: ip,
-		})
+		subsets[index].NotReadyAddresses = append(subsets[index].NotReadyAddresses, address.ToEndpointAddress())
 	}
 
-	return &corev1.Endpoints{
+	return subsets
+}
+
+func CreateEndpointsCr(i *esov1alpha1.ExternalService) *corev1.Endpoints {
+	endpoint := &corev1.Endpoints{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      i.Name,
 			Namespace: i.Namespace,
-			Labels:    labels,
-		},
-		Subsets: []corev1.EndpointSubset{
-			corev1.EndpointSubset{
-				NotReadyAddresses: endpointAddresses,
-				Ports: []corev1.EndpointPort{
-					corev1.EndpointPort{
-						Port: i.Spec.Port,
-					},
-				},
-			},
 		},
+		Subsets: i.HideNotReadyAddresses(createEndpointSubsets(i)),
 	}
+	setManagedMetadata(&endpoint.ObjectMeta, i, endpointLabels(i), nil)
 
+	return endpoint
+}
+
+// endpointLabels returns the configured labels of the Service, the Endpoint carries them as well
+func endpointLabels(i *esov1alpha1.ExternalService) map[string]string {
+	if i.Spec.Service == nil {
+		return nil
+	}
+	return i.Spec.Service.Labels
 }
@@ -438,3 +438,73 @@ func TestEqualIgnoreReadyIpChanged(t *testing.T) {
 	equals := equalIgnoreReady(oldEndpoint, newEndpoint)
 	testutils.ExpectFalse(equals, t)
 }
+
+func TestCreateEndpointsCrWithAddressMetadata(t *testing.T) {
+	externalService := getTestExternalServiceCR()
+	externalService.Spec.Addresses = []esov1alpha1.ExternalServiceAddress{
+		esov1alpha1.ExternalServiceAddress{
+			IP:       "10.0.100.20",
+			Hostname: "db-0",
+			NodeName: "node-a",
+			Port:     5432,
+		},
+	}
+
+	endpoint := CreateEndpointsCr(externalService)
+
+	testutils.ExpectEqInt(int32(len(endpoint.Subsets)), 2, t)
+	testutils.ExpectEqInt(endpoint.Subsets[0].Ports[0].Port, 80, t)
+	testutils.ExpectEqInt(int32(len(endpoint.Subsets[0].NotReadyAddresses)), 3, t)
+
+	testutils.ExpectEqInt(endpoint.Subsets[1].Ports[0].Port, 5432, t)
+	testutils.ExpectEqStr(endpoint.Subsets[1].NotReadyAddresses[0].IP, "10.0.100.20", t)
+	testutils.ExpectEqStr(endpoint.Subsets[1].NotReadyAddresses[0].Hostname, "db-0", t)
+	testutils.ExpectEqStr(*endpoint.Subsets[1].NotReadyAddresses[0].NodeName, "node-a", t)
+}
+
+func TestMergeEndpointWithExternalServiceDef_MoveReadyIpToOtherPort(t *testing.T) {
+	externalService := getTestExternalServiceCR()
+	oldEndpoint := CreateEndpointsCr(externalService)
+	// 10.0.100.10 became ready
+	oldEndpoint.Subsets[0].Addresses = oldEndpoint.Subsets[0].NotReadyAddresses[:1]
+	oldEndpoint.Subsets[0].NotReadyAddresses = oldEndpoint.Subsets[0].NotReadyAddresses[1:]
+
+	externalService.Spec.Ips = []string{"10.0.100.11", "10.0.100.12"}
+	externalService.Spec.Addresses = []esov1alpha1.ExternalServiceAddress{
+		esov1alpha1.ExternalServiceAddress{IP: "10.0.100.10", Port: 8080, Hostname: "backend-0"},
+	}
+
+	actualEndpoint, changed := mergeEndpointWithExternalServiceDef(externalService, oldEndpoint)
+
+	testutils.ExpectTrue(changed, t)
+	testutils.ExpectEqInt(int32(len(actualEndpoint.Subsets)), 2, t)
+	testutils.ExpectEqInt(int32(len(actualEndpoint.Subsets[0].Addresses)), 0, t)
+	testutils.ExpectEqInt(actualEndpoint.Subsets[1].Ports[0].Port, 8080, t)
+	// the ready state is kept
+	testutils.ExpectEqStr(actualEndpoint.Subsets[1].Addresses[0].IP, "10.0.100.10", t)
+	testutils.ExpectEqStr(actualEndpoint.Subsets[1].Addresses[0].Hostname, "backend-0", t)
+}
+
+func TestCreateEndpointsCrWithDiscoveredAddresses(t *testing.T) {
+	instance := getTestExternalServiceCR()
+	instance.Spec.Sources = []esov1alpha1.AddressSource{
+		esov1alpha1.AddressSource{Name: "ldap", DNSSRV: &esov1alpha1.DNSSRVSource{Name: "_ldap._tcp.example.com"}},
+	}
+	instance.Status.Sources = []esov1alpha1.AddressSourceStatus{
+		esov1alpha1.AddressSourceStatus{
+			Name: "ldap",
+			Addresses: []esov1alpha1.ExternalServiceAddress{
+				esov1alpha1.ExternalServiceAddress{IP: "10.0.100.10", Port: 389},
+				esov1alpha1.ExternalServiceAddress{IP: "10.0.200.10", Port: 389},
+			},
+		},
+	}
+
+	endpoint := CreateEndpointsCr(instance)
+
+	// static addresses win over discovered ones with the same IP
+	testutils.ExpectEqInt(int32(len(endpoint.Subsets)), 2, t)
+	testutils.ExpectEqInt(int32(len(endpoint.Subsets[0].NotReadyAddresses)), 3, t)
+	testutils.ExpectEqStr(endpoint.Subsets[1].NotReadyAddresses[0].IP, "10.0.200.10", t)
+	testutils.ExpectEqInt(endpoint.Subsets[1].Ports[0].Port, 389, t)
+}
@@ -2,15 +2,22 @@ package externalservice
 
 import (
 	"context"
+	"reflect"
 
 	esov1alpha1 "github.com/CrowdfoxGmbH/external-service-operator/pkg/apis/eso/v1alpha1"
 	corev1 "k8s.io/api/core/v1"
 	extv1 "k8s.io/api/extensions/v1beta1"
 
 	"github.com/CrowdfoxGmbH/external-service-operator/pkg/prober"
+	"github.com/CrowdfoxGmbH/external-service-operator/pkg/sources"
 
+	"github.com/go-logr/logr"
 	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller"
 	"sigs.k8s.io/controller-runtime/pkg/handler"
@@ -25,18 +32,31 @@ var log = logf.Log.WithName("controller_externalservice")
 // Add creates a new ExternalService Controller and adds it to the Manager. The Manager will set fields on the Controller
 // and Start it when the Manager is Started.
 func Add(mgr manager.Manager) error {
-	return add(mgr, newReconciler(mgr))
+	r, err := newReconciler(mgr)
+	if err != nil {
+		return err
+	}
+	return add(mgr, r)
 }
 
 // newReconciler returns a new reconcile.Reconciler
-func newReconciler(mgr manager.Manager) reconcile.Reconciler {
+func newReconciler(mgr manager.Manager) (reconcile.Reconciler, error) {
+	// Sources read ConfigMaps and Secrets straight from the apiserver, reading them through
+	// the manager client would cache all of them
+	sourceClient, err := client.New(mgr.GetConfig(), client.Options{Scheme: mgr.GetScheme(), Mapper: mgr.GetRESTMapper()})
+	if err != nil {
+		return nil, err
+	}
 	client := mgr.GetClient()
 
 	return &ReconcileExternalService{
-		client:       client,
-		scheme:       mgr.GetScheme(),
-		probeManager: prober.NewProber(client),
-	}
+		client:        client,
+		sourceClient:  sourceClient,
+		scheme:        mgr.GetScheme(),
+		recorder:      mgr.GetRecorder("externalservice-controller"),
+		probeManager:  prober.NewProber(client),
+		sourceManager: sources.NewSourceManager(sourceClient),
+	}, nil
 }
 
 // add adds a new Controller to mgr with r as the reconcile.Reconciler
@@ -77,6 +97,39 @@ func add(mgr manager.Manager, r reconcile.Reconciler) error {
 		return err
 	}
 
+	// Nodes are watched for address sources using them
+	err = c.Watch(&source.Kind{Type: &corev1.Node{}}, &handler.EnqueueRequestsFromMapFunc{
+		ToRequests: enqueueForSources(mgr.GetClient(), matchesNodes),
+	})
+	if err != nil {
+		return err
+	}
+
+	// ConfigMaps and Secrets are watched for file sources reading them
+	if err := watchSourceObjects(mgr, c); err != nil {
+		return err
+	}
+
+	// Gateway API, Istio, Traefik and Contour are optional, their kinds are only watched if they are installed
+	optionalGVKs := append(append([]schema.GroupVersionKind{}, gatewayRouteGVKs...), istioGVKs...)
+	optionalGVKs = append(optionalGVKs, traefikIngressRouteGVK, contourHTTPProxyGVK)
+	for _, gvk := range optionalGVKs {
+		if _, err := mgr.GetRESTMapper().RESTMapping(gvk.GroupKind(), gvk.Version); err != nil {
+			log.Info("Not watching kind, it is not installed", "kind", gvk.String())
+			continue
+		}
+
+		object := &unstructured.Unstructured{}
+		object.SetGroupVersionKind(gvk)
+		err = c.Watch(&source.Kind{Type: object}, &handler.EnqueueRequestForOwner{
+			IsController: true,
+			OwnerType:    &esov1alpha1.ExternalService{},
+		})
+		if err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
@@ -87,9 +140,13 @@ var _ reconcile.Reconciler = &ReconcileExternalService{}
 type ReconcileExternalService struct {
 	// This client, initialized using mgr.Client() above, is a split client
 	// that reads objects from the cache and writes to the apiserver
-	client       client.Client
-	scheme       *runtime.Scheme
-	probeManager *prober.ProbeManager
+	client client.Client
+	// sourceClient reads from the apiserver directly, address sources read ConfigMaps and Secrets with it
+	sourceClient  client.Client
+	scheme        *runtime.Scheme
+	recorder      record.EventRecorder
+	probeManager  *prober.ProbeManager
+	sourceManager *sources.SourceManager
 }
 
 // Reconcile reads that state of the cluster for a ExternalService object and makes changes based on the state read
@@ -108,23 +165,141 @@ func (r *ReconcileExternalService) Reconcile(request reconcile.Request) (reconci
 		if errors.IsNotFound(err) {
 			reqLogger.Info("ExternalService got removed")
 			r.probeManager.RemoveProbesByNamespacedName(request.NamespacedName)
+			r.sourceManager.RemoveSources(request.NamespacedName)
 			return reconcile.Result{}, nil
 		}
 		// Error reading the object - requeue the request.
 		return reconcile.Result{}, err
 	}
 
+	if deleted, err := r.reconcileFinalizer(instance, reqLogger); deleted || err != nil {
+		return reconcile.Result{}, err
+	}
+
+	// the reconcilers only change the status, it is written once at the end
+	status := instance.Status.DeepCopy()
+	result, err := r.reconcileInstance(instance, reqLogger)
+	if !reflect.DeepEqual(status, &instance.Status) {
+		reqLogger.Info("Updating status of ExternalService")
+		if updateErr := r.client.Status().Update(context.TODO(), instance); updateErr != nil {
+			if err == nil {
+				err = updateErr
+			}
+			return reconcile.Result{}, err
+		}
+		r.recordDrainEvents(instance, status.DrainedAddresses, reqLogger)
+	}
+	return result, err
+}
+
+// reconcileInstance reconciles all objects of the ExternalService and updates its status in memory
+func (r *ReconcileExternalService) reconcileInstance(instance *esov1alpha1.ExternalService, reqLogger logr.Logger) (reconcile.Result, error) {
+	overrideResult, err := r.reconcileReadinessOverrides(instance, reqLogger)
+	if err != nil {
+		return overrideResult, err
+	}
+
+	conflicts, err := r.reconcileAdoption(instance, reqLogger)
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+	if len(conflicts) > 0 && instance.Spec.GetAdoptionPolicy() == esov1alpha1.AdoptionPolicyFail {
+		reqLogger.Info("Existing objects are not owned by the ExternalService, stop reconciling", "conflicts", conflicts)
+		r.probeManager.RemoveProbes(instance)
+		return reconcile.Result{RequeueAfter: conflictRequeueInterval}, nil
+	}
+	conflictResult := reconcile.Result{}
+	if len(conflicts) > 0 {
+		conflictResult.RequeueAfter = conflictRequeueInterval
+	}
+
+	if instance.Spec.IsExternalName() {
+		return r.reconcileExternalName(instance, reqLogger)
+	}
+
+	if result, err := r.reconcileRanges(instance, reqLogger); err != nil {
+		return result, err
+	}
+	sourceResult, err := r.reconcileSources(instance, reqLogger)
+	if err != nil {
+		return sourceResult, err
+	}
+	rolloutResult, err := r.reconcileRollout(instance, reqLogger)
+	if err != nil {
+		return rolloutResult, err
+	}
+	terminatingResult, err := r.reconcileTerminatingAddresses(instance, reqLogger)
+	if err != nil {
+		return terminatingResult, err
+	}
 	if result, err := r.reconcileEndpoints(instance, reqLogger); err != nil {
 		return result, err
 	}
 	if result, err := r.reconcileService(instance, reqLogger); err != nil {
 		return result, err
 	}
-	if result, err := r.reconcileIngress(instance, reqLogger); err != nil {
+	if result, err := r.reconcileTrafficSplit(instance, reqLogger); err != nil {
+		return result, err
+	}
+	if result, err := r.reconcileRoutes(instance, reqLogger); err != nil {
 		return result, err
 	}
+	if result, err := r.reconcileGatewayRoutes(instance, reqLogger); err != nil {
+		return result, err
+	}
+	if result, err := r.reconcileIstio(instance, reqLogger); err != nil {
+		return result, err
+	}
+	if result, err := r.reconcileDrainedAddresses(instance, reqLogger); err != nil {
+		return result, err
+	}
+
+	// the probes would change the addresses of Endpoints the ExternalService does not own
+	if conflicts["Endpoints"] {
+		r.probeManager.RemoveProbes(instance)
+	} else {
+		r.probeManager.UpdateProbes(instance)
+	}
 
-	r.probeManager.UpdateProbes(instance)
+	// requeue when the next readiness override expires, the rollout has to be checked, the
+	// next terminating address can be deleted or a conflict may be gone
+	return requeueFirst(overrideResult, sourceResult, rolloutResult, terminatingResult, conflictResult), nil
+}
 
+// requeueFirst returns the result which requeues first
+func requeueFirst(results ...reconcile.Result) reconcile.Result {
+	first := reconcile.Result{}
+	for _, result := range results {
+		if first.RequeueAfter == 0 || result.RequeueAfter != 0 && result.RequeueAfter < first.RequeueAfter {
+			first = result
+		}
+	}
+	return first
+}
+
+// reconcileExternalName reconciles an ExternalService whose Service points to a DNS name.
+// Such a Service has no Endpoints, so there is nothing to probe either.
+func (r *ReconcileExternalService) reconcileExternalName(instance *esov1alpha1.ExternalService, reqLogger logr.Logger) (reconcile.Result, error) {
+	r.probeManager.RemoveProbes(instance)
+	r.sourceManager.RemoveSources(types.NamespacedName{Name: instance.Name, Namespace: instance.Namespace})
+
+	if result, err := r.removeEndpoints(instance, reqLogger); err != nil {
+		return result, err
+	}
+	if result, err := r.reconcileService(instance, reqLogger); err != nil {
+		return result, err
+	}
+	if result, err := r.reconcileTrafficSplit(instance, reqLogger); err != nil {
+		return result, err
+	}
+	if result, err := r.reconcileRoutes(instance, reqLogger); err != nil {
+		return result, err
+	}
+	if result, err := r.reconcileGatewayRoutes(instance, reqLogger); err != nil {
+		return result, err
+	}
+	if result, err := r.reconcileIstio(instance, reqLogger); err != nil {
+		return result, err
+	}
 	return reconcile.Result{}, nil
 }
@@ -2,7 +2,11 @@ package externalservice
 
 import (
 	esov1alpha1 "github.com/CrowdfoxGmbH/external-service-operator/pkg/apis/eso/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	extv1 "k8s.io/api/extensions/v1beta1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 
 	"github.com/CrowdfoxGmbH/external-service-operator/pkg/testutils"
 	"k8s.io/apimachinery/pkg/api/errors"
@@ -167,6 +171,9 @@ func TestReconcileIngressWithoutHost(t *testing.T) {
 func TestReconcileIngressDeleteRSWhenHostsAreRemoved(t *testing.T) {
 	instance := getTestExternalServiceCR()
 	oldIngress := createIngressCr(instance.DeepCopy())
+	if err := controllerutil.SetControllerReference(instance, oldIngress, scheme.Scheme); err != nil {
+		t.Fatalf("set owner reference: (%v)", err)
+	}
 	instance.Spec.Hosts = []esov1alpha1.ExternalServiceHostPath{}
 
 	client := testutils.InitFakeClient(instance, oldIngress)
@@ -210,3 +217,165 @@ func TestReconcileIngressAddHostpathAndRemoveAnnotation(t *testing.T) {
 	testutils.ExpectEqStr(actualIngress.ResourceVersion, "2019", t)
 	testutils.ExpectEqInt(int32(len(actualIngress.ObjectMeta.OwnerReferences)), 1, t)
 }
+
+func TestReconcileServiceRecreatesOnClusterIPChange(t *testing.T) {
+	// Given a headless Service
+	instance := getTestExternalServiceCR()
+	oldService := createServiceCr(instance)
+	oldService.ResourceVersion = "1337"
+	client := testutils.InitFakeClient(instance, oldService)
+
+	// When I ask for a fixed virtual IP
+	instance.Spec.Service = &esov1alpha1.ServiceConfig{ClusterIP: "10.96.0.50"}
+	updateObject(client, instance)
+
+	res, err := runTestReconcile(client, instance.Name, instance.Namespace)
+	testutils.ExpectNoErrorsAndRequeue(res, err, t)
+
+	// Then the Service is deleted first
+	if _, err := getRuntimeService(client, instance.Name, instance.Namespace); !errors.IsNotFound(err) {
+		t.Fatalf("Expected Service to be deleted, got (%v)", err)
+	}
+
+	// And recreated with the virtual IP by the requeued reconcile once it is gone
+	res, err = runTestReconcile(client, instance.Name, instance.Namespace)
+	testutils.ExpectNoErrorsAndRequeue(res, err, t)
+	actualService, err := getRuntimeService(client, instance.Name, instance.Namespace)
+	if err != nil {
+		t.Fatalf("get Service: (%v)", err)
+	}
+
+	testutils.ExpectEqStr(actualService.Spec.ClusterIP, "10.96.0.50", t)
+	testutils.ExpectTrue(actualService.ResourceVersion != "1337", t)
+	testutils.ExpectServiceOwnerReference(actualService, instance, t)
+}
+
+func TestReconcileExternalNameRemovesEndpoints(t *testing.T) {
+	// Given an ExternalService with Endpoints
+	instance := getTestExternalServiceCR()
+	client := testutils.InitFakeClient(instance)
+	res, err := runTestReconcile(client, instance.Name, instance.Namespace)
+	testutils.ExpectNoErrorsAndRequeue(res, err, t)
+
+	// When it is switched to an ExternalName Service
+	instance, _ = getRuntimeExternalService(client, instance.Name, instance.Namespace)
+	instance.Spec.Service = &esov1alpha1.ServiceConfig{
+		Type:         "ExternalName",
+		ExternalName: "db.example.com",
+	}
+	updateObject(client, instance)
+
+	res, err = runTestReconcile(client, instance.Name, instance.Namespace)
+	testutils.ExpectNoErrorsAndRequeue(res, err, t)
+
+	// Then the Endpoint is gone and the Service points to the DNS name
+	found, err := getRuntimeEndpoint(client, instance.Name, instance.Namespace)
+	if err == nil || !errors.IsNotFound(err) {
+		t.Fatalf("Expected that Endpoint was deleted, but found %v", found)
+	}
+
+	actualService, err := getRuntimeService(client, instance.Name, instance.Namespace)
+	if err != nil {
+		t.Fatalf("get Service: (%v)", err)
+	}
+	testutils.ExpectEqStr(actualService.Spec.ExternalName, "db.example.com", t)
+	testutils.ExpectEqStr(actualService.Spec.ClusterIP, "", t)
+}
+
+func TestReconcileServiceSkipsUpdateOfDefaultedService(t *testing.T) {
+	// Given a Service which got defaulted by the API server
+	instance := getTestExternalServiceCR()
+	instance.Spec.Service = &esov1alpha1.ServiceConfig{Type: corev1.ServiceTypeNodePort}
+	client := testutils.InitFakeClient(instance, defaultService(instance, createServiceCr(instance)))
+
+	// When it is reconciled
+	res, err := newTestReconciler(client).reconcileService(instance, log)
+
+	// Then it is not updated
+	if err != nil {
+		t.Fatalf("reconcile: (%v)", err)
+	}
+	testutils.ExpectFalse(res.Requeue, t)
+
+	actualService, err := getRuntimeService(client, instance.Name, instance.Namespace)
+	if err != nil {
+		t.Fatalf("get Service: (%v)", err)
+	}
+	testutils.ExpectEqStr(actualService.ResourceVersion, "4242", t)
+}
+
+func TestReconcileIngressKeepsForeignAnnotationsAndTLS(t *testing.T) {
+	// Given an Ingress which another controller added annotations and TLS to
+	instance := getTestExternalServiceCR()
+	instance.Spec.Ingress = &esov1alpha1.IngressConfig{Annotations: map[string]string{"foo.bar": "testvalue"}}
+	foundIngress := createIngressCr(instance)
+	controllerutil.SetControllerReference(instance, foundIngress, scheme.Scheme)
+	foundIngress.ResourceVersion = "2019"
+	foundIngress.Annotations["cert-manager.io/cluster-issuer"] = "letsencrypt"
+	foundIngress.Spec.TLS = []extv1.IngressTLS{extv1.IngressTLS{Hosts: []string{"subdomain.example.com"}, SecretName: "subdomain-tls"}}
+	foundIngress.Status.LoadBalancer.Ingress = []corev1.LoadBalancerIngress{corev1.LoadBalancerIngress{IP: "203.0.113.10"}}
+	client := testutils.InitFakeClient(instance, foundIngress)
+
+	// When nothing changed it is not updated
+	res, err := newTestReconciler(client).reconcileIngress(instance, log)
+	if err != nil {
+		t.Fatalf("reconcile: (%v)", err)
+	}
+	testutils.ExpectFalse(res.Requeue, t)
+
+	// And when I change a configured annotation
+	instance.Spec.Ingress.Annotations["foo.bar"] = "changed"
+	res, err = newTestReconciler(client).reconcileIngress(instance, log)
+	if err != nil {
+		t.Fatalf("reconcile: (%v)", err)
+	}
+
+	// Then only the managed annotation changes
+	actualIngress, err := getRuntimeIngress(client, instance.Name, instance.Namespace)
+	if err != nil {
+		t.Fatalf("get Ingress: (%v)", err)
+	}
+	testutils.ExpectEqStr(actualIngress.Annotations["foo.bar"], "changed", t)
+	testutils.ExpectEqStr(actualIngress.Annotations["cert-manager.io/cluster-issuer"], "letsencrypt", t)
+	testutils.ExpectEqStr(actualIngress.Spec.TLS[0].SecretName, "subdomain-tls", t)
+}
+
+func TestReconcileServiceLabelsReachServiceAndEndpoints(t *testing.T) {
+	// Given an ExternalService with configured Service labels
+	instance := getTestExternalServiceCR()
+	instance.Spec.Service = &esov1alpha1.ServiceConfig{
+		Labels:      map[string]string{"team": "payments"},
+		Annotations: map[string]string{"prometheus.io/scrape": "true"},
+	}
+	client := testutils.InitFakeClient(instance)
+	res, err := runTestReconcile(client, instance.Name, instance.Namespace)
+	testutils.ExpectNoErrorsAndRequeue(res, err, t)
+
+	actualService, _ := getRuntimeService(client, instance.Name, instance.Namespace)
+	testutils.ExpectEqStr(actualService.Labels["team"], "payments", t)
+	testutils.ExpectEqStr(actualService.Labels["app"], "TestService", t)
+	testutils.ExpectEqStr(actualService.Annotations["prometheus.io/scrape"], "true", t)
+	actualEndpoint, _ := getRuntimeEndpoint(client, instance.Name, instance.Namespace)
+	testutils.ExpectEqStr(actualEndpoint.Labels["team"], "payments", t)
+
+	// When another controller labels the Service and I remove my label
+	actualService.Labels["owner"] = "someone-else"
+	updateObject(client, actualService)
+	instance, _ = getRuntimeExternalService(client, instance.Name, instance.Namespace)
+	instance.Spec.Service.Labels = nil
+	updateObject(client, instance)
+
+	res, err = runTestReconcile(client, instance.Name, instance.Namespace)
+	testutils.ExpectNoErrorsAndRequeue(res, err, t)
+
+	// Then only my label is gone
+	actualService, _ = getRuntimeService(client, instance.Name, instance.Namespace)
+	_, found := actualService.Labels["team"]
+	testutils.ExpectFalse(found, t)
+	testutils.ExpectEqStr(actualService.Labels["owner"], "someone-else", t)
+	testutils.ExpectEqStr(actualService.Labels["app"], "TestService", t)
+
+	actualEndpoint, _ = getRuntimeEndpoint(client, instance.Name, instance.Namespace)
+	_, found = actualEndpoint.Labels["team"]
+	testutils.ExpectFalse(found, t)
+}
@@ -0,0 +1,146 @@
+package externalservice
+
+import (
+	"context"
+
+	esov1alpha1 "github.com/CrowdfoxGmbH/external-service-operator/pkg/apis/eso/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	extv1 "k8s.io/api/extensions/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// cleanupFinalizer keeps a deleted ExternalService until its probes and sources are stopped
+const cleanupFinalizer = "eso.crowdfox.com/cleanup"
+
+// reconcileFinalizer adds the cleanup finalizer to the ExternalService. Once the ExternalService
+// is deleted, its probes and sources are stopped and, with the Orphan deletion policy, the owner
+// references are removed from the created objects before the finalizer is removed. Returns true
+// if the ExternalService is deleted.
+func (r *ReconcileExternalService) reconcileFinalizer(instance *esov1alpha1.ExternalService, reqLogger logr.Logger) (bool, error) {
+	if instance.DeletionTimestamp == nil {
+		if hasFinalizer(instance) {
+			return false, nil
+		}
+		reqLogger.Info("Adding finalizer")
+		instance.Finalizers = append(instance.Finalizers, cleanupFinalizer)
+		return false, r.client.Update(context.TODO(), instance)
+	}
+
+	if !hasFinalizer(instance) {
+		return true, nil
+	}
+
+	reqLogger.Info("ExternalService is deleted, stopping probes and sources")
+	r.probeManager.RemoveProbes(instance)
+	r.sourceManager.RemoveSources(types.NamespacedName{Name: instance.Name, Namespace: instance.Namespace})
+
+	message := "ExternalService deleted, probes and sources are stopped"
+	if instance.Spec.DeletionPolicy == esov1alpha1.DeletionPolicyOrphan {
+		if err := r.orphanObjects(instance, reqLogger); err != nil {
+			return true, err
+		}
+		message = "ExternalService deleted, probes and sources are stopped, the created objects are kept"
+	}
+	r.recorder.Event(instance, corev1.EventTypeNormal, "Deleted", message)
+
+	finalizers := []string{}
+	for _, finalizer := range instance.Finalizers {
+		if finalizer != cleanupFinalizer {
+			finalizers = append(finalizers, finalizer)
+		}
+	}
+	instance.Finalizers = finalizers
+	return true, r.client.Update(context.TODO(), instance)
+}
+
+func hasFinalizer(instance *esov1alpha1.ExternalService) bool {
+	for _, finalizer := range instance.Finalizers {
+		if finalizer == cleanupFinalizer {
+			return true
+		}
+	}
+	return false
+}
+
+// orphanObjects removes the owner reference of the ExternalService from every object created
+// for it, so the garbage collector keeps them
+func (r *ReconcileExternalService) orphanObjects(instance *esov1alpha1.ExternalService, reqLogger logr.Logger) error {
+	names := []string{instance.Name}
+	for _, group := range instance.Status.TrafficSplit {
+		names = append(names, group.Service)
+	}
+	for _, name := range names {
+		if _, err := r.orphanObject(instance, &corev1.Service{}, "Service", name, reqLogger); err != nil {
+			return err
+		}
+		if _, err := r.orphanObject(instance, &corev1.Endpoints{}, "Endpoints", name, reqLogger); err != nil {
+			return err
+		}
+	}
+	for _, name := range []string{instance.Name, canaryIngressName(instance)} {
+		if _, err := r.orphanObject(instance, &extv1.Ingress{}, "Ingress", name, reqLogger); err != nil {
+			return err
+		}
+	}
+
+	for _, gvk := range append(append([]schema.GroupVersionKind{}, gatewayRouteGVKs...), istioGVKs...) {
+		if _, err := r.orphanObject(instance, emptyUnstructured(gvk), gvk.Kind, instance.Name, reqLogger); err != nil {
+			return err
+		}
+	}
+	for _, gvk := range []schema.GroupVersionKind{traefikIngressRouteGVK, contourHTTPProxyGVK} {
+		for index := 0; ; index++ {
+			existed, err := r.orphanObject(instance, emptyUnstructured(gvk), gvk.Kind, routeName(instance, index), reqLogger)
+			if err != nil {
+				return err
+			}
+			if !existed {
+				break
+			}
+		}
+	}
+	return nil
+}
+
+// orphanObject removes the owner reference of the ExternalService from the named object. It
+// returns whether the object existed. Kinds which are not installed in the cluster are ignored.
+func (r *ReconcileExternalService) orphanObject(instance *esov1alpha1.ExternalService, object runtime.Object, kind string, name string, reqLogger logr.Logger) (bool, error) {
+	err := r.client.Get(context.TODO(), types.NamespacedName{Name: name, Namespace: instance.Namespace}, object)
+	if errors.IsNotFound(err) || meta.IsNoMatchError(err) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+
+	accessor, err := meta.Accessor(object)
+	if err != nil {
+		return true, err
+	}
+	if !metav1.IsControlledBy(accessor, instance) {
+		return true, nil
+	}
+
+	references := []metav1.OwnerReference{}
+	for _, reference := range accessor.GetOwnerReferences() {
+		if reference.UID != instance.UID {
+			references = append(references, reference)
+		}
+	}
+	accessor.SetOwnerReferences(references)
+	reqLogger.Info("Orphaning "+kind, "namespace", instance.Namespace, "name", name)
+	return true, r.client.Update(context.TODO(), object)
+}
+
+func emptyUnstructured(gvk schema.GroupVersionKind) *unstructured.Unstructured {
+	object := &unstructured.Unstructured{}
+	object.SetGroupVersionKind(gvk)
+	return object
+}
@@ -0,0 +1,95 @@
+package externalservice
+
+import (
+	"testing"
+
+	esov1alpha1 "github.com/CrowdfoxGmbH/external-service-operator/pkg/apis/eso/v1alpha1"
+	"github.com/CrowdfoxGmbH/external-service-operator/pkg/testutils"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// deleteTestExternalService marks the ExternalService as deleted, the fake client does not
+// wait for finalizers
+func deleteTestExternalService(client client.Client, name string, namespace string, t *testing.T) {
+	externalService, _ := getRuntimeExternalService(client, name, namespace)
+	now := metav1.Now()
+	externalService.DeletionTimestamp = &now
+	if err := updateObject(client, externalService); err != nil {
+		t.Fatalf("update external service: (%v)", err)
+	}
+}
+
+func TestReconcileAddsFinalizer(t *testing.T) {
+	instance := getTestExternalServiceCR()
+	client := testutils.InitFakeClient(instance)
+
+	if _, err := runTestReconcile(client, instance.Name, instance.Namespace); err != nil {
+		t.Fatalf("reconcile: (%v)", err)
+	}
+
+	externalService, _ := getRuntimeExternalService(client, instance.Name, instance.Namespace)
+	testutils.ExpectEqInt(int32(len(externalService.Finalizers)), 1, t)
+	testutils.ExpectEqStr(externalService.Finalizers[0], cleanupFinalizer, t)
+}
+
+func TestReconcileDeletionRemovesFinalizer(t *testing.T) {
+	// Given a reconciled ExternalService
+	instance := getTestExternalServiceCR()
+	client := testutils.InitFakeClient(instance)
+	reconciler := newTestReconciler(client)
+	recorder := record.NewFakeRecorder(100)
+	reconciler.recorder = recorder
+	request := reconcile.Request{NamespacedName: types.NamespacedName{Name: instance.Name, Namespace: instance.Namespace}}
+	if _, err := reconciler.Reconcile(request); err != nil {
+		t.Fatalf("reconcile: (%v)", err)
+	}
+
+	// When it is deleted
+	deleteTestExternalService(client, instance.Name, instance.Namespace, t)
+	for len(recorder.Events) > 0 {
+		<-recorder.Events
+	}
+	if _, err := reconciler.Reconcile(request); err != nil {
+		t.Fatalf("reconcile: (%v)", err)
+	}
+
+	// Then the finalizer is removed
+	externalService, _ := getRuntimeExternalService(client, instance.Name, instance.Namespace)
+	testutils.ExpectEqInt(int32(len(externalService.Finalizers)), 0, t)
+	testutils.ExpectEqStr(<-recorder.Events, "Normal Deleted ExternalService deleted, probes and sources are stopped", t)
+
+	// and the created objects are still owned by it
+	service, _ := getRuntimeService(client, instance.Name, instance.Namespace)
+	testutils.ExpectTrue(metav1.IsControlledBy(service, externalService), t)
+}
+
+func TestReconcileDeletionOrphansObjects(t *testing.T) {
+	// Given a reconciled ExternalService with the Orphan deletion policy
+	instance := getTestExternalServiceCR()
+	instance.Spec.DeletionPolicy = esov1alpha1.DeletionPolicyOrphan
+	client := testutils.InitFakeClient(instance)
+	if _, err := runTestReconcile(client, instance.Name, instance.Namespace); err != nil {
+		t.Fatalf("reconcile: (%v)", err)
+	}
+
+	// When it is deleted
+	deleteTestExternalService(client, instance.Name, instance.Namespace, t)
+	if _, err := runTestReconcile(client, instance.Name, instance.Namespace); err != nil {
+		t.Fatalf("reconcile: (%v)", err)
+	}
+
+	// Then the created objects are kept without owner reference
+	service, err := getRuntimeService(client, instance.Name, instance.Namespace)
+	if err != nil {
+		t.Fatalf("get service: (%v)", err)
+	}
+	testutils.ExpectEqInt(int32(len(service.OwnerReferences)), 0, t)
+	endpoint, _ := getRuntimeEndpoint(client, instance.Name, instance.Namespace)
+	testutils.ExpectEqInt(int32(len(endpoint.OwnerReferences)), 0, t)
+	ingress, _ := getRuntimeIngress(client, instance.Name, instance.Namespace)
+	testutils.ExpectEqInt(int32(len(ingress.OwnerReferences)), 0, t)
+}
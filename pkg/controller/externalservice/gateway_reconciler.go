@@ -0,0 +1,246 @@
+package externalservice
+
+import (
+	esov1alpha1 "github.com/CrowdfoxGmbH/external-service-operator/pkg/apis/eso/v1alpha1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	"github.com/go-logr/logr"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+const gatewayGroup = "gateway.networking.k8s.io"
+
+var (
+	httpRouteGVK = schema.GroupVersionKind{Group: gatewayGroup, Version: "v1", Kind: "HTTPRoute"}
+	tlsRouteGVK  = schema.GroupVersionKind{Group: gatewayGroup, Version: "v1alpha2", Kind: "TLSRoute"}
+	tcpRouteGVK  = schema.GroupVersionKind{Group: gatewayGroup, Version: "v1alpha2", Kind: "TCPRoute"}
+
+	// gatewayRouteGVKs are all kinds of routes the operator creates
+	gatewayRouteGVKs = []schema.GroupVersionKind{httpRouteGVK, tlsRouteGVK, tcpRouteGVK}
+)
+
+func (r *ReconcileExternalService) reconcileGatewayRoutes(instance *esov1alpha1.ExternalService, reqLogger logr.Logger) (reconcile.Result, error) {
+	var route *unstructured.Unstructured
+	if instance.Spec.Gateway != nil {
+		route = createGatewayRouteCr(instance)
+	}
+
+	// only one kind of route exists at a time, routes of other kinds are left over from an earlier protocol
+	for _, gvk := range gatewayRouteGVKs {
+		if route != nil && route.GroupVersionKind() == gvk {
+			if result, err := r.reconcileUnstructured(instance, route, reqLogger); err != nil {
+				return result, err
+			}
+			continue
+		}
+
+		if err := r.deleteUnstructured(instance, gvk, reqLogger); err != nil {
+			return reconcile.Result{}, err
+		}
+	}
+
+	return reconcile.Result{}, nil
+}
+
+// createGatewayRouteCr creates the route of the configured protocol. The values are defaulted
+// like the API server does, so the route does not differ from the stored one.
+func createGatewayRouteCr(i *esov1alpha1.ExternalService) *unstructured.Unstructured {
+	config := i.Spec.Gateway
+	backendRefs := []interface{}{}
+	backends := splitBackends(i)
+	if backends == nil {
+		backends = []splitBackend{splitBackend{service: i.Name, weight: 1}}
+	}
+	for _, backend := range backends {
+		backendRefs = append(backendRefs, map[string]interface{}{
+			"group":  "",
+			"kind":   "Service",
+			"name":   backend.service,
+			"port":   int64(i.Spec.Port),
+			"weight": backend.weight,
+		})
+	}
+
+	spec := map[string]interface{}{
+		"parentRefs": gatewayParentRefs(i),
+	}
+
+	switch config.Protocol {
+	case esov1alpha1.GatewayProtocolTCP:
+		spec["rules"] = []interface{}{map[string]interface{}{"backendRefs": backendRefs}}
+		return newUnstructured(i, tcpRouteGVK, spec)
+	case esov1alpha1.GatewayProtocolTLS:
+		setHostnames(spec, i)
+		spec["rules"] = []interface{}{map[string]interface{}{"backendRefs": backendRefs}}
+		return newUnstructured(i, tlsRouteGVK, spec)
+	default:
+		setHostnames(spec, i)
+		rules := []interface{}{}
+		for _, rule := range gatewayHTTPRules(i) {
+			rules = append(rules, httpRouteRule(rule, backendRefs))
+		}
+		spec["rules"] = rules
+		return newUnstructured(i, httpRouteGVK, spec)
+	}
+}
+
+func gatewayParentRefs(i *esov1alpha1.ExternalService) []interface{} {
+	parentRefs := []interface{}{}
+	for _, ref := range i.Spec.Gateway.ParentRefs {
+		namespace := ref.Namespace
+		if namespace == "" {
+			namespace = i.Namespace
+		}
+
+		parentRef := map[string]interface{}{
+			"group":     gatewayGroup,
+			"kind":      "Gateway",
+			"name":      ref.Name,
+			"namespace": namespace,
+		}
+		if ref.SectionName != "" {
+			parentRef["sectionName"] = ref.SectionName
+		}
+		if ref.Port != 0 {
+			parentRef["port"] = int64(ref.Port)
+		}
+		parentRefs = append(parentRefs, parentRef)
+	}
+	return parentRefs
+}
+
+// setHostnames sets the configured hostnames or the hosts of spec.hosts
+func setHostnames(spec map[string]interface{}, i *esov1alpha1.ExternalService) {
+	hostnames := i.Spec.Gateway.Hostnames
+	if len(hostnames) == 0 {
+		hostnames = distinctHosts(i)
+	}
+
+	if len(hostnames) > 0 {
+		spec["hostnames"] = stringSlice(hostnames)
+	}
+}
+
+// gatewayHTTPRules returns the configured rules or one rule per path of spec.hosts
+func gatewayHTTPRules(i *esov1alpha1.ExternalService) []esov1alpha1.GatewayHTTPRouteRule {
+	if len(i.Spec.Gateway.Rules) > 0 {
+		return i.Spec.Gateway.Rules
+	}
+
+	rules := []esov1alpha1.GatewayHTTPRouteRule{}
+	known := map[string]bool{}
+	for _, hostpath := range i.Spec.Hosts {
+		if known[hostpath.Path] {
+			continue
+		}
+		known[hostpath.Path] = true
+
+		rules = append(rules, esov1alpha1.GatewayHTTPRouteRule{
+			Matches: []esov1alpha1.GatewayHTTPRouteMatch{
+				esov1alpha1.GatewayHTTPRouteMatch{Path: &esov1alpha1.GatewayHTTPPathMatch{Value: hostpath.Path}},
+			},
+		})
+	}
+
+	if len(rules) == 0 {
+		rules = append(rules, esov1alpha1.GatewayHTTPRouteRule{})
+	}
+	return rules
+}
+
+func httpRouteRule(rule esov1alpha1.GatewayHTTPRouteRule, backendRefs []interface{}) map[string]interface{} {
+	matches := []interface{}{}
+	for _, match := range rule.Matches {
+		matches = append(matches, httpRouteMatch(match))
+	}
+	if len(matches) == 0 {
+		matches = append(matches, httpRouteMatch(esov1alpha1.GatewayHTTPRouteMatch{}))
+	}
+
+	result := map[string]interface{}{
+		"matches":     matches,
+		"backendRefs": runtime.DeepCopyJSONValue(backendRefs),
+	}
+
+	if len(rule.Filters) > 0 {
+		filters := []interface{}{}
+		for _, filter := range rule.Filters {
+			filters = append(filters, httpRouteFilter(filter))
+		}
+		result["filters"] = filters
+	}
+	return result
+}
+
+func httpRouteMatch(match esov1alpha1.GatewayHTTPRouteMatch) map[string]interface{} {
+	path := map[string]interface{}{"type": "PathPrefix", "value": "/"}
+	if match.Path != nil {
+		if match.Path.Type != "" {
+			path["type"] = match.Path.Type
+		}
+		if match.Path.Value != "" {
+			path["value"] = match.Path.Value
+		}
+	}
+
+	result := map[string]interface{}{"path": path}
+	if len(match.Headers) > 0 {
+		headers := []interface{}{}
+		for _, header := range match.Headers {
+			headerType := header.Type
+			if headerType == "" {
+				headerType = "Exact"
+			}
+			headers = append(headers, map[string]interface{}{
+				"type":  headerType,
+				"name":  header.Name,
+				"value": header.Value,
+			})
+		}
+		result["headers"] = headers
+	}
+	return result
+}
+
+func httpRouteFilter(filter esov1alpha1.GatewayHTTPRouteFilter) map[string]interface{} {
+	result := map[string]interface{}{"type": filter.Type}
+	if filter.RequestHeaderModifier != nil {
+		result["requestHeaderModifier"] = httpHeaderFilter(filter.RequestHeaderModifier)
+	}
+	if filter.ResponseHeaderModifier != nil {
+		result["responseHeaderModifier"] = httpHeaderFilter(filter.ResponseHeaderModifier)
+	}
+	return result
+}
+
+func httpHeaderFilter(filter *esov1alpha1.GatewayHTTPHeaderFilter) map[string]interface{} {
+	result := map[string]interface{}{}
+	if len(filter.Set) > 0 {
+		result["set"] = httpHeaders(filter.Set)
+	}
+	if len(filter.Add) > 0 {
+		result["add"] = httpHeaders(filter.Add)
+	}
+	if len(filter.Remove) > 0 {
+		result["remove"] = stringSlice(filter.Remove)
+	}
+	return result
+}
+
+func httpHeaders(headers []esov1alpha1.GatewayHTTPHeader) []interface{} {
+	result := []interface{}{}
+	for _, header := range headers {
+		result = append(result, map[string]interface{}{"name": header.Name, "value": header.Value})
+	}
+	return result
+}
+
+func stringSlice(values []string) []interface{} {
+	result := []interface{}{}
+	for _, value := range values {
+		result = append(result, value)
+	}
+	return result
+}
@@ -0,0 +1,160 @@
+package externalservice
+
+import (
+	"testing"
+
+	esov1alpha1 "github.com/CrowdfoxGmbH/external-service-operator/pkg/apis/eso/v1alpha1"
+	"github.com/CrowdfoxGmbH/external-service-operator/pkg/testutils"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func getTestGatewayExternalServiceCR() *esov1alpha1.ExternalService {
+	instance := getTestExternalServiceCR()
+	instance.Spec.Gateway = &esov1alpha1.GatewayConfig{
+		ParentRefs: []esov1alpha1.GatewayParentRef{
+			esov1alpha1.GatewayParentRef{Name: "public", Namespace: "gateways", SectionName: "https"},
+		},
+	}
+	return instance
+}
+
+func TestCreateGatewayRouteCrDefaultsToHosts(t *testing.T) {
+	route := createGatewayRouteCr(getTestGatewayExternalServiceCR())
+
+	testutils.ExpectEqStr(route.GetKind(), "HTTPRoute", t)
+	testutils.ExpectEqStr(route.GetName(), "TestService", t)
+
+	hostnames, _, _ := unstructured.NestedStringSlice(route.Object, "spec", "hostnames")
+	testutils.ExpectEqInt(int32(len(hostnames)), 2, t)
+	testutils.ExpectEqStr(hostnames[1], "another.domain.com", t)
+
+	parentRefs, _, _ := unstructured.NestedSlice(route.Object, "spec", "parentRefs")
+	parentRef := parentRefs[0].(map[string]interface{})
+	testutils.ExpectEqStr(parentRef["namespace"].(string), "gateways", t)
+	testutils.ExpectEqStr(parentRef["sectionName"].(string), "https", t)
+
+	rules, _, _ := unstructured.NestedSlice(route.Object, "spec", "rules")
+	testutils.ExpectEqInt(int32(len(rules)), 2, t)
+	path, _, _ := unstructured.NestedStringMap(rules[0].(map[string]interface{})["matches"].([]interface{})[0].(map[string]interface{}), "path")
+	testutils.ExpectEqStr(path["type"], "PathPrefix", t)
+	testutils.ExpectEqStr(path["value"], "/", t)
+	path, _, _ = unstructured.NestedStringMap(rules[1].(map[string]interface{})["matches"].([]interface{})[0].(map[string]interface{}), "path")
+	testutils.ExpectEqStr(path["value"], "/foo", t)
+
+	backendRef := rules[0].(map[string]interface{})["backendRefs"].([]interface{})[0].(map[string]interface{})
+	testutils.ExpectEqStr(backendRef["name"].(string), "TestService", t)
+	testutils.ExpectEqInt(int32(backendRef["port"].(int64)), 80, t)
+}
+
+func TestCreateGatewayRouteCrWithRules(t *testing.T) {
+	instance := getTestGatewayExternalServiceCR()
+	instance.Spec.Gateway.Hostnames = []string{"api.example.com"}
+	instance.Spec.Gateway.Rules = []esov1alpha1.GatewayHTTPRouteRule{
+		esov1alpha1.GatewayHTTPRouteRule{
+			Matches: []esov1alpha1.GatewayHTTPRouteMatch{
+				esov1alpha1.GatewayHTTPRouteMatch{
+					Path:    &esov1alpha1.GatewayHTTPPathMatch{Type: "Exact", Value: "/v1"},
+					Headers: []esov1alpha1.GatewayHTTPHeaderMatch{esov1alpha1.GatewayHTTPHeaderMatch{Name: "X-Tenant", Value: "a"}},
+				},
+			},
+			Filters: []esov1alpha1.GatewayHTTPRouteFilter{
+				esov1alpha1.GatewayHTTPRouteFilter{
+					Type: "RequestHeaderModifier",
+					RequestHeaderModifier: &esov1alpha1.GatewayHTTPHeaderFilter{
+						Set:    []esov1alpha1.GatewayHTTPHeader{esov1alpha1.GatewayHTTPHeader{Name: "Host", Value: "backend.internal"}},
+						Remove: []string{"X-Debug"},
+					},
+				},
+			},
+		},
+	}
+
+	route := createGatewayRouteCr(instance)
+
+	hostnames, _, _ := unstructured.NestedStringSlice(route.Object, "spec", "hostnames")
+	testutils.ExpectEqInt(int32(len(hostnames)), 1, t)
+	testutils.ExpectEqStr(hostnames[0], "api.example.com", t)
+
+	rules, _, _ := unstructured.NestedSlice(route.Object, "spec", "rules")
+	rule := rules[0].(map[string]interface{})
+	match := rule["matches"].([]interface{})[0].(map[string]interface{})
+	testutils.ExpectEqStr(match["path"].(map[string]interface{})["type"].(string), "Exact", t)
+	header := match["headers"].([]interface{})[0].(map[string]interface{})
+	testutils.ExpectEqStr(header["type"].(string), "Exact", t)
+	testutils.ExpectEqStr(header["name"].(string), "X-Tenant", t)
+
+	filter := rule["filters"].([]interface{})[0].(map[string]interface{})
+	set, _, _ := unstructured.NestedSlice(filter, "requestHeaderModifier", "set")
+	testutils.ExpectEqStr(set[0].(map[string]interface{})["value"].(string), "backend.internal", t)
+	remove, _, _ := unstructured.NestedStringSlice(filter, "requestHeaderModifier", "remove")
+	testutils.ExpectEqStr(remove[0], "X-Debug", t)
+}
+
+func TestCreateGatewayRouteCrForTCPAndTLS(t *testing.T) {
+	instance := getTestGatewayExternalServiceCR()
+
+	instance.Spec.Gateway.Protocol = esov1alpha1.GatewayProtocolTCP
+	route := createGatewayRouteCr(instance)
+	testutils.ExpectEqStr(route.GetKind(), "TCPRoute", t)
+	_, found, _ := unstructured.NestedStringSlice(route.Object, "spec", "hostnames")
+	testutils.ExpectFalse(found, t)
+
+	instance.Spec.Gateway.Protocol = esov1alpha1.GatewayProtocolTLS
+	route = createGatewayRouteCr(instance)
+	testutils.ExpectEqStr(route.GetKind(), "TLSRoute", t)
+	hostnames, _, _ := unstructured.NestedStringSlice(route.Object, "spec", "hostnames")
+	testutils.ExpectEqInt(int32(len(hostnames)), 2, t)
+}
+
+func TestReconcileGatewayRoutesSwitchProtocol(t *testing.T) {
+	// Given an ExternalService with an HTTPRoute
+	instance := getTestGatewayExternalServiceCR()
+	client := testutils.InitFakeClient(instance)
+	res, err := runTestReconcile(client, instance.Name, instance.Namespace)
+	testutils.ExpectNoErrorsAndRequeue(res, err, t)
+
+	route, err := getRuntimeUnstructured(client, httpRouteGVK, instance.Name, instance.Namespace)
+	if err != nil {
+		t.Fatalf("get HTTPRoute: (%v)", err)
+	}
+	testutils.ExpectEqStr(route.GetOwnerReferences()[0].Name, "TestService", t)
+
+	// When the route is reconciled again it is not updated
+	res, err = newTestReconciler(client).reconcileGatewayRoutes(instance, log)
+	if err != nil {
+		t.Fatalf("reconcile: (%v)", err)
+	}
+	testutils.ExpectFalse(res.Requeue, t)
+
+	// When I switch to TCP
+	instance, _ = getRuntimeExternalService(client, instance.Name, instance.Namespace)
+	instance.Spec.Gateway.Protocol = esov1alpha1.GatewayProtocolTCP
+	updateObject(client, instance)
+	res, err = runTestReconcile(client, instance.Name, instance.Namespace)
+	testutils.ExpectNoErrorsAndRequeue(res, err, t)
+
+	// Then the HTTPRoute is replaced by a TCPRoute
+	if _, err := getRuntimeUnstructured(client, httpRouteGVK, instance.Name, instance.Namespace); !errors.IsNotFound(err) {
+		t.Errorf("Expected HTTPRoute to be deleted, but got %v", err)
+	}
+	if _, err := getRuntimeUnstructured(client, tcpRouteGVK, instance.Name, instance.Namespace); err != nil {
+		t.Errorf("get TCPRoute: (%v)", err)
+	}
+}
+
+func TestReconcileGatewayRoutesKeepsForeignRoute(t *testing.T) {
+	instance := getTestExternalServiceCR()
+	foreign := &unstructured.Unstructured{}
+	foreign.SetGroupVersionKind(httpRouteGVK)
+	foreign.SetName(instance.Name)
+	foreign.SetNamespace(instance.Namespace)
+	client := testutils.InitFakeClient(instance, foreign)
+
+	res, err := runTestReconcile(client, instance.Name, instance.Namespace)
+	testutils.ExpectNoErrorsAndRequeue(res, err, t)
+
+	if _, err := getRuntimeUnstructured(client, httpRouteGVK, instance.Name, instance.Namespace); err != nil {
+		t.Errorf("Expected HTTPRoute not owned by the ExternalService to be kept, but got %v", err)
+	}
+}
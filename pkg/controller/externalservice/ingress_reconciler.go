@@ -2,7 +2,7 @@ package externalservice
 
 import (
 	"context"
-	"reflect"
+	"strconv"
 	"time"
 
 	esov1alpha1 "github.com/CrowdfoxGmbH/external-service-operator/pkg/apis/eso/v1alpha1"
@@ -10,6 +10,7 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
 	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/api/equality"
 	"k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/types"
 	intstr "k8s.io/apimachinery/pkg/util/intstr"
@@ -17,6 +18,11 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 )
 
+const (
+	nginxCanaryAnnotation       = "nginx.ingress.kubernetes.io/canary"
+	nginxCanaryWeightAnnotation = "nginx.ingress.kubernetes.io/canary-weight"
+)
+
 func (r *ReconcileExternalService) reconcileIngress(instance *esov1alpha1.ExternalService, reqLogger logr.Logger) (reconcile.Result, error) {
 
 	key := types.NamespacedName{Name: instance.Name, Namespace: instance.Namespace}
@@ -25,15 +31,7 @@ func (r *ReconcileExternalService) reconcileIngress(instance *esov1alpha1.Extern
 	if len(instance.Spec.Hosts) <= 0 {
 		// Check if Ingress exists
 		reqLogger.V(1).Info("No Host definitions found. Skip Creating Ingress")
-		found := &extv1.Ingress{}
-		err := r.client.Get(context.TODO(), key, found)
-
-		if err == nil {
-			err := r.client.Delete(context.TODO(), found)
-			reqLogger.V(1).Info("Found existing old Ingress. Deleted Resource")
-			return reconcile.Result{}, err
-		}
-		return reconcile.Result{}, nil
+		return reconcile.Result{}, r.removeIngress(instance, instance.Name, reqLogger)
 	}
 
 	ingress := createIngressCr(instance)
@@ -57,16 +55,20 @@ func (r *ReconcileExternalService) reconcileIngress(instance *esov1alpha1.Extern
 		return reconcile.Result{}, err
 	}
 
-	newIngress := createIngressCr(instance)
-	if !reflect.DeepEqual(*newIngress, *found) {
-		reqLogger.Info("Specs changed. Trying to update Ingress", "namespace", found.Namespace, "ingress", found.Name)
-		newIngress.ObjectMeta.ResourceVersion = found.ObjectMeta.ResourceVersion
+	if !metav1.IsControlledBy(found, instance) {
+		reqLogger.Info("Skip reconcile: Ingress is not owned by the ExternalService", "namespace", found.Namespace, "ingress", found.Name)
+		return reconcile.Result{}, nil
+	}
 
-		if err := controllerutil.SetControllerReference(instance, newIngress, r.scheme); err != nil {
-			return reconcile.Result{}, err
-		}
+	merged := mergeIngress(withoutCopiedAnnotations(found, instance), createIngressCr(instance))
+	if err := controllerutil.SetControllerReference(instance, merged, r.scheme); err != nil {
+		return reconcile.Result{}, err
+	}
+
+	if !equality.Semantic.DeepEqual(merged, found) {
+		reqLogger.Info("Specs changed. Trying to update Ingress", "namespace", found.Namespace, "ingress", found.Name)
 
-		if err = r.client.Update(context.TODO(), newIngress); err == nil {
+		if err = r.client.Update(context.TODO(), merged); err == nil {
 			reqLogger.Info("Updated Ingress", "namespace", found.Namespace, "ingress", found.Name)
 		}
 
@@ -82,12 +84,100 @@ func (r *ReconcileExternalService) reconcileIngress(instance *esov1alpha1.Extern
 	return reconcile.Result{}, nil
 }
 
+// reconcileCanaryIngress creates the canary Ingress of a traffic split between two address
+// groups and removes it if the traffic is not split
+func (r *ReconcileExternalService) reconcileCanaryIngress(instance *esov1alpha1.ExternalService, reqLogger logr.Logger) (reconcile.Result, error) {
+	ingress := createCanaryIngressCr(instance)
+	if ingress == nil {
+		return reconcile.Result{}, r.removeIngress(instance, canaryIngressName(instance), reqLogger)
+	}
+	if err := controllerutil.SetControllerReference(instance, ingress, r.scheme); err != nil {
+		return reconcile.Result{}, err
+	}
+
+	found := &extv1.Ingress{}
+	err := r.client.Get(context.TODO(), types.NamespacedName{Name: ingress.Name, Namespace: ingress.Namespace}, found)
+	if err != nil && errors.IsNotFound(err) {
+		reqLogger.Info("Creating a new canary Ingress", "namespace", ingress.Namespace, "ingress", ingress.Name)
+		return reconcile.Result{}, r.client.Create(context.TODO(), ingress)
+	} else if err != nil {
+		return reconcile.Result{}, err
+	}
+
+	merged := mergeIngress(found, ingress)
+	if err := controllerutil.SetControllerReference(instance, merged, r.scheme); err != nil {
+		return reconcile.Result{}, err
+	}
+	if equality.Semantic.DeepEqual(merged, found) {
+		return reconcile.Result{}, nil
+	}
+
+	reqLogger.Info("Specs changed. Trying to update canary Ingress", "namespace", found.Namespace, "ingress", found.Name)
+	return reconcile.Result{
+		RequeueAfter: time.Second,
+		Requeue:      true,
+	}, r.client.Update(context.TODO(), merged)
+}
+
+// removeIngress deletes the named Ingress if the ExternalService owns it
+func (r *ReconcileExternalService) removeIngress(instance *esov1alpha1.ExternalService, name string, reqLogger logr.Logger) error {
+	found := &extv1.Ingress{}
+	err := r.client.Get(context.TODO(), types.NamespacedName{Name: name, Namespace: instance.Namespace}, found)
+	if errors.IsNotFound(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	if !metav1.IsControlledBy(found, instance) {
+		return nil
+	}
+
+	reqLogger.Info("Deleting Ingress", "namespace", found.Namespace, "ingress", found.Name)
+	if err := r.client.Delete(context.TODO(), found); err != nil && !errors.IsNotFound(err) {
+		return err
+	}
+	return nil
+}
+
+// ingressCanary returns the Services of both address groups if the traffic is split between
+// two groups, which an Ingress can express with a canary Ingress of ingress-nginx
+func ingressCanary(i *esov1alpha1.ExternalService) (primary splitBackend, canary splitBackend, ok bool) {
+	backends := splitBackends(i)
+	if len(backends) != 2 {
+		return splitBackend{}, splitBackend{}, false
+	}
+	return backends[0], backends[1], true
+}
+
+func canaryIngressName(i *esov1alpha1.ExternalService) string {
+	return i.Name + "-canary"
+}
+
 func createIngressCr(i *esov1alpha1.ExternalService) *extv1.Ingress {
-	labels := map[string]string{
-		"app":         i.Name,
-		"serviceType": "external",
+	service := i.Name
+	if primary, _, ok := ingressCanary(i); ok {
+		service = primary.service
+	}
+	return newIngressCr(i, i.Name, service, ingressAnnotations(i))
+}
+
+// createCanaryIngressCr creates the canary Ingress sending the share of the second address
+// group to its Service. Returns nil if there is no canary.
+func createCanaryIngressCr(i *esov1alpha1.ExternalService) *extv1.Ingress {
+	_, canary, ok := ingressCanary(i)
+	if !ok || len(i.Spec.Hosts) == 0 {
+		return nil
 	}
 
+	annotations := ingressAnnotations(i)
+	annotations = setKey(annotations, nginxCanaryAnnotation, "true")
+	annotations = setKey(annotations, nginxCanaryWeightAnnotation, strconv.FormatInt(canary.weight, 10))
+	return newIngressCr(i, canaryIngressName(i), canary.service, annotations)
+}
+
+// newIngressCr creates an Ingress routing all hosts to the given Service
+func newIngressCr(i *esov1alpha1.ExternalService, name string, service string, annotations map[string]string) *extv1.Ingress {
 	ingressrules := []extv1.IngressRule{}
 	for _, hostpath := range i.Spec.Hosts {
 		ingressrules = append(ingressrules, extv1.IngressRule{
@@ -98,7 +188,7 @@ func createIngressCr(i *esov1alpha1.ExternalService) *extv1.Ingress {
 						extv1.HTTPIngressPath{
 							Path: hostpath.Path,
 							Backend: extv1.IngressBackend{
-								ServiceName: i.Name,
+								ServiceName: service,
 								ServicePort: intstr.FromInt(int(i.Spec.Port)),
 							},
 						},
@@ -108,15 +198,93 @@ func createIngressCr(i *esov1alpha1.ExternalService) *extv1.Ingress {
 		})
 	}
 
-	return &extv1.Ingress{
+	ingress := &extv1.Ingress{
 		ObjectMeta: metav1.ObjectMeta{
-			Name:        i.Name,
-			Namespace:   i.Namespace,
-			Labels:      labels,
-			Annotations: i.Annotations,
+			Name:      name,
+			Namespace: i.Namespace,
 		},
 		Spec: extv1.IngressSpec{
 			Rules: ingressrules,
 		},
 	}
+	if i.Spec.Ingress != nil {
+		for _, tls := range i.Spec.Ingress.TLS {
+			ingress.Spec.TLS = append(ingress.Spec.TLS, extv1.IngressTLS{Hosts: tls.Hosts, SecretName: tls.SecretName})
+		}
+	}
+	setManagedMetadata(&ingress.ObjectMeta, i, ingressLabels(i), annotations)
+
+	return ingress
+}
+
+// mergeIngress applies the rules and metadata the operator owns to the found Ingress.
+// Everything else, like annotations added by other controllers, is kept. TLS settings are
+// only owned by the operator if they are configured.
+func mergeIngress(found *extv1.Ingress, wanted *extv1.Ingress) *extv1.Ingress {
+	merged := found.DeepCopy()
+	mergeManagedMetadata(&merged.ObjectMeta, wanted.ObjectMeta)
+	merged.Spec.Rules = wanted.Spec.Rules
+	if len(wanted.Spec.TLS) > 0 {
+		merged.Spec.TLS = wanted.Spec.TLS
+	}
+
+	return merged
+}
+
+// withoutCopiedAnnotations migrates Ingresses created before the managed annotations were
+// recorded. Those got every annotation of the ExternalService, including the ones of kubectl,
+// so without a record of managed keys the annotations named like one of the ExternalService
+// are removed. The configured ones are set again by the merge.
+func withoutCopiedAnnotations(found *extv1.Ingress, i *esov1alpha1.ExternalService) *extv1.Ingress {
+	_, hasLabels := found.Annotations[managedLabelsAnnotation]
+	_, hasAnnotations := found.Annotations[managedAnnotationsAnnotation]
+	if hasLabels || hasAnnotations {
+		return found
+	}
+
+	migrated := found.DeepCopy()
+	for key := range i.Annotations {
+		delete(migrated.Annotations, key)
+	}
+	if len(migrated.Annotations) == 0 {
+		migrated.Annotations = nil
+	}
+	return migrated
+}
+
+func ingressLabels(i *esov1alpha1.ExternalService) map[string]string {
+	if i.Spec.Ingress == nil {
+		return nil
+	}
+	return i.Spec.Ingress.Labels
+}
+
+// ingressAnnotations returns the configured annotations of the Ingress. If the annotations of
+// the ExternalService are copied, the configured ones take precedence.
+func ingressAnnotations(i *esov1alpha1.ExternalService) map[string]string {
+	if i.Spec.Ingress == nil {
+		return nil
+	}
+
+	annotations := map[string]string{}
+	if i.Spec.Ingress.CopyAnnotations {
+		annotations = copyAnnotations(i)
+	}
+	for key, value := range i.Spec.Ingress.Annotations {
+		annotations[key] = value
+	}
+	return annotations
+}
+
+// distinctHosts returns the hosts of spec.hosts without duplicates
+func distinctHosts(i *esov1alpha1.ExternalService) []string {
+	hosts := []string{}
+	known := map[string]bool{}
+	for _, hostpath := range i.Spec.Hosts {
+		if !known[hostpath.Host] {
+			known[hostpath.Host] = true
+			hosts = append(hosts, hostpath.Host)
+		}
+	}
+	return hosts
 }
@@ -1,12 +1,17 @@
 package externalservice
 
 import (
+	esov1alpha1 "github.com/CrowdfoxGmbH/external-service-operator/pkg/apis/eso/v1alpha1"
 	"github.com/CrowdfoxGmbH/external-service-operator/pkg/testutils"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 	"testing"
 )
 
 func TestCreateIngressCr(t *testing.T) {
-	ingress := createIngressCr(getTestExternalServiceCR())
+	instance := getTestExternalServiceCR()
+	instance.Spec.Ingress = &esov1alpha1.IngressConfig{CopyAnnotations: true}
+	ingress := createIngressCr(instance)
 
 	testutils.ExpectEqStr(ingress.Name, "TestService", t)
 	testutils.ExpectEqStr(ingress.Namespace, "external-services", t)
@@ -26,3 +31,67 @@ func TestCreateIngressCr(t *testing.T) {
 	testutils.ExpectEqStr(ingress.Spec.Rules[1].IngressRuleValue.HTTP.Paths[0].Backend.ServicePort.String(), "80", t)
 
 }
+
+func TestCreateIngressCrWithConfiguredMetadata(t *testing.T) {
+	instance := getTestExternalServiceCR()
+	instance.Spec.Ingress = &esov1alpha1.IngressConfig{
+		Annotations: map[string]string{"nginx.ingress.kubernetes.io/proxy-body-size": "8m"},
+		Labels:      map[string]string{"team": "payments", "app": "ignored"},
+	}
+
+	ingress := createIngressCr(instance)
+
+	testutils.ExpectEqStr(ingress.Annotations["nginx.ingress.kubernetes.io/proxy-body-size"], "8m", t)
+	testutils.ExpectEqStr(ingress.Annotations["foo.bar"], "", t)
+	testutils.ExpectEqStr(ingress.Labels["team"], "payments", t)
+	testutils.ExpectEqStr(ingress.Labels["app"], "TestService", t)
+}
+
+func TestCreateIngressCrCopiesAnnotationsWithoutDenied(t *testing.T) {
+	instance := getTestExternalServiceCR()
+	instance.Annotations["kubectl.kubernetes.io/last-applied-configuration"] = "{}"
+	instance.Annotations["meta.helm.sh/release-name"] = "external-services"
+	instance.Annotations[esov1alpha1.DrainAnnotation] = "10.0.100.10"
+	instance.Spec.Ingress = &esov1alpha1.IngressConfig{
+		CopyAnnotations: true,
+		Annotations:     map[string]string{"foo.bar": "configured"},
+	}
+
+	ingress := createIngressCr(instance)
+
+	testutils.ExpectEqStr(ingress.Annotations["foo.bar"], "configured", t)
+	_, found := ingress.Annotations["kubectl.kubernetes.io/last-applied-configuration"]
+	testutils.ExpectFalse(found, t)
+	_, found = ingress.Annotations["meta.helm.sh/release-name"]
+	testutils.ExpectFalse(found, t)
+	_, found = ingress.Annotations[esov1alpha1.DrainAnnotation]
+	testutils.ExpectFalse(found, t)
+}
+
+func TestReconcileIngressRemovesAnnotationsCopiedBeforeTracking(t *testing.T) {
+	// Given an Ingress created before managed annotations were recorded, which got all
+	// annotations of the ExternalService, and an annotation of another controller
+	instance := getTestExternalServiceCR()
+	instance.Annotations["kubectl.kubernetes.io/last-applied-configuration"] = `{"spec":{}}`
+	ingress := createIngressCr(instance)
+	controllerutil.SetControllerReference(instance, ingress, scheme.Scheme)
+	ingress.Annotations = map[string]string{
+		"foo.bar": "testvalue",
+		"kubectl.kubernetes.io/last-applied-configuration": "{}",
+		"cert-manager.io/issued":                           "true",
+	}
+	client := testutils.InitFakeClient(instance, ingress)
+
+	// When it is reconciled without copying annotations
+	if _, err := newTestReconciler(client).reconcileIngress(instance, log); err != nil {
+		t.Fatalf("reconcile ingress: (%v)", err)
+	}
+
+	// Then only the annotation of the other controller is left
+	found, err := getRuntimeIngress(client, instance.Name, instance.Namespace)
+	if err != nil {
+		t.Fatalf("get Ingress: (%v)", err)
+	}
+	testutils.ExpectEqInt(int32(len(found.Annotations)), 1, t)
+	testutils.ExpectEqStr(found.Annotations["cert-manager.io/issued"], "true", t)
+}
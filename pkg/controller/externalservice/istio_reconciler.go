@@ -0,0 +1,156 @@
+package externalservice
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	esov1alpha1 "github.com/CrowdfoxGmbH/external-service-operator/pkg/apis/eso/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+var (
+	serviceEntryGVK    = schema.GroupVersionKind{Group: "networking.istio.io", Version: "v1beta1", Kind: "ServiceEntry"}
+	destinationRuleGVK = schema.GroupVersionKind{Group: "networking.istio.io", Version: "v1beta1", Kind: "DestinationRule"}
+
+	// istioGVKs are all kinds of Istio resources the operator creates
+	istioGVKs = []schema.GroupVersionKind{serviceEntryGVK, destinationRuleGVK}
+)
+
+// reconcileIstio creates the ServiceEntry and the DestinationRule. The ServiceEntry lists the
+// ready addresses of the Endpoint, so it is updated whenever the prober changes the Endpoint.
+func (r *ReconcileExternalService) reconcileIstio(instance *esov1alpha1.ExternalService, reqLogger logr.Logger) (reconcile.Result, error) {
+	if instance.Spec.Istio == nil {
+		for _, gvk := range istioGVKs {
+			if err := r.deleteUnstructured(instance, gvk, reqLogger); err != nil {
+				return reconcile.Result{}, err
+			}
+		}
+		return reconcile.Result{}, nil
+	}
+
+	endpoint := &corev1.Endpoints{}
+	err := r.client.Get(context.TODO(), types.NamespacedName{Name: instance.Name, Namespace: instance.Namespace}, endpoint)
+	if err != nil && !errors.IsNotFound(err) {
+		return reconcile.Result{}, err
+	}
+
+	if result, err := r.reconcileUnstructured(instance, createServiceEntryCr(instance, endpoint), reqLogger); err != nil {
+		return result, err
+	}
+
+	if instance.Spec.Istio.DestinationRule == nil {
+		return reconcile.Result{}, r.deleteUnstructured(instance, destinationRuleGVK, reqLogger)
+	}
+	return r.reconcileUnstructured(instance, createDestinationRuleCr(instance), reqLogger)
+}
+
+func createServiceEntryCr(i *esov1alpha1.ExternalService, endpoint *corev1.Endpoints) *unstructured.Unstructured {
+	config := i.Spec.Istio
+	protocol := config.Protocol
+	if protocol == "" {
+		protocol = "TCP"
+	}
+	portName := fmt.Sprintf("%v-%v", strings.ToLower(protocol), i.Spec.Port)
+
+	resolution := config.Resolution
+	if resolution == "" {
+		resolution = esov1alpha1.IstioResolutionStatic
+	}
+
+	spec := map[string]interface{}{
+		"hosts":      stringSlice(istioHosts(i)),
+		"location":   "MESH_EXTERNAL",
+		"resolution": string(resolution),
+		"ports": []interface{}{
+			map[string]interface{}{
+				"number":   int64(i.Spec.Port),
+				"name":     portName,
+				"protocol": protocol,
+			},
+		},
+	}
+
+	switch {
+	case resolution == esov1alpha1.IstioResolutionStatic:
+		spec["endpoints"] = staticServiceEntryEndpoints(i, endpoint, portName)
+	case i.Spec.IsExternalName():
+		spec["endpoints"] = []interface{}{map[string]interface{}{"address": i.Spec.Service.ExternalName}}
+	}
+
+	return newUnstructured(i, serviceEntryGVK, spec)
+}
+
+// staticServiceEntryEndpoints returns the ready addresses of the Endpoint in their order
+func staticServiceEntryEndpoints(i *esov1alpha1.ExternalService, endpoint *corev1.Endpoints, portName string) []interface{} {
+	endpoints := []interface{}{}
+	for _, subset := range endpoint.Subsets {
+		for _, address := range subset.Addresses {
+			entry := map[string]interface{}{"address": address.IP}
+
+			if len(subset.Ports) > 0 && subset.Ports[0].Port != i.Spec.Port {
+				entry["ports"] = map[string]interface{}{portName: int64(subset.Ports[0].Port)}
+			}
+			if definition, found := i.GetAddress(address.IP); found && len(definition.Labels) > 0 {
+				labels := map[string]interface{}{}
+				for key, value := range definition.Labels {
+					labels[key] = value
+				}
+				entry["labels"] = labels
+			}
+
+			endpoints = append(endpoints, entry)
+		}
+	}
+	return endpoints
+}
+
+func createDestinationRuleCr(i *esov1alpha1.ExternalService) *unstructured.Unstructured {
+	host := istioHosts(i)[0]
+	spec := map[string]interface{}{"host": host}
+
+	if tls := i.Spec.Istio.DestinationRule.TLS; tls != nil {
+		settings := map[string]interface{}{"mode": tls.Mode}
+
+		sni := tls.SNI
+		if sni == "" && tls.Mode != "DISABLE" && tls.Mode != "ISTIO_MUTUAL" {
+			sni = host
+		}
+		if sni != "" {
+			settings["sni"] = sni
+		}
+		if tls.CredentialName != "" {
+			settings["credentialName"] = tls.CredentialName
+		}
+		if len(tls.SubjectAltNames) > 0 {
+			settings["subjectAltNames"] = stringSlice(tls.SubjectAltNames)
+		}
+		if tls.InsecureSkipVerify {
+			settings["insecureSkipVerify"] = true
+		}
+
+		spec["trafficPolicy"] = map[string]interface{}{"tls": settings}
+	}
+
+	return newUnstructured(i, destinationRuleGVK, spec)
+}
+
+// istioHosts returns the configured hosts, the hosts of spec.hosts or the name of the Service
+func istioHosts(i *esov1alpha1.ExternalService) []string {
+	if len(i.Spec.Istio.Hosts) > 0 {
+		return i.Spec.Istio.Hosts
+	}
+
+	hosts := distinctHosts(i)
+	if len(hosts) == 0 {
+		hosts = append(hosts, fmt.Sprintf("%v.%v.svc.cluster.local", i.Name, i.Namespace))
+	}
+	return hosts
+}
@@ -0,0 +1,137 @@
+package externalservice
+
+import (
+	"testing"
+
+	esov1alpha1 "github.com/CrowdfoxGmbH/external-service-operator/pkg/apis/eso/v1alpha1"
+	"github.com/CrowdfoxGmbH/external-service-operator/pkg/testutils"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func getTestIstioExternalServiceCR() *esov1alpha1.ExternalService {
+	instance := getTestExternalServiceCR()
+	instance.Spec.Hosts = []esov1alpha1.ExternalServiceHostPath{}
+	instance.Spec.Istio = &esov1alpha1.IstioConfig{Protocol: "HTTPS"}
+	return instance
+}
+
+func serviceEntryEndpoints(t *testing.T, serviceEntry *unstructured.Unstructured) []interface{} {
+	endpoints, found, err := unstructured.NestedSlice(serviceEntry.Object, "spec", "endpoints")
+	if !found || err != nil {
+		t.Fatalf("Expected endpoints in ServiceEntry, but got %v (%v)", serviceEntry.Object, err)
+	}
+	return endpoints
+}
+
+func TestCreateServiceEntryCrWithReadyAddresses(t *testing.T) {
+	instance := getTestIstioExternalServiceCR()
+	instance.Spec.Ips = []string{"10.0.100.10", "10.0.100.11"}
+	instance.Spec.Addresses = []esov1alpha1.ExternalServiceAddress{
+		esov1alpha1.ExternalServiceAddress{IP: "10.0.100.12", Port: 8443, Labels: map[string]string{"zone": "b"}},
+	}
+	endpoint := CreateEndpointsCr(instance)
+	endpoint.Subsets[0].Addresses = []corev1.EndpointAddress{corev1.EndpointAddress{IP: "10.0.100.10"}}
+	endpoint.Subsets[0].NotReadyAddresses = []corev1.EndpointAddress{corev1.EndpointAddress{IP: "10.0.100.11"}}
+	endpoint.Subsets[1].Addresses = endpoint.Subsets[1].NotReadyAddresses
+	endpoint.Subsets[1].NotReadyAddresses = nil
+
+	serviceEntry := createServiceEntryCr(instance, endpoint)
+
+	testutils.ExpectEqStr(serviceEntry.GetKind(), "ServiceEntry", t)
+	hosts, _, _ := unstructured.NestedStringSlice(serviceEntry.Object, "spec", "hosts")
+	testutils.ExpectEqStr(hosts[0], "TestService.external-services.svc.cluster.local", t)
+	resolution, _, _ := unstructured.NestedString(serviceEntry.Object, "spec", "resolution")
+	testutils.ExpectEqStr(resolution, "STATIC", t)
+	ports, _, _ := unstructured.NestedSlice(serviceEntry.Object, "spec", "ports")
+	testutils.ExpectEqStr(ports[0].(map[string]interface{})["name"].(string), "https-80", t)
+
+	endpoints := serviceEntryEndpoints(t, serviceEntry)
+	testutils.ExpectEqInt(int32(len(endpoints)), 2, t)
+	testutils.ExpectEqStr(endpoints[0].(map[string]interface{})["address"].(string), "10.0.100.10", t)
+	testutils.ExpectEqStr(endpoints[1].(map[string]interface{})["address"].(string), "10.0.100.12", t)
+	port, _, _ := unstructured.NestedInt64(endpoints[1].(map[string]interface{}), "ports", "https-80")
+	testutils.ExpectEqInt(int32(port), 8443, t)
+	zone, _, _ := unstructured.NestedString(endpoints[1].(map[string]interface{}), "labels", "zone")
+	testutils.ExpectEqStr(zone, "b", t)
+}
+
+func TestCreateServiceEntryCrWithDNSResolution(t *testing.T) {
+	instance := getTestIstioExternalServiceCR()
+	instance.Spec.Istio.Hosts = []string{"db.example.com"}
+	instance.Spec.Istio.Resolution = esov1alpha1.IstioResolutionDNS
+	instance.Spec.Service = &esov1alpha1.ServiceConfig{Type: corev1.ServiceTypeExternalName, ExternalName: "db.eu-west-1.example.com"}
+
+	serviceEntry := createServiceEntryCr(instance, &corev1.Endpoints{})
+
+	endpoints := serviceEntryEndpoints(t, serviceEntry)
+	testutils.ExpectEqStr(endpoints[0].(map[string]interface{})["address"].(string), "db.eu-west-1.example.com", t)
+}
+
+func TestCreateDestinationRuleCrWithTLSOrigination(t *testing.T) {
+	instance := getTestIstioExternalServiceCR()
+	instance.Spec.Istio.Hosts = []string{"api.example.com"}
+	instance.Spec.Istio.DestinationRule = &esov1alpha1.IstioDestinationRule{
+		TLS: &esov1alpha1.IstioTLSSettings{Mode: "MUTUAL", CredentialName: "api-client-cert"},
+	}
+
+	destinationRule := createDestinationRuleCr(instance)
+
+	host, _, _ := unstructured.NestedString(destinationRule.Object, "spec", "host")
+	testutils.ExpectEqStr(host, "api.example.com", t)
+	tls, _, _ := unstructured.NestedStringMap(destinationRule.Object, "spec", "trafficPolicy", "tls")
+	testutils.ExpectEqStr(tls["mode"], "MUTUAL", t)
+	testutils.ExpectEqStr(tls["sni"], "api.example.com", t)
+	testutils.ExpectEqStr(tls["credentialName"], "api-client-cert", t)
+}
+
+func TestReconcileIstioFollowsReadiness(t *testing.T) {
+	// Given an ExternalService with Istio integration and a ready address
+	instance := getTestIstioExternalServiceCR()
+	instance.Spec.Istio.DestinationRule = &esov1alpha1.IstioDestinationRule{TLS: &esov1alpha1.IstioTLSSettings{Mode: "SIMPLE"}}
+	endpoint := CreateEndpointsCr(instance)
+	endpoint.Subsets[0].Addresses = endpoint.Subsets[0].NotReadyAddresses[:1]
+	endpoint.Subsets[0].NotReadyAddresses = endpoint.Subsets[0].NotReadyAddresses[1:]
+	client := testutils.InitFakeClient(instance, endpoint)
+
+	_, err := newTestReconciler(client).reconcileIstio(instance, log)
+	if err != nil {
+		t.Fatalf("reconcile: (%v)", err)
+	}
+	serviceEntry, err := getRuntimeUnstructured(client, serviceEntryGVK, instance.Name, instance.Namespace)
+	if err != nil {
+		t.Fatalf("get ServiceEntry: (%v)", err)
+	}
+	testutils.ExpectEqInt(int32(len(serviceEntryEndpoints(t, serviceEntry))), 1, t)
+	if _, err := getRuntimeUnstructured(client, destinationRuleGVK, instance.Name, instance.Namespace); err != nil {
+		t.Fatalf("get DestinationRule: (%v)", err)
+	}
+
+	// When the prober marks all addresses ready
+	endpoint, _ = getRuntimeEndpoint(client, instance.Name, instance.Namespace)
+	endpoint.Subsets[0].Addresses = append(endpoint.Subsets[0].Addresses, endpoint.Subsets[0].NotReadyAddresses...)
+	endpoint.Subsets[0].NotReadyAddresses = nil
+	updateObject(client, endpoint)
+
+	_, err = newTestReconciler(client).reconcileIstio(instance, log)
+	if err != nil {
+		t.Fatalf("reconcile: (%v)", err)
+	}
+
+	// Then the ServiceEntry lists all of them
+	serviceEntry, _ = getRuntimeUnstructured(client, serviceEntryGVK, instance.Name, instance.Namespace)
+	testutils.ExpectEqInt(int32(len(serviceEntryEndpoints(t, serviceEntry))), 3, t)
+
+	// And when the Istio integration is removed, both resources are deleted
+	instance.Spec.Istio = nil
+	if _, err := newTestReconciler(client).reconcileIstio(instance, log); err != nil {
+		t.Fatalf("reconcile: (%v)", err)
+	}
+	if _, err := getRuntimeUnstructured(client, serviceEntryGVK, instance.Name, instance.Namespace); !errors.IsNotFound(err) {
+		t.Errorf("Expected ServiceEntry to be deleted, but got %v", err)
+	}
+	if _, err := getRuntimeUnstructured(client, destinationRuleGVK, instance.Name, instance.Namespace); !errors.IsNotFound(err) {
+		t.Errorf("Expected DestinationRule to be deleted, but got %v", err)
+	}
+}
@@ -0,0 +1,144 @@
+package externalservice
+
+import (
+	"sort"
+	"strings"
+
+	esov1alpha1 "github.com/CrowdfoxGmbH/external-service-operator/pkg/apis/eso/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// managedLabelsAnnotation lists the configured labels set by the operator, so they can be
+	// removed again without touching labels added by others
+	managedLabelsAnnotation = "eso.crowdfox.com/managed-labels"
+	// managedAnnotationsAnnotation lists the annotations set by the operator
+	managedAnnotationsAnnotation = "eso.crowdfox.com/managed-annotations"
+)
+
+// copiedAnnotationsDenylist holds the prefixes of annotations which are never copied from
+// the ExternalService, as they belong to the tools managing the ExternalService itself
+var copiedAnnotationsDenylist = []string{
+	"kubectl.kubernetes.io/",
+	"meta.helm.sh/",
+	"eso.crowdfox.com/",
+}
+
+// standardLabels are set on every object created for an ExternalService
+func standardLabels(i *esov1alpha1.ExternalService) map[string]string {
+	return map[string]string{
+		"app":         i.Name,
+		"serviceType": "external",
+	}
+}
+
+// setManagedMetadata sets the standard labels, the configured labels and the annotations of an
+// object created by the operator
+func setManagedMetadata(meta *metav1.ObjectMeta, i *esov1alpha1.ExternalService, labels map[string]string, annotations map[string]string) {
+	managed := managedMetadata(labels, annotations)
+	meta.Labels = managed.Labels
+	meta.Annotations = managed.Annotations
+
+	for key, value := range standardLabels(i) {
+		meta.Labels = setKey(meta.Labels, key, value)
+	}
+}
+
+// managedMetadata returns the configured labels and annotations and records their keys as managed
+func managedMetadata(labels map[string]string, annotations map[string]string) metav1.ObjectMeta {
+	meta := metav1.ObjectMeta{
+		Labels:      copyMap(labels),
+		Annotations: copyMap(annotations),
+	}
+
+	if len(labels) > 0 {
+		meta.Annotations = setKey(meta.Annotations, managedLabelsAnnotation, joinKeys(labels))
+	}
+	if len(annotations) > 0 {
+		meta.Annotations = setKey(meta.Annotations, managedAnnotationsAnnotation, joinKeys(annotations))
+	}
+	return meta
+}
+
+// mergeManagedMetadata applies the labels and annotations the operator manages to an existing
+// object. Managed keys which are not wanted anymore are removed, all other keys are kept.
+func mergeManagedMetadata(found *metav1.ObjectMeta, wanted metav1.ObjectMeta) {
+	previousLabels := splitKeys(found.Annotations[managedLabelsAnnotation])
+	previousAnnotations := append(splitKeys(found.Annotations[managedAnnotationsAnnotation]), managedLabelsAnnotation, managedAnnotationsAnnotation)
+
+	found.Labels = mergeManagedKeys(found.Labels, wanted.Labels, previousLabels)
+	found.Annotations = mergeManagedKeys(found.Annotations, wanted.Annotations, previousAnnotations)
+}
+
+// copyAnnotations returns the annotations of the ExternalService without the denied ones
+func copyAnnotations(i *esov1alpha1.ExternalService) map[string]string {
+	annotations := map[string]string{}
+	for key, value := range i.Annotations {
+		if !isDeniedAnnotation(key) {
+			annotations[key] = value
+		}
+	}
+	return annotations
+}
+
+func isDeniedAnnotation(key string) bool {
+	for _, prefix := range copiedAnnotationsDenylist {
+		if strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func mergeManagedKeys(found map[string]string, wanted map[string]string, previouslyManaged []string) map[string]string {
+	merged := copyMap(found)
+	for _, key := range previouslyManaged {
+		if _, ok := wanted[key]; !ok {
+			delete(merged, key)
+		}
+	}
+	for key, value := range wanted {
+		merged = setKey(merged, key, value)
+	}
+
+	if len(merged) == 0 {
+		return nil
+	}
+	return merged
+}
+
+func copyMap(m map[string]string) map[string]string {
+	if len(m) == 0 {
+		return nil
+	}
+
+	result := make(map[string]string, len(m))
+	for key, value := range m {
+		result[key] = value
+	}
+	return result
+}
+
+func setKey(m map[string]string, key string, value string) map[string]string {
+	if m == nil {
+		m = map[string]string{}
+	}
+	m[key] = value
+	return m
+}
+
+func joinKeys(m map[string]string) string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return strings.Join(keys, ",")
+}
+
+func splitKeys(keys string) []string {
+	if keys == "" {
+		return nil
+	}
+	return strings.Split(keys, ",")
+}
@@ -0,0 +1,47 @@
+package externalservice
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	esov1alpha1 "github.com/CrowdfoxGmbH/external-service-operator/pkg/apis/eso/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/go-logr/logr"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// reconcileReadinessOverrides removes expired readiness overrides from the spec. As long as
+// overrides are left, the request is requeued for the time the next one expires.
+func (r *ReconcileExternalService) reconcileReadinessOverrides(instance *esov1alpha1.ExternalService, reqLogger logr.Logger) (reconcile.Result, error) {
+	now := time.Now()
+
+	activeOverrides := []esov1alpha1.ReadinessOverride{}
+	var nextExpiry *time.Time
+	for _, override := range instance.Spec.ReadinessOverrides {
+		if override.IsExpired(now) {
+			reqLogger.Info("Readiness override expired", "ip", override.IP, "override", override.Name())
+			r.recorder.Event(instance, corev1.EventTypeNormal, "ReadinessOverrideExpired", fmt.Sprintf("%v override of address %v expired", override.Name(), override.IP))
+			continue
+		}
+
+		activeOverrides = append(activeOverrides, override)
+		if override.ExpiresAt != nil && (nextExpiry == nil || override.ExpiresAt.Time.Before(*nextExpiry)) {
+			nextExpiry = &override.ExpiresAt.Time
+		}
+	}
+
+	if len(activeOverrides) != len(instance.Spec.ReadinessOverrides) {
+		instance.Spec.ReadinessOverrides = activeOverrides
+		if err := r.client.Update(context.TODO(), instance); err != nil {
+			return reconcile.Result{}, err
+		}
+	}
+
+	if nextExpiry == nil {
+		return reconcile.Result{}, nil
+	}
+
+	return reconcile.Result{RequeueAfter: nextExpiry.Sub(now)}, nil
+}
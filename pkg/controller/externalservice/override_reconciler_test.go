@@ -0,0 +1,40 @@
+package externalservice
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	esov1alpha1 "github.com/CrowdfoxGmbH/external-service-operator/pkg/apis/eso/v1alpha1"
+	"github.com/CrowdfoxGmbH/external-service-operator/pkg/testutils"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func TestReconcileRemovesExpiredReadinessOverride(t *testing.T) {
+	expired := metav1.NewTime(time.Now().Add(-time.Minute))
+	active := metav1.NewTime(time.Now().Add(time.Hour))
+
+	instance := getTestExternalServiceCR()
+	instance.Spec.ReadinessOverrides = []esov1alpha1.ReadinessOverride{
+		esov1alpha1.ReadinessOverride{IP: "10.0.100.10", ForceReady: true, ExpiresAt: &expired},
+		esov1alpha1.ReadinessOverride{IP: "10.0.100.11", ForceNotReady: true, ExpiresAt: &active},
+		esov1alpha1.ReadinessOverride{IP: "10.0.100.12", ForceNotReady: true},
+	}
+	client := testutils.InitFakeClient(instance)
+
+	res, err := runTestReconcile(client, instance.Name, instance.Namespace)
+	testutils.ExpectNoErrorsAndRequeue(res, err, t)
+
+	if res.RequeueAfter <= 0 || res.RequeueAfter > time.Hour {
+		t.Errorf("Expected to be requeued when the next override expires, but got %v", res.RequeueAfter)
+	}
+
+	actual := &esov1alpha1.ExternalService{}
+	if err := client.Get(context.TODO(), types.NamespacedName{Name: instance.Name, Namespace: instance.Namespace}, actual); err != nil {
+		t.Fatalf("get ExternalService: (%v)", err)
+	}
+	testutils.ExpectEqInt(int32(len(actual.Spec.ReadinessOverrides)), 2, t)
+	testutils.ExpectEqStr(actual.Spec.ReadinessOverrides[0].IP, "10.0.100.11", t)
+	testutils.ExpectEqStr(actual.Spec.ReadinessOverrides[1].IP, "10.0.100.12", t)
+}
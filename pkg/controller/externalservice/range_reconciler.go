@@ -0,0 +1,44 @@
+package externalservice
+
+import (
+	"fmt"
+	"strings"
+
+	esov1alpha1 "github.com/CrowdfoxGmbH/external-service-operator/pkg/apis/eso/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/go-logr/logr"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// reconcileRanges reports ranges which can not be expanded in the RangesValid condition.
+// Their addresses are left out until they are fixed.
+func (r *ReconcileExternalService) reconcileRanges(instance *esov1alpha1.ExternalService, reqLogger logr.Logger) (reconcile.Result, error) {
+	if !setRangesCondition(instance) {
+		return reconcile.Result{}, nil
+	}
+
+	reqLogger.Info("Updating RangesValid condition")
+	return reconcile.Result{}, nil
+}
+
+// setRangesCondition sets the RangesValid condition, it is removed if there are no ranges.
+// Returns true if the status changed.
+func setRangesCondition(instance *esov1alpha1.ExternalService) bool {
+	if len(instance.Spec.Ranges) == 0 {
+		return instance.Status.RemoveCondition(esov1alpha1.RangesValid)
+	}
+
+	condition := esov1alpha1.ExternalServiceCondition{
+		Type:    esov1alpha1.RangesValid,
+		Status:  corev1.ConditionTrue,
+		Reason:  "RangesExpanded",
+		Message: "All ranges are expanded",
+	}
+	if errors := instance.Spec.GetRangeErrors(); len(errors) > 0 {
+		condition.Status = corev1.ConditionFalse
+		condition.Reason = "InvalidRange"
+		condition.Message = fmt.Sprintf("Ranges left out: %v", strings.Join(errors, "; "))
+	}
+	return instance.Status.SetCondition(condition)
+}
@@ -0,0 +1,76 @@
+package externalservice
+
+import (
+	"testing"
+
+	esov1alpha1 "github.com/CrowdfoxGmbH/external-service-operator/pkg/apis/eso/v1alpha1"
+	"github.com/CrowdfoxGmbH/external-service-operator/pkg/testutils"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestCreateEndpointsCrExpandsRanges(t *testing.T) {
+	instance := getTestExternalServiceCR()
+	instance.Spec.Ips = []string{"10.20.0.12"}
+	instance.Spec.Ranges = []esov1alpha1.AddressRange{
+		esov1alpha1.AddressRange{From: "10.20.0.10", To: "10.20.0.14", Exclude: []string{"10.20.0.11"}},
+		esov1alpha1.AddressRange{CIDR: "10.30.0.0/30", Port: 8080},
+	}
+
+	endpoint := CreateEndpointsCr(instance)
+
+	// the IP listed in spec.ips is only added once, network and broadcast address are left out
+	testutils.ExpectEqInt(int32(len(endpoint.Subsets)), 2, t)
+	notReady := endpoint.Subsets[0].NotReadyAddresses
+	testutils.ExpectEqInt(int32(len(notReady)), 4, t)
+	testutils.ExpectEqStr(notReady[0].IP, "10.20.0.12", t)
+	testutils.ExpectEqStr(notReady[1].IP, "10.20.0.10", t)
+	testutils.ExpectEqStr(notReady[3].IP, "10.20.0.14", t)
+	testutils.ExpectEqInt(endpoint.Subsets[1].Ports[0].Port, 8080, t)
+	testutils.ExpectEqStr(endpoint.Subsets[1].NotReadyAddresses[0].IP, "10.30.0.1", t)
+	testutils.ExpectEqStr(endpoint.Subsets[1].NotReadyAddresses[1].IP, "10.30.0.2", t)
+}
+
+func TestCreateEndpointsCrHidesNotReadyRangeAddresses(t *testing.T) {
+	instance := getTestExternalServiceCR()
+	instance.Spec.Ranges = []esov1alpha1.AddressRange{
+		esov1alpha1.AddressRange{CIDR: "10.30.0.0/28", Port: 8080, HideNotReady: true},
+	}
+
+	endpoint := CreateEndpointsCr(instance)
+
+	// the range addresses are only listed once they are ready
+	testutils.ExpectEqInt(int32(len(endpoint.Subsets)), 1, t)
+	testutils.ExpectEqInt(int32(len(endpoint.Subsets[0].NotReadyAddresses)), 3, t)
+
+	// and ready ones are kept on merge
+	endpoint.Subsets = append(endpoint.Subsets, corev1.EndpointSubset{
+		Addresses: []corev1.EndpointAddress{corev1.EndpointAddress{IP: "10.30.0.5"}},
+		Ports:     []corev1.EndpointPort{corev1.EndpointPort{Port: 8080}},
+	})
+	merged, changed := mergeEndpointWithExternalServiceDef(instance, endpoint)
+	testutils.ExpectFalse(changed, t)
+	testutils.ExpectEqStr(merged.Subsets[1].Addresses[0].IP, "10.30.0.5", t)
+	testutils.ExpectEqInt(int32(len(merged.Subsets[1].NotReadyAddresses)), 0, t)
+}
+
+func TestReconcileReportsInvalidRanges(t *testing.T) {
+	// Given a range larger than its cap
+	instance := getTestExternalServiceCR()
+	instance.Spec.Ranges = []esov1alpha1.AddressRange{
+		esov1alpha1.AddressRange{CIDR: "10.30.0.0/16"},
+		esov1alpha1.AddressRange{CIDR: "10.40.0.0/24", MaxAddresses: 10},
+		esov1alpha1.AddressRange{From: "10.50.0.1", To: "10.50.0.2"},
+	}
+	client := testutils.InitFakeClient(instance)
+
+	if _, err := runTestReconcile(client, instance.Name, instance.Namespace); err != nil {
+		t.Fatalf("reconcile: (%v)", err)
+	}
+
+	// Then only the valid range is expanded and the others are reported
+	externalService, _ := getRuntimeExternalService(client, instance.Name, instance.Namespace)
+	testutils.ExpectEqInt(int32(len(externalService.GetIps())), 5, t)
+	condition := externalService.Status.GetCondition(esov1alpha1.RangesValid)
+	testutils.ExpectEqStr(string(condition.Status), string(corev1.ConditionFalse), t)
+	testutils.ExpectEqStr(condition.Message, "Ranges left out: range 10.30.0.0/16 has 65534 addresses, more than the maximum of 256; range 10.40.0.0/24 has 254 addresses, more than the maximum of 10", t)
+}
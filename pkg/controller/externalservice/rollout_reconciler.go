@@ -0,0 +1,276 @@
+package externalservice
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	esov1alpha1 "github.com/CrowdfoxGmbH/external-service-operator/pkg/apis/eso/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// reconcileRollout keeps the addresses removed from the spec until all new addresses pass
+// their probes, then removes them one at a time. The old addresses are kept in the status,
+// from where they are added to the Endpoints. The request is requeued for the time the
+// rollout has to be checked again.
+func (r *ReconcileExternalService) reconcileRollout(instance *esov1alpha1.ExternalService, reqLogger logr.Logger) (reconcile.Result, error) {
+	endpoint := &corev1.Endpoints{}
+	err := r.client.Get(context.TODO(), types.NamespacedName{Name: instance.Name, Namespace: instance.Namespace}, endpoint)
+	if err != nil && !errors.IsNotFound(err) {
+		return reconcile.Result{}, err
+	}
+
+	before := instance.Status.Rollout.DeepCopy()
+	removed, requeueAfter, changed := updateRollout(instance, endpoint.Subsets, time.Now())
+	if !changed {
+		return reconcile.Result{RequeueAfter: requeueAfter}, nil
+	}
+
+	r.recordRolloutEvents(instance, before, removed, reqLogger)
+	reqLogger.Info("Updating status of rollout")
+	return reconcile.Result{RequeueAfter: requeueAfter}, nil
+}
+
+// recordRolloutEvents records an Event for every step the rollout took
+func (r *ReconcileExternalService) recordRolloutEvents(instance *esov1alpha1.ExternalService, before *esov1alpha1.RolloutStatus, removed string, reqLogger logr.Logger) {
+	rollout := instance.Status.Rollout
+	if rollout == nil {
+		return
+	}
+
+	phase := esov1alpha1.RolloutPhaseProgressing
+	if before != nil && before.StartTime.Equal(&rollout.StartTime) {
+		phase = before.Phase
+	} else {
+		reqLogger.Info("Rollout started", "new", rollout.NewAddresses)
+		r.recorder.Event(instance, corev1.EventTypeNormal, "RolloutStarted", fmt.Sprintf("Rollout of %v new addresses started, old addresses are kept until they pass their probes", len(rollout.NewAddresses)))
+	}
+
+	if removed != "" {
+		reqLogger.Info("Old address removed", "ip", removed)
+		r.recorder.Event(instance, corev1.EventTypeNormal, "OldAddressRemoved", fmt.Sprintf("Old address %v removed, %v left", removed, len(rollout.OldAddresses)))
+	}
+
+	if phase == rollout.Phase {
+		return
+	}
+	switch rollout.Phase {
+	case esov1alpha1.RolloutPhaseComplete:
+		reqLogger.Info("Rollout complete")
+		r.recorder.Event(instance, corev1.EventTypeNormal, "RolloutComplete", "All old addresses are removed")
+	case esov1alpha1.RolloutPhaseAborted:
+		condition := instance.Status.GetCondition(esov1alpha1.RolloutProgressing)
+		reqLogger.Info("Rollout aborted", "reason", condition.Message)
+		r.recorder.Event(instance, corev1.EventTypeWarning, "RolloutAborted", condition.Message)
+	}
+}
+
+// updateRollout moves the rollout forward and sets the RolloutProgressing condition. Returns
+// the IP of the old address it removed, the time after which it has to be checked again, 0 if
+// only changes of the addresses move it, and whether the status changed.
+func updateRollout(instance *esov1alpha1.ExternalService, subsets []corev1.EndpointSubset, now time.Time) (string, time.Duration, bool) {
+	if instance.Spec.Rollout == nil || instance.Spec.IsExternalName() {
+		changed := instance.Status.Rollout != nil
+		instance.Status.Rollout = nil
+		return "", 0, instance.Status.RemoveCondition(esov1alpha1.RolloutProgressing) || changed
+	}
+
+	before := instance.Status.Rollout.DeepCopy()
+	rollout := nextRollout(instance, subsets, now)
+	if rollout == nil {
+		return "", 0, false
+	}
+	if rollout.Phase == esov1alpha1.RolloutPhaseProgressing && len(rollout.OldAddresses) == 0 {
+		rollout.Phase = esov1alpha1.RolloutPhaseComplete
+	}
+
+	strategy := instance.Spec.Rollout
+	condition := esov1alpha1.ExternalServiceCondition{
+		Type:   esov1alpha1.RolloutProgressing,
+		Status: corev1.ConditionTrue,
+	}
+	var requeueAfter time.Duration
+	removed := ""
+
+	if rollout.Phase == esov1alpha1.RolloutPhaseProgressing {
+		deadline := rollout.StartTime.Add(time.Duration(strategy.GetDeadline()) * time.Second)
+		unhealthy := unhealthyIps(instance, rollout.NewAddresses)
+		switch {
+		case len(unhealthy) > 0 && !now.Before(deadline):
+			rollout.Phase = esov1alpha1.RolloutPhaseAborted
+			condition.Status = corev1.ConditionFalse
+			condition.Reason = "ProgressDeadlineExceeded"
+			condition.Message = fmt.Sprintf("New addresses %v did not pass their probes within %vs, old addresses are kept", strings.Join(unhealthy, ", "), strategy.GetDeadline())
+		case len(unhealthy) > 0:
+			requeueAfter = deadline.Sub(now)
+			condition.Reason = "WaitingForNewAddresses"
+			condition.Message = fmt.Sprintf("%v of %v new addresses passed their probes", len(rollout.NewAddresses)-len(unhealthy), len(rollout.NewAddresses))
+		default:
+			pause := time.Duration(strategy.GetPause()) * time.Second
+			if rollout.LastRemovalTime == nil || !now.Before(rollout.LastRemovalTime.Add(pause)) {
+				removalTime := metav1.NewTime(now)
+				rollout.LastRemovalTime = &removalTime
+				removed = rollout.OldAddresses[0].IP
+				rollout.OldAddresses = rollout.OldAddresses[1:]
+			}
+			requeueAfter = rollout.LastRemovalTime.Add(pause).Sub(now)
+			condition.Reason = "RemovingOldAddresses"
+			condition.Message = fmt.Sprintf("%v old addresses left", len(rollout.OldAddresses))
+			if len(rollout.OldAddresses) == 0 {
+				rollout.Phase = esov1alpha1.RolloutPhaseComplete
+				requeueAfter = 0
+			}
+		}
+	}
+
+	switch rollout.Phase {
+	case esov1alpha1.RolloutPhaseComplete:
+		condition.Reason = "RolloutComplete"
+		condition.Message = "All old addresses are removed"
+	case esov1alpha1.RolloutPhaseAborted:
+		// the message of the abort stays until the next rollout
+		if existing := instance.Status.GetCondition(esov1alpha1.RolloutProgressing); existing != nil && before != nil && before.Phase == esov1alpha1.RolloutPhaseAborted {
+			condition = *existing
+		}
+	}
+
+	instance.Status.Rollout = rollout
+	changed := !reflect.DeepEqual(before, rollout)
+	return removed, requeueAfter, instance.Status.SetCondition(condition) || changed
+}
+
+// nextRollout returns the rollout with the current addresses. A rollout starts when addresses
+// served by the Endpoints are removed from the spec, an aborted rollout starts again when the
+// new addresses change. Returns nil if there is no rollout.
+func nextRollout(instance *esov1alpha1.ExternalService, subsets []corev1.EndpointSubset, now time.Time) *esov1alpha1.RolloutStatus {
+	wanted := map[string]bool{}
+	for _, address := range instance.GetWantedAddresses() {
+		wanted[address.IP] = true
+	}
+
+	rollout := instance.Status.Rollout.DeepCopy()
+	active := rollout != nil && rollout.Phase != esov1alpha1.RolloutPhaseComplete
+
+	// old addresses are the ones kept by the rollout and the ones the Endpoints still serve
+	old := []esov1alpha1.ExternalServiceAddress{}
+	known := map[string]bool{}
+	if active {
+		for _, address := range rollout.OldAddresses {
+			if !wanted[address.IP] {
+				known[address.IP] = true
+				old = append(old, address)
+			}
+		}
+	}
+	served := map[string]bool{}
+	for _, subset := range subsets {
+		for _, address := range append(append([]corev1.EndpointAddress{}, subset.Addresses...), subset.NotReadyAddresses...) {
+			served[address.IP] = true
+			if wanted[address.IP] || known[address.IP] || instance.IsTerminating(address.IP) {
+				continue
+			}
+			known[address.IP] = true
+			old = append(old, servedAddress(address, subset))
+		}
+	}
+
+	// new addresses are the ones the Endpoints do not serve yet. Addresses hidden while they
+	// are not ready never show up before, so they are not waited for.
+	added := []string{}
+	for _, address := range instance.GetWantedAddresses() {
+		if !served[address.IP] && !address.HideNotReady {
+			added = append(added, address.IP)
+		}
+	}
+
+	if active {
+		newIps := []string{}
+		for _, ip := range rollout.NewAddresses {
+			if wanted[ip] {
+				newIps = append(newIps, ip)
+			}
+		}
+		rollout.OldAddresses = old
+
+		if rollout.Phase == esov1alpha1.RolloutPhaseProgressing {
+			rollout.NewAddresses = append(newIps, added...)
+			return rollout
+		}
+		// an aborted rollout holds its new addresses back until they change
+		if len(old) == 0 || sameIps(newIps, added) {
+			rollout.NewAddresses = newIps
+			if len(old) == 0 {
+				rollout.Phase = esov1alpha1.RolloutPhaseComplete
+			}
+			return rollout
+		}
+	}
+
+	if len(old) == 0 {
+		return rollout
+	}
+	return &esov1alpha1.RolloutStatus{
+		Phase:        esov1alpha1.RolloutPhaseProgressing,
+		StartTime:    metav1.NewTime(now),
+		NewAddresses: added,
+		OldAddresses: old,
+	}
+}
+
+// servedAddress returns the definition of an address served by the Endpoints
+func servedAddress(address corev1.EndpointAddress, subset corev1.EndpointSubset) esov1alpha1.ExternalServiceAddress {
+	served := esov1alpha1.ExternalServiceAddress{IP: address.IP, Hostname: address.Hostname}
+	if address.NodeName != nil {
+		served.NodeName = *address.NodeName
+	}
+	if len(subset.Ports) > 0 {
+		served.Port = subset.Ports[0].Port
+	}
+	return served
+}
+
+// unhealthyIps returns the IPs whose last probe did not pass, including the ones not probed yet.
+// Without a ReadinessProbe nothing is probed, so all addresses are healthy.
+func unhealthyIps(instance *esov1alpha1.ExternalService, ips []string) []string {
+	if instance.Spec.ReadinessProbe == (corev1.Probe{}) {
+		return []string{}
+	}
+
+	healthy := map[string]bool{}
+	for _, address := range instance.Status.Addresses {
+		healthy[address.IP] = address.Healthy != nil && *address.Healthy
+	}
+
+	unhealthy := []string{}
+	for _, ip := range ips {
+		if !healthy[ip] {
+			unhealthy = append(unhealthy, ip)
+		}
+	}
+	return unhealthy
+}
+
+// sameIps reports whether both lists have the same IPs, no matter in which order
+func sameIps(a []string, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	ips := map[string]bool{}
+	for _, ip := range a {
+		ips[ip] = true
+	}
+	for _, ip := range b {
+		if !ips[ip] {
+			return false
+		}
+	}
+	return true
+}
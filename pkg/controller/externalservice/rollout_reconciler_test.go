@@ -0,0 +1,158 @@
+package externalservice
+
+import (
+	"testing"
+	"time"
+
+	esov1alpha1 "github.com/CrowdfoxGmbH/external-service-operator/pkg/apis/eso/v1alpha1"
+	"github.com/CrowdfoxGmbH/external-service-operator/pkg/testutils"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func getTestRolloutExternalServiceCR(ips ...string) *esov1alpha1.ExternalService {
+	instance := getTestExternalServiceCR()
+	instance.Spec.Ips = ips
+	instance.Spec.ReadinessProbe = testutils.CreateDefaultTestProbe()
+	instance.Spec.Rollout = &esov1alpha1.RolloutStrategy{PauseSeconds: 30, DeadlineSeconds: 600}
+	return instance
+}
+
+// getTestRolloutSubsets returns subsets serving the given IPs as ready addresses
+func getTestRolloutSubsets(ips ...string) []corev1.EndpointSubset {
+	subset := corev1.EndpointSubset{Ports: []corev1.EndpointPort{corev1.EndpointPort{Port: 80}}}
+	for _, ip := range ips {
+		subset.Addresses = append(subset.Addresses, corev1.EndpointAddress{IP: ip})
+	}
+	return []corev1.EndpointSubset{subset}
+}
+
+func setTestHealthy(instance *esov1alpha1.ExternalService, ips ...string) {
+	healthy := true
+	for _, ip := range ips {
+		instance.Status.Addresses = append(instance.Status.Addresses, esov1alpha1.ExternalServiceAddressStatus{IP: ip, Ready: true, Healthy: &healthy})
+	}
+}
+
+func TestUpdateRolloutKeepsOldAddressesUntilNewPass(t *testing.T) {
+	// Given an address replaced by a new one
+	instance := getTestRolloutExternalServiceCR("10.0.100.20")
+	now := time.Now()
+
+	_, _, changed := updateRollout(instance, getTestRolloutSubsets("10.0.100.10"), now)
+
+	// Then the old address is kept until the new one passes its probe
+	testutils.ExpectTrue(changed, t)
+	rollout := instance.Status.Rollout
+	testutils.ExpectEqStr(string(rollout.Phase), string(esov1alpha1.RolloutPhaseProgressing), t)
+	testutils.ExpectEqStr(rollout.NewAddresses[0], "10.0.100.20", t)
+	testutils.ExpectEqStr(rollout.OldAddresses[0].IP, "10.0.100.10", t)
+	testutils.ExpectEqInt(int32(len(instance.GetIps())), 2, t)
+	testutils.ExpectEqStr(instance.Status.GetCondition(esov1alpha1.RolloutProgressing).Reason, "WaitingForNewAddresses", t)
+
+	// When the new address passed its probe
+	setTestHealthy(instance, "10.0.100.20")
+	removed, _, _ := updateRollout(instance, getTestRolloutSubsets("10.0.100.10", "10.0.100.20"), now.Add(time.Second))
+
+	// Then the old address is removed
+	testutils.ExpectEqStr(removed, "10.0.100.10", t)
+	testutils.ExpectEqStr(string(instance.Status.Rollout.Phase), string(esov1alpha1.RolloutPhaseComplete), t)
+	testutils.ExpectEqInt(int32(len(instance.GetIps())), 1, t)
+	testutils.ExpectEqStr(instance.Status.GetCondition(esov1alpha1.RolloutProgressing).Reason, "RolloutComplete", t)
+}
+
+func TestUpdateRolloutWithoutProbeTreatsAddressesAsHealthy(t *testing.T) {
+	// Given an address replaced by a new one without a ReadinessProbe
+	instance := getTestRolloutExternalServiceCR("10.0.100.20")
+	instance.Spec.ReadinessProbe = corev1.Probe{}
+
+	removed, _, changed := updateRollout(instance, getTestRolloutSubsets("10.0.100.10"), time.Now())
+
+	// Then the new address is never probed, so the old one is removed right away
+	testutils.ExpectTrue(changed, t)
+	testutils.ExpectEqStr(removed, "10.0.100.10", t)
+	testutils.ExpectEqStr(string(instance.Status.Rollout.Phase), string(esov1alpha1.RolloutPhaseComplete), t)
+	testutils.ExpectEqInt(int32(len(instance.GetIps())), 1, t)
+}
+
+func TestUpdateRolloutPausesBetweenRemovals(t *testing.T) {
+	// Given two old addresses and a new one which passed its probe
+	instance := getTestRolloutExternalServiceCR("10.0.100.20")
+	setTestHealthy(instance, "10.0.100.20")
+	now := time.Now()
+	subsets := getTestRolloutSubsets("10.0.100.10", "10.0.100.11", "10.0.100.20")
+
+	// Then the first one is removed right away
+	removed, requeueAfter, _ := updateRollout(instance, subsets, now)
+	testutils.ExpectEqStr(removed, "10.0.100.10", t)
+	testutils.ExpectTrue(requeueAfter == 30*time.Second, t)
+
+	// and the second one after the pause
+	removed, requeueAfter, changed := updateRollout(instance, getTestRolloutSubsets("10.0.100.11", "10.0.100.20"), now.Add(10*time.Second))
+	testutils.ExpectEqStr(removed, "", t)
+	testutils.ExpectFalse(changed, t)
+	testutils.ExpectTrue(requeueAfter == 20*time.Second, t)
+
+	removed, _, _ = updateRollout(instance, getTestRolloutSubsets("10.0.100.11", "10.0.100.20"), now.Add(30*time.Second))
+	testutils.ExpectEqStr(removed, "10.0.100.11", t)
+	testutils.ExpectEqStr(string(instance.Status.Rollout.Phase), string(esov1alpha1.RolloutPhaseComplete), t)
+}
+
+func TestUpdateRolloutAbortsAfterDeadline(t *testing.T) {
+	// Given a new address which does not pass its probe
+	instance := getTestRolloutExternalServiceCR("10.0.100.20")
+	now := time.Now()
+	updateRollout(instance, getTestRolloutSubsets("10.0.100.10"), now)
+
+	// When the deadline is over
+	subsets := getTestRolloutSubsets("10.0.100.10")
+	subsets[0].NotReadyAddresses = []corev1.EndpointAddress{corev1.EndpointAddress{IP: "10.0.100.20"}}
+	updateRollout(instance, subsets, now.Add(600*time.Second))
+
+	// Then the old address is kept and the new one is left out
+	testutils.ExpectEqStr(string(instance.Status.Rollout.Phase), string(esov1alpha1.RolloutPhaseAborted), t)
+	ips := instance.GetIps()
+	testutils.ExpectEqInt(int32(len(ips)), 1, t)
+	testutils.ExpectEqStr(ips[0], "10.0.100.10", t)
+	condition := instance.Status.GetCondition(esov1alpha1.RolloutProgressing)
+	testutils.ExpectEqStr(string(condition.Status), string(corev1.ConditionFalse), t)
+	testutils.ExpectEqStr(condition.Message, "New addresses 10.0.100.20 did not pass their probes within 600s, old addresses are kept", t)
+
+	// and it stays aborted until the addresses change
+	_, _, changed := updateRollout(instance, getTestRolloutSubsets("10.0.100.10"), now.Add(700*time.Second))
+	testutils.ExpectFalse(changed, t)
+
+	instance.Spec.Ips = []string{"10.0.100.21"}
+	updateRollout(instance, getTestRolloutSubsets("10.0.100.10"), now.Add(800*time.Second))
+	testutils.ExpectEqStr(string(instance.Status.Rollout.Phase), string(esov1alpha1.RolloutPhaseProgressing), t)
+	testutils.ExpectEqStr(instance.Status.Rollout.NewAddresses[0], "10.0.100.21", t)
+	testutils.ExpectEqStr(instance.Status.Rollout.OldAddresses[0].IP, "10.0.100.10", t)
+}
+
+func TestReconcileRolloutKeepsRemovedAddresses(t *testing.T) {
+	// Given an ExternalService with a rollout strategy and a probe
+	instance := getTestExternalServiceCR()
+	instance.Spec.ReadinessProbe = testutils.CreateDefaultTestProbe()
+	instance.Spec.Rollout = &esov1alpha1.RolloutStrategy{}
+	client := testutils.InitFakeClient(instance)
+	if _, err := runTestReconcile(client, instance.Name, instance.Namespace); err != nil {
+		t.Fatalf("reconcile: (%v)", err)
+	}
+
+	// When two of its addresses are replaced
+	externalService, _ := getRuntimeExternalService(client, instance.Name, instance.Namespace)
+	externalService.Spec.Ips = []string{"10.0.100.10", "10.0.100.20"}
+	if err := updateObject(client, externalService); err != nil {
+		t.Fatalf("update external service: (%v)", err)
+	}
+	result, err := runTestReconcile(client, instance.Name, instance.Namespace)
+	if err != nil {
+		t.Fatalf("reconcile: (%v)", err)
+	}
+
+	// Then the Endpoints keep them next to the new one until the deadline
+	endpoint, _ := getRuntimeEndpoint(client, instance.Name, instance.Namespace)
+	testutils.ExpectEqInt(int32(len(endpoint.Subsets[0].Addresses)+len(endpoint.Subsets[0].NotReadyAddresses)), 4, t)
+	testutils.ExpectTrue(result.RequeueAfter > 590*time.Second, t)
+	externalService, _ = getRuntimeExternalService(client, instance.Name, instance.Namespace)
+	testutils.ExpectEqInt(int32(len(externalService.Status.Rollout.OldAddresses)), 2, t)
+}
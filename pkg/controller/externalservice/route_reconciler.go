@@ -0,0 +1,138 @@
+package externalservice
+
+import (
+	"fmt"
+
+	esov1alpha1 "github.com/CrowdfoxGmbH/external-service-operator/pkg/apis/eso/v1alpha1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	"github.com/go-logr/logr"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// routeGenerator creates the objects which route the hosts of an ExternalService to its Service
+type routeGenerator interface {
+	// reconcile creates or updates the objects of the generator
+	reconcile(r *ReconcileExternalService, instance *esov1alpha1.ExternalService, reqLogger logr.Logger) (reconcile.Result, error)
+	// remove deletes the objects of the generator owned by the ExternalService
+	remove(r *ReconcileExternalService, instance *esov1alpha1.ExternalService, reqLogger logr.Logger) error
+}
+
+// routeGenerators are all generators an ExternalService can select from
+var routeGenerators = []struct {
+	kind      esov1alpha1.IngressKind
+	generator routeGenerator
+}{
+	{esov1alpha1.IngressKindIngress, ingressGenerator{}},
+	{esov1alpha1.IngressKindTraefik, unstructuredRouteGenerator{gvk: traefikIngressRouteGVK, create: createTraefikIngressRouteCrs}},
+	{esov1alpha1.IngressKindContour, unstructuredRouteGenerator{gvk: contourHTTPProxyGVK, create: createContourHTTPProxyCrs}},
+}
+
+// reconcileRoutes reconciles the objects of the selected generator and removes the ones of all others
+func (r *ReconcileExternalService) reconcileRoutes(instance *esov1alpha1.ExternalService, reqLogger logr.Logger) (reconcile.Result, error) {
+	selected := ingressKind(instance)
+
+	for _, route := range routeGenerators {
+		if route.kind == selected {
+			if result, err := route.generator.reconcile(r, instance, reqLogger); err != nil {
+				return result, err
+			}
+			continue
+		}
+
+		if err := route.generator.remove(r, instance, reqLogger); err != nil {
+			return reconcile.Result{}, err
+		}
+	}
+
+	return reconcile.Result{}, nil
+}
+
+func ingressKind(i *esov1alpha1.ExternalService) esov1alpha1.IngressKind {
+	if i.Spec.Ingress == nil || i.Spec.Ingress.Kind == "" {
+		return esov1alpha1.IngressKindIngress
+	}
+	return i.Spec.Ingress.Kind
+}
+
+// ingressGenerator creates a plain Ingress
+type ingressGenerator struct{}
+
+func (ingressGenerator) reconcile(r *ReconcileExternalService, instance *esov1alpha1.ExternalService, reqLogger logr.Logger) (reconcile.Result, error) {
+	if result, err := r.reconcileIngress(instance, reqLogger); err != nil {
+		return result, err
+	}
+	return r.reconcileCanaryIngress(instance, reqLogger)
+}
+
+func (ingressGenerator) remove(r *ReconcileExternalService, instance *esov1alpha1.ExternalService, reqLogger logr.Logger) error {
+	if err := r.removeIngress(instance, instance.Name, reqLogger); err != nil {
+		return err
+	}
+	return r.removeIngress(instance, canaryIngressName(instance), reqLogger)
+}
+
+// unstructuredRouteGenerator creates objects of a kind the operator has no Go types for. The
+// objects are named like the ExternalService, followed by -1, -2, ... if there is more than one.
+type unstructuredRouteGenerator struct {
+	gvk    schema.GroupVersionKind
+	create func(i *esov1alpha1.ExternalService) []*unstructured.Unstructured
+}
+
+func (g unstructuredRouteGenerator) reconcile(r *ReconcileExternalService, instance *esov1alpha1.ExternalService, reqLogger logr.Logger) (reconcile.Result, error) {
+	routes := []*unstructured.Unstructured{}
+	if len(instance.Spec.Hosts) > 0 {
+		routes = g.create(instance)
+	}
+
+	for _, route := range routes {
+		if result, err := r.reconcileUnstructured(instance, route, reqLogger); err != nil {
+			return result, err
+		}
+	}
+
+	return reconcile.Result{}, g.removeFrom(r, instance, len(routes), reqLogger)
+}
+
+func (g unstructuredRouteGenerator) remove(r *ReconcileExternalService, instance *esov1alpha1.ExternalService, reqLogger logr.Logger) error {
+	return g.removeFrom(r, instance, 0, reqLogger)
+}
+
+// removeFrom deletes the objects starting with the given index. As the objects are numbered
+// without gaps, the first missing one ends the search.
+func (g unstructuredRouteGenerator) removeFrom(r *ReconcileExternalService, instance *esov1alpha1.ExternalService, index int, reqLogger logr.Logger) error {
+	for ; ; index++ {
+		existed, err := r.deleteUnstructuredByName(instance, g.gvk, routeName(instance, index), reqLogger)
+		if err != nil || !existed {
+			return err
+		}
+	}
+}
+
+// routeName returns the name of the route with the given index
+func routeName(i *esov1alpha1.ExternalService, index int) string {
+	if index == 0 {
+		return i.Name
+	}
+	return fmt.Sprintf("%v-%v", i.Name, index)
+}
+
+// tlsSecretName returns the Secret of the first TLS entry covering the host
+func tlsSecretName(i *esov1alpha1.ExternalService, host string) string {
+	if i.Spec.Ingress == nil {
+		return ""
+	}
+
+	for _, tls := range i.Spec.Ingress.TLS {
+		if len(tls.Hosts) == 0 {
+			return tls.SecretName
+		}
+		for _, tlsHost := range tls.Hosts {
+			if tlsHost == host {
+				return tls.SecretName
+			}
+		}
+	}
+	return ""
+}
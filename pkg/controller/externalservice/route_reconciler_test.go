@@ -0,0 +1,134 @@
+package externalservice
+
+import (
+	"testing"
+
+	esov1alpha1 "github.com/CrowdfoxGmbH/external-service-operator/pkg/apis/eso/v1alpha1"
+	"github.com/CrowdfoxGmbH/external-service-operator/pkg/testutils"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestCreateTraefikIngressRouteCrs(t *testing.T) {
+	instance := getTestExternalServiceCR()
+	instance.Spec.Ingress = &esov1alpha1.IngressConfig{
+		Kind: esov1alpha1.IngressKindTraefik,
+		TLS:  []esov1alpha1.IngressTLS{esov1alpha1.IngressTLS{SecretName: "example-tls"}},
+		Traefik: &esov1alpha1.TraefikConfig{
+			EntryPoints: []string{"websecure"},
+			Middlewares: []esov1alpha1.TraefikMiddlewareRef{esov1alpha1.TraefikMiddlewareRef{Name: "retry"}},
+		},
+	}
+
+	routes := createTraefikIngressRouteCrs(instance)
+
+	testutils.ExpectEqInt(int32(len(routes)), 1, t)
+	testutils.ExpectEqStr(routes[0].GetName(), "TestService", t)
+	entryPoints, _, _ := unstructured.NestedStringSlice(routes[0].Object, "spec", "entryPoints")
+	testutils.ExpectEqStr(entryPoints[0], "websecure", t)
+	secretName, _, _ := unstructured.NestedString(routes[0].Object, "spec", "tls", "secretName")
+	testutils.ExpectEqStr(secretName, "example-tls", t)
+
+	rules, _, _ := unstructured.NestedSlice(routes[0].Object, "spec", "routes")
+	testutils.ExpectEqInt(int32(len(rules)), 2, t)
+	testutils.ExpectEqStr(rules[0].(map[string]interface{})["match"].(string), "Host(`subdomain.example.com`)", t)
+	testutils.ExpectEqStr(rules[1].(map[string]interface{})["match"].(string), "Host(`another.domain.com`) && PathPrefix(`/foo`)", t)
+	middlewares, _, _ := unstructured.NestedSlice(rules[0].(map[string]interface{}), "middlewares")
+	testutils.ExpectEqStr(middlewares[0].(map[string]interface{})["namespace"].(string), "external-services", t)
+}
+
+func TestCreateContourHTTPProxyCrs(t *testing.T) {
+	instance := getTestExternalServiceCR()
+	instance.Spec.Hosts = append(instance.Spec.Hosts, esov1alpha1.ExternalServiceHostPath{Host: "another.domain.com", Path: "/bar"})
+	instance.Spec.Ingress = &esov1alpha1.IngressConfig{
+		Kind: esov1alpha1.IngressKindContour,
+		TLS:  []esov1alpha1.IngressTLS{esov1alpha1.IngressTLS{Hosts: []string{"another.domain.com"}, SecretName: "another-tls"}},
+		Contour: &esov1alpha1.ContourConfig{
+			IngressClassName: "contour",
+			RetryPolicy:      &esov1alpha1.ContourRetryPolicy{Count: 3, PerTryTimeout: "150ms"},
+		},
+	}
+
+	proxies := createContourHTTPProxyCrs(instance)
+
+	testutils.ExpectEqInt(int32(len(proxies)), 2, t)
+	testutils.ExpectEqStr(proxies[0].GetName(), "TestService", t)
+	testutils.ExpectEqStr(proxies[1].GetName(), "TestService-1", t)
+
+	fqdn, _, _ := unstructured.NestedString(proxies[0].Object, "spec", "virtualhost", "fqdn")
+	testutils.ExpectEqStr(fqdn, "subdomain.example.com", t)
+	if _, found, _ := unstructured.NestedMap(proxies[0].Object, "spec", "virtualhost", "tls"); found {
+		t.Errorf("Expected no TLS for a host without certificate")
+	}
+	secretName, _, _ := unstructured.NestedString(proxies[1].Object, "spec", "virtualhost", "tls", "secretName")
+	testutils.ExpectEqStr(secretName, "another-tls", t)
+
+	routes, _, _ := unstructured.NestedSlice(proxies[1].Object, "spec", "routes")
+	testutils.ExpectEqInt(int32(len(routes)), 2, t)
+	conditions, _, _ := unstructured.NestedSlice(routes[1].(map[string]interface{}), "conditions")
+	testutils.ExpectEqStr(conditions[0].(map[string]interface{})["prefix"].(string), "/bar", t)
+	count, _, _ := unstructured.NestedInt64(routes[1].(map[string]interface{}), "retryPolicy", "count")
+	testutils.ExpectEqInt(int32(count), 3, t)
+}
+
+func TestCreateIngressCrWithTLS(t *testing.T) {
+	instance := getTestExternalServiceCR()
+	instance.Spec.Ingress = &esov1alpha1.IngressConfig{
+		TLS: []esov1alpha1.IngressTLS{esov1alpha1.IngressTLS{Hosts: []string{"subdomain.example.com"}, SecretName: "example-tls"}},
+	}
+
+	ingress := createIngressCr(instance)
+
+	testutils.ExpectEqInt(int32(len(ingress.Spec.TLS)), 1, t)
+	testutils.ExpectEqStr(ingress.Spec.TLS[0].SecretName, "example-tls", t)
+}
+
+func TestReconcileRoutesSwitchesKind(t *testing.T) {
+	// Given an ExternalService with a plain Ingress
+	instance := getTestExternalServiceCR()
+	client := testutils.InitFakeClient(instance)
+
+	if _, err := newTestReconciler(client).reconcileRoutes(instance, log); err != nil {
+		t.Fatalf("reconcile: (%v)", err)
+	}
+	if _, err := getRuntimeIngress(client, instance.Name, instance.Namespace); err != nil {
+		t.Fatalf("get Ingress: (%v)", err)
+	}
+
+	// When it switches to Contour
+	instance.Spec.Ingress = &esov1alpha1.IngressConfig{Kind: esov1alpha1.IngressKindContour}
+	if _, err := newTestReconciler(client).reconcileRoutes(instance, log); err != nil {
+		t.Fatalf("reconcile: (%v)", err)
+	}
+
+	// Then the Ingress is replaced by one HTTPProxy per host
+	if _, err := getRuntimeIngress(client, instance.Name, instance.Namespace); !errors.IsNotFound(err) {
+		t.Errorf("Expected Ingress to be deleted, but got %v", err)
+	}
+	for _, name := range []string{"TestService", "TestService-1"} {
+		if _, err := getRuntimeUnstructured(client, contourHTTPProxyGVK, name, instance.Namespace); err != nil {
+			t.Errorf("get HTTPProxy %v: (%v)", name, err)
+		}
+	}
+
+	// And when a host is removed, its HTTPProxy is deleted
+	instance.Spec.Hosts = instance.Spec.Hosts[:1]
+	if _, err := newTestReconciler(client).reconcileRoutes(instance, log); err != nil {
+		t.Fatalf("reconcile: (%v)", err)
+	}
+	if _, err := getRuntimeUnstructured(client, contourHTTPProxyGVK, "TestService-1", instance.Namespace); !errors.IsNotFound(err) {
+		t.Errorf("Expected HTTPProxy to be deleted, but got %v", err)
+	}
+
+	// And when it switches to Traefik, the HTTPProxies are deleted
+	instance.Spec.Ingress.Kind = esov1alpha1.IngressKindTraefik
+	if _, err := newTestReconciler(client).reconcileRoutes(instance, log); err != nil {
+		t.Fatalf("reconcile: (%v)", err)
+	}
+	if _, err := getRuntimeUnstructured(client, contourHTTPProxyGVK, "TestService", instance.Namespace); !errors.IsNotFound(err) {
+		t.Errorf("Expected HTTPProxy to be deleted, but got %v", err)
+	}
+	if _, err := getRuntimeUnstructured(client, traefikIngressRouteGVK, "TestService", instance.Namespace); err != nil {
+		t.Errorf("get IngressRoute: (%v)", err)
+	}
+}
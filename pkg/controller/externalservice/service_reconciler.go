@@ -2,7 +2,6 @@ package externalservice
 
 import (
 	"context"
-	"reflect"
 	"time"
 
 	esov1alpha1 "github.com/CrowdfoxGmbH/external-service-operator/pkg/apis/eso/v1alpha1"
@@ -10,8 +9,10 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
 	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/api/equality"
 	"k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 )
@@ -39,16 +40,42 @@ func (r *ReconcileExternalService) reconcileService(instance *esov1alpha1.Extern
 		return reconcile.Result{}, err
 	}
 
-	newService := createServiceCr(instance)
-	if !reflect.DeepEqual(*newService, *found) {
-		reqLogger.Info("Specs Changed for Service. Trying to update", "namespace", found.Namespace, "service", found.Name)
-		newService.ObjectMeta.ResourceVersion = found.ObjectMeta.ResourceVersion
+	if !metav1.IsControlledBy(found, instance) {
+		reqLogger.Info("Skip reconcile: Service is not owned by the ExternalService", "namespace", found.Namespace, "service", found.Name)
+		return reconcile.Result{}, nil
+	}
+
+	// a Service being deleted for a recreate is created again once it is gone
+	if found.DeletionTimestamp != nil {
+		reqLogger.Info("Service is being deleted, waiting to recreate it", "namespace", found.Namespace, "service", found.Name)
+		return reconcile.Result{
+			RequeueAfter: time.Second,
+			Requeue:      true,
+		}, nil
+	}
 
-		if err := controllerutil.SetControllerReference(instance, newService, r.scheme); err != nil {
+	newService := createServiceCr(instance)
+	if serviceNeedsRecreate(newService, found) {
+		reqLogger.Info("Immutable fields changed for Service. Deleting it to recreate it", "namespace", found.Namespace, "service", found.Name)
+		if err := r.client.Delete(context.TODO(), found); err != nil && !errors.IsNotFound(err) {
 			return reconcile.Result{}, err
 		}
 
-		if err = r.client.Update(context.TODO(), newService); err == nil {
+		return reconcile.Result{
+			RequeueAfter: time.Second,
+			Requeue:      true,
+		}, nil
+	}
+
+	merged := mergeService(found, newService)
+	if err := controllerutil.SetControllerReference(instance, merged, r.scheme); err != nil {
+		return reconcile.Result{}, err
+	}
+
+	if !equality.Semantic.DeepEqual(merged, found) {
+		reqLogger.Info("Specs Changed for Service. Trying to update", "namespace", found.Namespace, "service", found.Name)
+
+		if err = r.client.Update(context.TODO(), merged); err == nil {
 			reqLogger.Info("Updated Service", "namespace", found.Namespace, "service", found.Name)
 		}
 
@@ -62,17 +89,99 @@ func (r *ReconcileExternalService) reconcileService(instance *esov1alpha1.Extern
 	return reconcile.Result{}, nil
 }
 
-func createServiceCr(i *esov1alpha1.ExternalService) *corev1.Service {
-	labels := map[string]string{
-		"app":         i.Name,
-		"serviceType": "external",
+// serviceNeedsRecreate reports whether the found Service can not be updated to the wanted one,
+// because the cluster IP is immutable once it is set
+func serviceNeedsRecreate(wanted *corev1.Service, found *corev1.Service) bool {
+	// ExternalName Services have no cluster IP, switching from or to them is a normal update
+	if wanted.Spec.Type == corev1.ServiceTypeExternalName || found.Spec.Type == corev1.ServiceTypeExternalName {
+		return false
+	}
+
+	switch wanted.Spec.ClusterIP {
+	case found.Spec.ClusterIP:
+		return false
+	case "":
+		// a virtual IP is wanted, any allocated one is fine but a headless Service has none
+		return found.Spec.ClusterIP == corev1.ClusterIPNone
+	default:
+		return found.Spec.ClusterIP != ""
+	}
+}
+
+// mergeService applies the fields the operator owns to the found Service. Fields defaulted
+// by the API server, like an allocated cluster IP or node ports, and fields set by others are kept.
+func mergeService(found *corev1.Service, wanted *corev1.Service) *corev1.Service {
+	merged := found.DeepCopy()
+	mergeManagedMetadata(&merged.ObjectMeta, wanted.ObjectMeta)
+
+	spec := &merged.Spec
+	spec.Type = wanted.Spec.Type
+	spec.ExternalName = wanted.Spec.ExternalName
+	spec.Ports = mergeServicePorts(wanted.Spec, found.Spec.Ports)
+
+	// an empty cluster IP asks for an allocated one, which can not be changed anyway
+	if wanted.Spec.ClusterIP != "" || wanted.Spec.Type == corev1.ServiceTypeExternalName {
+		spec.ClusterIP = wanted.Spec.ClusterIP
 	}
 
-	return &corev1.Service{
+	spec.SessionAffinity = wanted.Spec.SessionAffinity
+	if spec.SessionAffinity == "" {
+		spec.SessionAffinity = corev1.ServiceAffinityNone
+	}
+	if spec.SessionAffinity == corev1.ServiceAffinityNone {
+		spec.SessionAffinityConfig = nil
+	}
+
+	if spec.Type == corev1.ServiceTypeNodePort || spec.Type == corev1.ServiceTypeLoadBalancer {
+		if wanted.Spec.ExternalTrafficPolicy != "" {
+			spec.ExternalTrafficPolicy = wanted.Spec.ExternalTrafficPolicy
+		} else if spec.ExternalTrafficPolicy == "" {
+			spec.ExternalTrafficPolicy = corev1.ServiceExternalTrafficPolicyTypeCluster
+		}
+	} else {
+		spec.ExternalTrafficPolicy = ""
+	}
+	if spec.Type != corev1.ServiceTypeLoadBalancer || spec.ExternalTrafficPolicy != corev1.ServiceExternalTrafficPolicyTypeLocal {
+		spec.HealthCheckNodePort = 0
+	}
+
+	return merged
+}
+
+// mergeServicePorts returns the wanted ports with the defaults of the API server applied and
+// the node ports which were already allocated for them
+func mergeServicePorts(wanted corev1.ServiceSpec, found []corev1.ServicePort) []corev1.ServicePort {
+	exposed := wanted.Type == corev1.ServiceTypeNodePort || wanted.Type == corev1.ServiceTypeLoadBalancer
+
+	ports := []corev1.ServicePort{}
+	for _, port := range wanted.Ports {
+		if port.Protocol == "" {
+			port.Protocol = corev1.ProtocolTCP
+		}
+		if port.TargetPort == (intstr.IntOrString{}) {
+			port.TargetPort = intstr.FromInt(int(port.Port))
+		}
+
+		if !exposed {
+			port.NodePort = 0
+		} else if port.NodePort == 0 {
+			for _, foundPort := range found {
+				if foundPort.Port == port.Port && foundPort.Protocol == port.Protocol {
+					port.NodePort = foundPort.NodePort
+				}
+			}
+		}
+
+		ports = append(ports, port)
+	}
+	return ports
+}
+
+func createServiceCr(i *esov1alpha1.ExternalService) *corev1.Service {
+	service := &corev1.Service{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      i.Name,
 			Namespace: i.Namespace,
-			Labels:    labels,
 		},
 		Spec: corev1.ServiceSpec{
 			Ports: []corev1.ServicePort{
@@ -80,8 +189,35 @@ func createServiceCr(i *esov1alpha1.ExternalService) *corev1.Service {
 					Port: i.Spec.Port,
 				},
 			},
-			ClusterIP: "None",
+			ClusterIP: corev1.ClusterIPNone,
 			Type:      corev1.ServiceTypeClusterIP,
 		},
 	}
+
+	config := i.Spec.Service
+	if config == nil {
+		setManagedMetadata(&service.ObjectMeta, i, nil, nil)
+		return service
+	}
+
+	setManagedMetadata(&service.ObjectMeta, i, config.Labels, config.Annotations)
+
+	// labels or annotations alone keep the headless Service, changing it would recreate it
+	if config.Type != "" || config.ClusterIP != "" {
+		service.Spec.ClusterIP = config.ClusterIP
+	}
+	if config.Type != "" {
+		service.Spec.Type = config.Type
+	}
+	service.Spec.SessionAffinity = config.SessionAffinity
+
+	switch service.Spec.Type {
+	case corev1.ServiceTypeExternalName:
+		service.Spec.ClusterIP = ""
+		service.Spec.ExternalName = config.ExternalName
+	case corev1.ServiceTypeNodePort, corev1.ServiceTypeLoadBalancer:
+		service.Spec.ExternalTrafficPolicy = config.ExternalTrafficPolicy
+	}
+
+	return service
 }
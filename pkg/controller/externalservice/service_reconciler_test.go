@@ -1,11 +1,65 @@
 package externalservice
 
 import (
+	esov1alpha1 "github.com/CrowdfoxGmbH/external-service-operator/pkg/apis/eso/v1alpha1"
 	"github.com/CrowdfoxGmbH/external-service-operator/pkg/testutils"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 	"testing"
+	"time"
 )
 
+// defaultService returns the Service like the API server stores it after it got created
+// from the given one, with some additions of other controllers
+func defaultService(instance *esov1alpha1.ExternalService, service *corev1.Service) *corev1.Service {
+	defaulted := service.DeepCopy()
+	controllerutil.SetControllerReference(instance, defaulted, scheme.Scheme)
+
+	defaulted.UID = "3b4fd3f6-1f2b-11ea-a5e4-0242ac110002"
+	defaulted.ResourceVersion = "4242"
+	defaulted.SelfLink = "/api/v1/namespaces/" + defaulted.Namespace + "/services/" + defaulted.Name
+	defaulted.CreationTimestamp = metav1.NewTime(time.Date(2019, 12, 16, 10, 0, 0, 0, time.UTC))
+	if defaulted.Annotations == nil {
+		defaulted.Annotations = map[string]string{}
+	}
+	defaulted.Annotations["external-dns.alpha.kubernetes.io/hostname"] = "service.example.com"
+
+	spec := &defaulted.Spec
+	if spec.ClusterIP == "" && spec.Type != corev1.ServiceTypeExternalName {
+		spec.ClusterIP = "10.96.12.34"
+	}
+	if spec.SessionAffinity == "" {
+		spec.SessionAffinity = corev1.ServiceAffinityNone
+	}
+	if spec.SessionAffinity == corev1.ServiceAffinityClientIP {
+		timeout := int32(10800)
+		spec.SessionAffinityConfig = &corev1.SessionAffinityConfig{ClientIP: &corev1.ClientIPConfig{TimeoutSeconds: &timeout}}
+	}
+
+	exposed := spec.Type == corev1.ServiceTypeNodePort || spec.Type == corev1.ServiceTypeLoadBalancer
+	if exposed && spec.ExternalTrafficPolicy == "" {
+		spec.ExternalTrafficPolicy = corev1.ServiceExternalTrafficPolicyTypeCluster
+	}
+	for i := range spec.Ports {
+		port := &spec.Ports[i]
+		port.Protocol = corev1.ProtocolTCP
+		port.TargetPort = intstr.FromInt(int(port.Port))
+		if exposed {
+			port.NodePort = 30000 + int32(i)
+		}
+	}
+
+	if spec.Type == corev1.ServiceTypeLoadBalancer {
+		defaulted.Status.LoadBalancer.Ingress = []corev1.LoadBalancerIngress{corev1.LoadBalancerIngress{IP: "203.0.113.10"}}
+	}
+
+	return defaulted
+}
+
 func TestCreateServiceCr(t *testing.T) {
 
 	service := createServiceCr(getTestExternalServiceCR())
@@ -21,3 +75,124 @@ func TestCreateServiceCr(t *testing.T) {
 	testutils.ExpectServiceType(service.Spec.Type, corev1.ServiceTypeClusterIP, t)
 
 }
+
+func TestCreateServiceCrWithLoadBalancer(t *testing.T) {
+	instance := getTestExternalServiceCR()
+	instance.Spec.Service = &esov1alpha1.ServiceConfig{
+		Type:                  corev1.ServiceTypeLoadBalancer,
+		SessionAffinity:       corev1.ServiceAffinityClientIP,
+		ExternalTrafficPolicy: corev1.ServiceExternalTrafficPolicyTypeLocal,
+	}
+
+	service := createServiceCr(instance)
+
+	testutils.ExpectServiceType(service.Spec.Type, corev1.ServiceTypeLoadBalancer, t)
+	testutils.ExpectEqStr(service.Spec.ClusterIP, "", t)
+	testutils.ExpectEqStr(string(service.Spec.SessionAffinity), string(corev1.ServiceAffinityClientIP), t)
+	testutils.ExpectEqStr(string(service.Spec.ExternalTrafficPolicy), string(corev1.ServiceExternalTrafficPolicyTypeLocal), t)
+}
+
+func TestCreateServiceCrWithExternalName(t *testing.T) {
+	instance := getTestExternalServiceCR()
+	instance.Spec.Service = &esov1alpha1.ServiceConfig{
+		Type:         corev1.ServiceTypeExternalName,
+		ClusterIP:    "10.96.0.50",
+		ExternalName: "db.example.com",
+	}
+
+	service := createServiceCr(instance)
+
+	testutils.ExpectServiceType(service.Spec.Type, corev1.ServiceTypeExternalName, t)
+	testutils.ExpectEqStr(service.Spec.ExternalName, "db.example.com", t)
+	testutils.ExpectEqStr(service.Spec.ClusterIP, "", t)
+}
+
+func TestCreateServiceCrWithLabelsOnlyStaysHeadless(t *testing.T) {
+	instance := getTestExternalServiceCR()
+	instance.Spec.Service = &esov1alpha1.ServiceConfig{
+		Labels:      map[string]string{"team": "payments"},
+		Annotations: map[string]string{"prometheus.io/scrape": "true"},
+	}
+
+	service := createServiceCr(instance)
+	testutils.ExpectEqStr(service.Spec.ClusterIP, corev1.ClusterIPNone, t)
+	testutils.ExpectServiceType(service.Spec.Type, corev1.ServiceTypeClusterIP, t)
+	testutils.ExpectEqStr(service.Labels["team"], "payments", t)
+
+	// so a headless Service created without the block is not recreated
+	found := defaultService(instance, createServiceCr(getTestExternalServiceCR()))
+	testutils.ExpectFalse(serviceNeedsRecreate(service, found), t)
+}
+
+func TestServiceNeedsRecreate(t *testing.T) {
+	service := func(serviceType corev1.ServiceType, clusterIP string) *corev1.Service {
+		return &corev1.Service{Spec: corev1.ServiceSpec{Type: serviceType, ClusterIP: clusterIP}}
+	}
+
+	tests := []struct {
+		wanted   *corev1.Service
+		found    *corev1.Service
+		recreate bool
+	}{
+		{service(corev1.ServiceTypeClusterIP, "None"), service(corev1.ServiceTypeClusterIP, "None"), false},
+		{service(corev1.ServiceTypeClusterIP, ""), service(corev1.ServiceTypeClusterIP, "10.96.0.10"), false},
+		{service(corev1.ServiceTypeClusterIP, ""), service(corev1.ServiceTypeClusterIP, "None"), true},
+		{service(corev1.ServiceTypeClusterIP, "None"), service(corev1.ServiceTypeClusterIP, "10.96.0.10"), true},
+		{service(corev1.ServiceTypeClusterIP, "10.96.0.20"), service(corev1.ServiceTypeClusterIP, "10.96.0.10"), true},
+		{service(corev1.ServiceTypeNodePort, ""), service(corev1.ServiceTypeClusterIP, "10.96.0.10"), false},
+		{service(corev1.ServiceTypeExternalName, ""), service(corev1.ServiceTypeClusterIP, "10.96.0.10"), false},
+		{service(corev1.ServiceTypeClusterIP, "None"), service(corev1.ServiceTypeExternalName, ""), false},
+	}
+
+	for _, test := range tests {
+		if actual := serviceNeedsRecreate(test.wanted, test.found); actual != test.recreate {
+			t.Errorf("Expected recreate to be %v from %v/%v to %v/%v but got %v", test.recreate,
+				test.found.Spec.Type, test.found.Spec.ClusterIP, test.wanted.Spec.Type, test.wanted.Spec.ClusterIP, actual)
+		}
+	}
+}
+
+func TestMergeServiceKeepsServerDefaults(t *testing.T) {
+	configs := []*esov1alpha1.ServiceConfig{
+		nil,
+		&esov1alpha1.ServiceConfig{},
+		&esov1alpha1.ServiceConfig{Type: corev1.ServiceTypeNodePort},
+		&esov1alpha1.ServiceConfig{Type: corev1.ServiceTypeLoadBalancer, SessionAffinity: corev1.ServiceAffinityClientIP},
+		&esov1alpha1.ServiceConfig{Type: corev1.ServiceTypeExternalName, ExternalName: "db.example.com"},
+	}
+
+	for _, config := range configs {
+		instance := getTestExternalServiceCR()
+		instance.Spec.Service = config
+		wanted := createServiceCr(instance)
+		found := defaultService(instance, wanted)
+
+		merged := mergeService(found, wanted)
+		controllerutil.SetControllerReference(instance, merged, scheme.Scheme)
+
+		if !equality.Semantic.DeepEqual(merged, found) {
+			t.Errorf("Expected no change for defaulted Service with config %+v but got:\n  (merged) %+v\n!=\n  (found)  %+v", config, merged, found)
+		}
+	}
+}
+
+func TestMergeServiceSwitchToClusterIP(t *testing.T) {
+	instance := getTestExternalServiceCR()
+	instance.Spec.Service = &esov1alpha1.ServiceConfig{
+		Type:                  corev1.ServiceTypeLoadBalancer,
+		SessionAffinity:       corev1.ServiceAffinityClientIP,
+		ExternalTrafficPolicy: corev1.ServiceExternalTrafficPolicyTypeLocal,
+	}
+	found := defaultService(instance, createServiceCr(instance))
+
+	instance.Spec.Service = &esov1alpha1.ServiceConfig{Type: corev1.ServiceTypeClusterIP}
+	merged := mergeService(found, createServiceCr(instance))
+
+	testutils.ExpectServiceType(merged.Spec.Type, corev1.ServiceTypeClusterIP, t)
+	testutils.ExpectEqStr(merged.Spec.ClusterIP, "10.96.12.34", t)
+	testutils.ExpectEqInt(merged.Spec.Ports[0].NodePort, 0, t)
+	testutils.ExpectEqStr(string(merged.Spec.ExternalTrafficPolicy), "", t)
+	testutils.ExpectEqStr(string(merged.Spec.SessionAffinity), string(corev1.ServiceAffinityNone), t)
+	testutils.ExpectTrue(merged.Spec.SessionAffinityConfig == nil, t)
+	testutils.ExpectEqStr(merged.Annotations["external-dns.alpha.kubernetes.io/hostname"], "service.example.com", t)
+}
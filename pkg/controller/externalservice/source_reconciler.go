@@ -0,0 +1,113 @@
+package externalservice
+
+import (
+	"context"
+
+	esov1alpha1 "github.com/CrowdfoxGmbH/external-service-operator/pkg/apis/eso/v1alpha1"
+	"github.com/CrowdfoxGmbH/external-service-operator/pkg/sources"
+
+	"github.com/go-logr/logr"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+)
+
+// reconcileSources keeps a worker running for every address source. The workers write the
+// discovered addresses to the status, which triggers another reconcile of the ExternalService.
+// Sources watching objects of the cluster are discovered right away instead.
+func (r *ReconcileExternalService) reconcileSources(instance *esov1alpha1.ExternalService, reqLogger logr.Logger) (reconcile.Result, error) {
+	r.sourceManager.UpdateSources(instance)
+
+	changed := sources.SyncWatchedSources(r.client, r.sourceClient, instance)
+	changed = sources.PruneStatus(instance) || changed
+	result := reconcile.Result{RequeueAfter: sources.PollInterval(instance)}
+	if !changed {
+		return result, nil
+	}
+
+	reqLogger.Info("Updating status of address sources")
+	return result, nil
+}
+
+// sourceLabel marks the ConfigMaps and Secrets read by file sources. Only objects carrying it
+// are watched and cached, changes of others are picked up at the interval of the source.
+const sourceLabel = "eso.crowdfox.com/source"
+
+// watchSourceObjects watches the labeled ConfigMaps and Secrets. They are watched through their
+// own informers, so the manager doesn't cache every ConfigMap and Secret of the cluster.
+func watchSourceObjects(mgr manager.Manager, c controller.Controller) error {
+	clientset, err := kubernetes.NewForConfig(mgr.GetConfig())
+	if err != nil {
+		return err
+	}
+	factory := informers.NewFilteredSharedInformerFactory(clientset, 0, metav1.NamespaceAll, func(options *metav1.ListOptions) {
+		options.LabelSelector = sourceLabel
+	})
+
+	err = c.Watch(&source.Informer{Informer: factory.Core().V1().ConfigMaps().Informer()}, &handler.EnqueueRequestsFromMapFunc{
+		ToRequests: enqueueForSources(mgr.GetClient(), matchesConfigMap),
+	})
+	if err != nil {
+		return err
+	}
+	err = c.Watch(&source.Informer{Informer: factory.Core().V1().Secrets().Informer()}, &handler.EnqueueRequestsFromMapFunc{
+		ToRequests: enqueueForSources(mgr.GetClient(), matchesSecret),
+	})
+	if err != nil {
+		return err
+	}
+
+	return mgr.Add(manager.RunnableFunc(func(stop <-chan struct{}) error {
+		factory.Start(stop)
+		<-stop
+		return nil
+	}))
+}
+
+// enqueueForSources returns a mapper enqueueing every ExternalService with a source the
+// changed object matches. Sources only read objects of their own namespace, so only those
+// ExternalServices are listed, cluster scoped objects like Nodes list all of them.
+func enqueueForSources(c client.Client, matches func(source esov1alpha1.AddressSource, namespace string, object handler.MapObject) bool) handler.ToRequestsFunc {
+	return func(object handler.MapObject) []reconcile.Request {
+		externalServices := &esov1alpha1.ExternalServiceList{}
+		if err := c.List(context.TODO(), &client.ListOptions{Namespace: object.Meta.GetNamespace()}, externalServices); err != nil {
+			log.Error(err, "Could not list ExternalServices")
+			return nil
+		}
+
+		requests := []reconcile.Request{}
+		for _, externalService := range externalServices.Items {
+			for _, source := range externalService.Spec.Sources {
+				if matches(source, externalService.Namespace, object) {
+					requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Name: externalService.Name, Namespace: externalService.Namespace}})
+					break
+				}
+			}
+		}
+		return requests
+	}
+}
+
+// matchesNodes matches every Node for sources using Nodes, the selector is applied on discovery
+func matchesNodes(source esov1alpha1.AddressSource, _ string, _ handler.MapObject) bool {
+	return source.Nodes != nil
+}
+
+// matchesConfigMap matches the ConfigMap a file source reads from
+func matchesConfigMap(source esov1alpha1.AddressSource, namespace string, object handler.MapObject) bool {
+	return source.File != nil && source.File.ConfigMapKeyRef != nil && namespace == object.Meta.GetNamespace() &&
+		source.File.ConfigMapKeyRef.Name == object.Meta.GetName()
+}
+
+// matchesSecret matches the Secret a file source reads from
+func matchesSecret(source esov1alpha1.AddressSource, namespace string, object handler.MapObject) bool {
+	return source.File != nil && source.File.SecretKeyRef != nil && namespace == object.Meta.GetNamespace() &&
+		source.File.SecretKeyRef.Name == object.Meta.GetName()
+}
@@ -0,0 +1,157 @@
+package externalservice
+
+import (
+	"testing"
+	"time"
+
+	esov1alpha1 "github.com/CrowdfoxGmbH/external-service-operator/pkg/apis/eso/v1alpha1"
+	"github.com/CrowdfoxGmbH/external-service-operator/pkg/testutils"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+)
+
+func getTestNode(name string, ip string) *corev1.Node {
+	return &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Labels: map[string]string{"role": "agent"}},
+		Status: corev1.NodeStatus{
+			Conditions: []corev1.NodeCondition{corev1.NodeCondition{Type: corev1.NodeReady, Status: corev1.ConditionTrue}},
+			Addresses:  []corev1.NodeAddress{corev1.NodeAddress{Type: corev1.NodeInternalIP, Address: ip}},
+		},
+	}
+}
+
+func getTestNodesExternalServiceCR() *esov1alpha1.ExternalService {
+	instance := getTestExternalServiceCR()
+	instance.Spec.Ips = nil
+	instance.Spec.Sources = []esov1alpha1.AddressSource{
+		esov1alpha1.AddressSource{Nodes: &esov1alpha1.NodesSource{
+			Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"role": "agent"}},
+		}},
+	}
+	return instance
+}
+
+func TestReconcileSourcesFollowsNodes(t *testing.T) {
+	// Given an ExternalService using the agent Nodes, without a probe its addresses are ready right away
+	instance := getTestNodesExternalServiceCR()
+	client := testutils.InitFakeClient(instance, getTestNode("agent-1", "10.0.0.1"))
+
+	if _, err := runTestReconcile(client, instance.Name, instance.Namespace); err != nil {
+		t.Fatalf("reconcile: (%v)", err)
+	}
+	endpoint, err := getRuntimeEndpoint(client, instance.Name, instance.Namespace)
+	if err != nil {
+		t.Fatalf("get Endpoint: (%v)", err)
+	}
+	testutils.ExpectEqStr(endpoint.Subsets[0].Addresses[0].IP, "10.0.0.1", t)
+	testutils.ExpectEqStr(*endpoint.Subsets[0].Addresses[0].NodeName, "agent-1", t)
+
+	// When another Node joins
+	createObject(client, getTestNode("agent-2", "10.0.0.2"))
+	if _, err := runTestReconcile(client, instance.Name, instance.Namespace); err != nil {
+		t.Fatalf("reconcile: (%v)", err)
+	}
+
+	// Then its address is added
+	externalService, _ := getRuntimeExternalService(client, instance.Name, instance.Namespace)
+	testutils.ExpectEqInt(int32(len(externalService.Status.Sources[0].Addresses)), 2, t)
+	testutils.ExpectEqStr(externalService.Status.Sources[0].Name, "nodes-0", t)
+	if _, err := runTestReconcile(client, instance.Name, instance.Namespace); err != nil {
+		t.Fatalf("reconcile: (%v)", err)
+	}
+	endpoint, _ = getRuntimeEndpoint(client, instance.Name, instance.Namespace)
+	testutils.ExpectEqInt(int32(len(endpoint.Subsets[0].Addresses)+len(endpoint.Subsets[0].NotReadyAddresses)), 2, t)
+}
+
+func TestEnqueueForSourcesMatchesNodes(t *testing.T) {
+	withNodes := getTestNodesExternalServiceCR()
+	withoutNodes := getTestExternalServiceCR()
+	withoutNodes.Name = "WithoutNodes"
+	client := testutils.InitFakeClient(withNodes, withoutNodes)
+
+	node := getTestNode("agent-1", "10.0.0.1")
+	requests := enqueueForSources(client, matchesNodes)(handler.MapObject{Meta: node, Object: node})
+
+	testutils.ExpectEqInt(int32(len(requests)), 1, t)
+	testutils.ExpectEqStr(requests[0].Name, "TestService", t)
+}
+
+func TestReconcileSourcesKeepsAddressesOnInvalidConfigMap(t *testing.T) {
+	// Given an ExternalService reading its addresses from a ConfigMap
+	instance := getTestExternalServiceCR()
+	instance.Spec.Ips = nil
+	instance.Spec.Sources = []esov1alpha1.AddressSource{
+		esov1alpha1.AddressSource{File: &esov1alpha1.FileSource{
+			ConfigMapKeyRef: &corev1.ConfigMapKeySelector{LocalObjectReference: corev1.LocalObjectReference{Name: "db-hosts"}, Key: "hosts"},
+		}},
+	}
+	configMap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "db-hosts", Namespace: instance.Namespace},
+		Data:       map[string]string{"hosts": "10.0.0.1\n10.0.0.2"},
+	}
+	client := testutils.InitFakeClient(instance, configMap)
+
+	if _, err := runTestReconcile(client, instance.Name, instance.Namespace); err != nil {
+		t.Fatalf("reconcile: (%v)", err)
+	}
+	externalService, _ := getRuntimeExternalService(client, instance.Name, instance.Namespace)
+	testutils.ExpectEqInt(int32(len(externalService.Status.Sources[0].Addresses)), 2, t)
+
+	// When an invalid entry is written
+	configMap.Data["hosts"] = "10.0.0.1\n10.0.0.3\nnot-an-ip"
+	updateObject(client, configMap)
+	if _, err := runTestReconcile(client, instance.Name, instance.Namespace); err != nil {
+		t.Fatalf("reconcile: (%v)", err)
+	}
+
+	// Then nothing of it is applied and the error is reported
+	externalService, _ = getRuntimeExternalService(client, instance.Name, instance.Namespace)
+	testutils.ExpectEqStr(externalService.Status.Sources[0].Addresses[1].IP, "10.0.0.2", t)
+	testutils.ExpectEqStr(externalService.Status.Sources[0].Error, `invalid address "not-an-ip"`, t)
+	testutils.ExpectEqStr(string(externalService.Status.GetCondition(esov1alpha1.SourcesSynced).Status), string(corev1.ConditionFalse), t)
+}
+
+func TestEnqueueForSourcesMatchesConfigMap(t *testing.T) {
+	instance := getTestExternalServiceCR()
+	instance.Spec.Sources = []esov1alpha1.AddressSource{
+		esov1alpha1.AddressSource{File: &esov1alpha1.FileSource{
+			ConfigMapKeyRef: &corev1.ConfigMapKeySelector{LocalObjectReference: corev1.LocalObjectReference{Name: "db-hosts"}, Key: "hosts"},
+		}},
+	}
+	client := testutils.InitFakeClient(instance)
+
+	configMap := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "db-hosts", Namespace: instance.Namespace}}
+	testutils.ExpectEqInt(int32(len(enqueueForSources(client, matchesConfigMap)(handler.MapObject{Meta: configMap, Object: configMap}))), 1, t)
+
+	configMap.Namespace = "other"
+	testutils.ExpectEqInt(int32(len(enqueueForSources(client, matchesConfigMap)(handler.MapObject{Meta: configMap, Object: configMap}))), 0, t)
+	testutils.ExpectEqInt(int32(len(enqueueForSources(client, matchesSecret)(handler.MapObject{Meta: configMap, Object: configMap}))), 0, t)
+}
+
+func TestReconcileSourcesPollsConfigMap(t *testing.T) {
+	// Given an ExternalService reading its addresses from a ConfigMap every 10 seconds
+	instance := getTestExternalServiceCR()
+	instance.Spec.Ips = nil
+	instance.Spec.Sources = []esov1alpha1.AddressSource{
+		esov1alpha1.AddressSource{IntervalSeconds: 10, File: &esov1alpha1.FileSource{
+			ConfigMapKeyRef: &corev1.ConfigMapKeySelector{LocalObjectReference: corev1.LocalObjectReference{Name: "db-hosts"}, Key: "hosts"},
+		}},
+	}
+	configMap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "db-hosts", Namespace: instance.Namespace},
+		Data:       map[string]string{"hosts": "10.0.0.1"},
+	}
+	client := testutils.InitFakeClient(instance, configMap)
+
+	// When it is reconciled
+	result, err := runTestReconcile(client, instance.Name, instance.Namespace)
+	if err != nil {
+		t.Fatalf("reconcile: (%v)", err)
+	}
+
+	// Then the ConfigMap is read again after the interval, in case it isn't labeled to be watched
+	if result.RequeueAfter != 10*time.Second {
+		t.Errorf("Expected a requeue after 10s, got %v", result.RequeueAfter)
+	}
+}
@@ -0,0 +1,358 @@
+package externalservice
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	esov1alpha1 "github.com/CrowdfoxGmbH/external-service-operator/pkg/apis/eso/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// splitBackend is a Service receiving a share of the traffic of the routes
+type splitBackend struct {
+	service string
+	weight  int64
+}
+
+// reconcileTrafficSplit creates a Service and Endpoints for every address group. The Endpoints
+// of a group take over the ready state of its addresses from the Endpoints of the
+// ExternalService, so probes apply to them as well. The effective weights are reported in the
+// status, from where the routes pick them up.
+func (r *ReconcileExternalService) reconcileTrafficSplit(instance *esov1alpha1.ExternalService, reqLogger logr.Logger) (reconcile.Result, error) {
+	groups := trafficSplitGroups(instance)
+
+	endpoint := &corev1.Endpoints{}
+	err := r.client.Get(context.TODO(), types.NamespacedName{Name: instance.Name, Namespace: instance.Namespace}, endpoint)
+	if err != nil && !errors.IsNotFound(err) {
+		return reconcile.Result{}, err
+	}
+
+	for _, group := range groups {
+		if result, err := r.reconcileGroupService(instance, group, reqLogger); err != nil {
+			return result, err
+		}
+		if result, err := r.reconcileGroupEndpoints(instance, group, endpoint.Subsets, reqLogger); err != nil {
+			return result, err
+		}
+	}
+
+	// the status remembers the groups, so the objects of removed groups can be found
+	for _, status := range instance.Status.TrafficSplit {
+		if !hasGroup(groups, status.Name) {
+			if err := r.removeGroup(instance, status.Service, reqLogger); err != nil {
+				return reconcile.Result{}, err
+			}
+		}
+	}
+
+	// until the Endpoints show up in the cache no address would be ready, so the status waits for them
+	if len(groups) > 0 && errors.IsNotFound(err) {
+		return reconcile.Result{}, nil
+	}
+	if !setTrafficSplitStatus(instance, groups, endpoint.Subsets) {
+		return reconcile.Result{}, nil
+	}
+
+	reqLogger.Info("Updating status of traffic split")
+	return reconcile.Result{}, nil
+}
+
+// trafficSplitGroups returns the configured groups. ExternalName Services have no addresses to split.
+func trafficSplitGroups(i *esov1alpha1.ExternalService) []esov1alpha1.AddressGroup {
+	if i.Spec.TrafficSplit == nil || i.Spec.IsExternalName() {
+		return nil
+	}
+	return i.Spec.TrafficSplit.Groups
+}
+
+func hasGroup(groups []esov1alpha1.AddressGroup, name string) bool {
+	for _, group := range groups {
+		if group.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// groupServiceName returns the name of the Service and Endpoints of an address group
+func groupServiceName(i *esov1alpha1.ExternalService, group string) string {
+	return fmt.Sprintf("%v-%v", i.Name, group)
+}
+
+func (r *ReconcileExternalService) reconcileGroupService(instance *esov1alpha1.ExternalService, group esov1alpha1.AddressGroup, reqLogger logr.Logger) (reconcile.Result, error) {
+	service := createGroupServiceCr(instance, group)
+	if err := controllerutil.SetControllerReference(instance, service, r.scheme); err != nil {
+		return reconcile.Result{}, err
+	}
+
+	found := &corev1.Service{}
+	err := r.client.Get(context.TODO(), types.NamespacedName{Name: service.Name, Namespace: service.Namespace}, found)
+	if err != nil && errors.IsNotFound(err) {
+		reqLogger.Info("Creating a new Service for address group", "namespace", service.Namespace, "service", service.Name)
+		return reconcile.Result{}, r.client.Create(context.TODO(), service)
+	} else if err != nil {
+		return reconcile.Result{}, err
+	}
+
+	merged := mergeService(found, service)
+	if err := controllerutil.SetControllerReference(instance, merged, r.scheme); err != nil {
+		return reconcile.Result{}, err
+	}
+	if equality.Semantic.DeepEqual(merged, found) {
+		return reconcile.Result{}, nil
+	}
+
+	reqLogger.Info("Specs changed. Trying to update Service of address group", "namespace", found.Namespace, "service", found.Name)
+	return reconcile.Result{
+		RequeueAfter: time.Second,
+		Requeue:      true,
+	}, r.client.Update(context.TODO(), merged)
+}
+
+// createGroupServiceCr creates the headless Service of an address group
+func createGroupServiceCr(i *esov1alpha1.ExternalService, group esov1alpha1.AddressGroup) *corev1.Service {
+	service := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      groupServiceName(i, group.Name),
+			Namespace: i.Namespace,
+		},
+		Spec: corev1.ServiceSpec{
+			Ports: []corev1.ServicePort{
+				corev1.ServicePort{
+					Port: i.Spec.Port,
+				},
+			},
+			ClusterIP: corev1.ClusterIPNone,
+			Type:      corev1.ServiceTypeClusterIP,
+		},
+	}
+	setManagedMetadata(&service.ObjectMeta, i, endpointLabels(i), nil)
+	return service
+}
+
+func (r *ReconcileExternalService) reconcileGroupEndpoints(instance *esov1alpha1.ExternalService, group esov1alpha1.AddressGroup, subsets []corev1.EndpointSubset, reqLogger logr.Logger) (reconcile.Result, error) {
+	endpoint := &corev1.Endpoints{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      groupServiceName(instance, group.Name),
+			Namespace: instance.Namespace,
+		},
+		Subsets: filterSubsets(subsets, group.GetIps(instance)),
+	}
+	setManagedMetadata(&endpoint.ObjectMeta, instance, endpointLabels(instance), nil)
+	if err := controllerutil.SetControllerReference(instance, endpoint, r.scheme); err != nil {
+		return reconcile.Result{}, err
+	}
+
+	found := &corev1.Endpoints{}
+	err := r.client.Get(context.TODO(), types.NamespacedName{Name: endpoint.Name, Namespace: endpoint.Namespace}, found)
+	if err != nil && errors.IsNotFound(err) {
+		reqLogger.Info("Creating a new Endpoint for address group", "Endpoint.Namespace", endpoint.Namespace, "Endpoint.Name", endpoint.Name)
+		return reconcile.Result{}, r.client.Create(context.TODO(), endpoint)
+	} else if err != nil {
+		return reconcile.Result{}, err
+	}
+
+	merged := found.DeepCopy()
+	mergeManagedMetadata(&merged.ObjectMeta, endpoint.ObjectMeta)
+	if reflect.DeepEqual(merged.ObjectMeta, found.ObjectMeta) && reflect.DeepEqual(endpointState(found.Subsets), endpointState(endpoint.Subsets)) {
+		return reconcile.Result{}, nil
+	}
+
+	merged.Subsets = endpoint.Subsets
+	reqLogger.Info("Updating Endpoint of address group", "Endpoint.Namespace", found.Namespace, "Endpoint.Name", found.Name)
+	return reconcile.Result{}, r.client.Update(context.TODO(), merged)
+}
+
+// filterSubsets returns the subsets with the given IPs only. Subsets left without addresses are removed.
+func filterSubsets(subsets []corev1.EndpointSubset, ips map[string]bool) []corev1.EndpointSubset {
+	filter := func(addresses []corev1.EndpointAddress) []corev1.EndpointAddress {
+		filtered := []corev1.EndpointAddress{}
+		for _, address := range addresses {
+			if ips[address.IP] {
+				filtered = append(filtered, address)
+			}
+		}
+		return filtered
+	}
+
+	result := []corev1.EndpointSubset{}
+	for _, subset := range subsets {
+		filtered := corev1.EndpointSubset{
+			Addresses:         filter(subset.Addresses),
+			NotReadyAddresses: filter(subset.NotReadyAddresses),
+			Ports:             subset.Ports,
+		}
+		if len(filtered.Addresses) > 0 || len(filtered.NotReadyAddresses) > 0 {
+			result = append(result, filtered)
+		}
+	}
+	return result
+}
+
+// endpointState maps every address and its ports to its ready state. The API server may
+// repack the subsets, so they are compared by this state instead of their order.
+func endpointState(subsets []corev1.EndpointSubset) map[string]bool {
+	state := map[string]bool{}
+	for _, subset := range subsets {
+		ports := []string{}
+		for _, port := range subset.Ports {
+			ports = append(ports, fmt.Sprintf("%v/%v", port.Port, port.Protocol))
+		}
+		for _, address := range subset.Addresses {
+			state[fmt.Sprintf("%v %v %v", address.IP, address.Hostname, strings.Join(ports, ","))] = true
+		}
+		for _, address := range subset.NotReadyAddresses {
+			state[fmt.Sprintf("%v %v %v", address.IP, address.Hostname, strings.Join(ports, ","))] = false
+		}
+	}
+	return state
+}
+
+// removeGroup deletes the Service and Endpoints of an address group which is not configured anymore
+func (r *ReconcileExternalService) removeGroup(instance *esov1alpha1.ExternalService, name string, reqLogger logr.Logger) error {
+	for _, object := range []runtime.Object{&corev1.Service{}, &corev1.Endpoints{}} {
+		err := r.client.Get(context.TODO(), types.NamespacedName{Name: name, Namespace: instance.Namespace}, object)
+		if errors.IsNotFound(err) {
+			continue
+		} else if err != nil {
+			return err
+		}
+
+		accessor := object.(metav1.Object)
+		if !metav1.IsControlledBy(accessor, instance) {
+			continue
+		}
+
+		reqLogger.Info("Deleting object of removed address group", "namespace", instance.Namespace, "name", name)
+		if err := r.client.Delete(context.TODO(), object); err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// setTrafficSplitStatus calculates the effective weights from the ready addresses of the
+// groups and sets them with the TrafficSplitActive condition. Returns true if the status changed.
+func setTrafficSplitStatus(instance *esov1alpha1.ExternalService, groups []esov1alpha1.AddressGroup, subsets []corev1.EndpointSubset) bool {
+	if len(groups) == 0 {
+		changed := len(instance.Status.TrafficSplit) > 0
+		instance.Status.TrafficSplit = nil
+		return instance.Status.RemoveCondition(esov1alpha1.TrafficSplitActive) || changed
+	}
+
+	ready := map[string]bool{}
+	for _, subset := range subsets {
+		for _, address := range subset.Addresses {
+			ready[address.IP] = true
+		}
+	}
+
+	statuses := []esov1alpha1.AddressGroupStatus{}
+	weights := []int32{}
+	anyReady := false
+	for _, group := range groups {
+		status := esov1alpha1.AddressGroupStatus{Name: group.Name, Service: groupServiceName(instance, group.Name)}
+		for ip := range group.GetIps(instance) {
+			if ready[ip] {
+				status.ReadyAddresses++
+			}
+		}
+		anyReady = anyReady || status.ReadyAddresses > 0
+		statuses = append(statuses, status)
+		weights = append(weights, group.Weight)
+	}
+
+	// without ready addresses in a group its share would fail, unless there is nothing to fail over to
+	if anyReady {
+		for index := range statuses {
+			if statuses[index].ReadyAddresses == 0 {
+				weights[index] = 0
+			}
+		}
+	}
+	for index, percent := range percentages(weights) {
+		statuses[index].Weight = percent
+	}
+
+	condition := esov1alpha1.ExternalServiceCondition{
+		Type:    esov1alpha1.TrafficSplitActive,
+		Status:  corev1.ConditionTrue,
+		Reason:  "WeightsApplied",
+		Message: splitMessage(statuses),
+	}
+	switch {
+	case !anyReady:
+		condition.Status = corev1.ConditionFalse
+		condition.Reason = "NoReadyGroup"
+		condition.Message = "No address group has ready addresses"
+	case ingressKind(instance) == esov1alpha1.IngressKindIngress && len(instance.Spec.Hosts) > 0 && len(groups) != 2:
+		condition.Status = corev1.ConditionFalse
+		condition.Reason = "UnsupportedGroups"
+		condition.Message = "An Ingress can only split the traffic between two groups by a canary Ingress"
+	}
+
+	changed := !reflect.DeepEqual(instance.Status.TrafficSplit, statuses)
+	instance.Status.TrafficSplit = statuses
+	return instance.Status.SetCondition(condition) || changed
+}
+
+// percentages converts the weights to percentages. The rounding remainder goes to the first
+// group with a weight, so they always add up to 100.
+func percentages(weights []int32) []int32 {
+	total := int32(0)
+	for _, weight := range weights {
+		total += weight
+	}
+
+	result := make([]int32, len(weights))
+	if total <= 0 {
+		return result
+	}
+
+	sum := int32(0)
+	for index, weight := range weights {
+		result[index] = weight * 100 / total
+		sum += result[index]
+	}
+	for index, weight := range weights {
+		if weight > 0 {
+			result[index] += 100 - sum
+			break
+		}
+	}
+	return result
+}
+
+func splitMessage(statuses []esov1alpha1.AddressGroupStatus) string {
+	shares := []string{}
+	for _, status := range statuses {
+		shares = append(shares, fmt.Sprintf("%v %d%%", status.Name, status.Weight))
+	}
+	return strings.Join(shares, ", ")
+}
+
+// splitBackends returns the Services of the address groups with their effective weights, or
+// nil if the traffic is not split
+func splitBackends(i *esov1alpha1.ExternalService) []splitBackend {
+	if len(trafficSplitGroups(i)) == 0 || len(i.Status.TrafficSplit) == 0 {
+		return nil
+	}
+
+	backends := []splitBackend{}
+	for _, status := range i.Status.TrafficSplit {
+		backends = append(backends, splitBackend{service: status.Service, weight: int64(status.Weight)})
+	}
+	return backends
+}
@@ -0,0 +1,190 @@
+package externalservice
+
+import (
+	"testing"
+
+	esov1alpha1 "github.com/CrowdfoxGmbH/external-service-operator/pkg/apis/eso/v1alpha1"
+	"github.com/CrowdfoxGmbH/external-service-operator/pkg/testutils"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func getTestSplitExternalServiceCR() *esov1alpha1.ExternalService {
+	instance := getTestExternalServiceCR()
+	instance.Spec.TrafficSplit = &esov1alpha1.TrafficSplitConfig{
+		Groups: []esov1alpha1.AddressGroup{
+			esov1alpha1.AddressGroup{Name: "old", Ips: []string{"10.0.100.10"}, Weight: 30},
+			esov1alpha1.AddressGroup{Name: "new", Ips: []string{"10.0.100.11", "10.0.100.12"}, Weight: 70},
+		},
+	}
+	return instance
+}
+
+func getTestSplitSubsets(ready ...string) []corev1.EndpointSubset {
+	subset := corev1.EndpointSubset{Ports: []corev1.EndpointPort{corev1.EndpointPort{Port: 80}}}
+	isReady := map[string]bool{}
+	for _, ip := range ready {
+		isReady[ip] = true
+	}
+	for _, ip := range []string{"10.0.100.10", "10.0.100.11", "10.0.100.12"} {
+		if isReady[ip] {
+			subset.Addresses = append(subset.Addresses, corev1.EndpointAddress{IP: ip})
+		} else {
+			subset.NotReadyAddresses = append(subset.NotReadyAddresses, corev1.EndpointAddress{IP: ip})
+		}
+	}
+	return []corev1.EndpointSubset{subset}
+}
+
+func TestSetTrafficSplitStatusUsesConfiguredWeights(t *testing.T) {
+	instance := getTestSplitExternalServiceCR()
+
+	testutils.ExpectTrue(setTrafficSplitStatus(instance, trafficSplitGroups(instance), getTestSplitSubsets("10.0.100.10", "10.0.100.12")), t)
+
+	testutils.ExpectEqInt(instance.Status.TrafficSplit[0].Weight, 30, t)
+	testutils.ExpectEqInt(instance.Status.TrafficSplit[1].Weight, 70, t)
+	testutils.ExpectEqInt(instance.Status.TrafficSplit[1].ReadyAddresses, 1, t)
+	testutils.ExpectEqStr(instance.Status.TrafficSplit[1].Service, "TestService-new", t)
+	condition := instance.Status.GetCondition(esov1alpha1.TrafficSplitActive)
+	testutils.ExpectEqStr(condition.Reason, "WeightsApplied", t)
+	testutils.ExpectEqStr(condition.Message, "old 30%, new 70%", t)
+
+	// nothing changes on the next calculation
+	testutils.ExpectFalse(setTrafficSplitStatus(instance, trafficSplitGroups(instance), getTestSplitSubsets("10.0.100.10", "10.0.100.12")), t)
+}
+
+func TestSetTrafficSplitStatusMovesShareOfUnreadyGroup(t *testing.T) {
+	instance := getTestSplitExternalServiceCR()
+
+	setTrafficSplitStatus(instance, trafficSplitGroups(instance), getTestSplitSubsets("10.0.100.10"))
+
+	testutils.ExpectEqInt(instance.Status.TrafficSplit[0].Weight, 100, t)
+	testutils.ExpectEqInt(instance.Status.TrafficSplit[1].Weight, 0, t)
+
+	// without any ready group the configured weights stay
+	setTrafficSplitStatus(instance, trafficSplitGroups(instance), getTestSplitSubsets())
+
+	testutils.ExpectEqInt(instance.Status.TrafficSplit[0].Weight, 30, t)
+	condition := instance.Status.GetCondition(esov1alpha1.TrafficSplitActive)
+	testutils.ExpectEqStr(string(condition.Status), string(corev1.ConditionFalse), t)
+	testutils.ExpectEqStr(condition.Reason, "NoReadyGroup", t)
+}
+
+func TestPercentagesAddUpToHundred(t *testing.T) {
+	result := percentages([]int32{1, 1, 1})
+
+	testutils.ExpectEqInt(result[0], 34, t)
+	testutils.ExpectEqInt(result[1], 33, t)
+	testutils.ExpectEqInt(result[2], 33, t)
+}
+
+func TestReconcileTrafficSplitCreatesGroupEndpoints(t *testing.T) {
+	// Given an ExternalService whose old group is ready
+	instance := getTestSplitExternalServiceCR()
+	client := testutils.InitFakeClient(instance)
+	if _, err := runTestReconcile(client, instance.Name, instance.Namespace); err != nil {
+		t.Fatalf("reconcile: (%v)", err)
+	}
+	endpoint, _ := getRuntimeEndpoint(client, instance.Name, instance.Namespace)
+	endpoint.Subsets = getTestSplitSubsets("10.0.100.10")
+	if err := updateObject(client, endpoint); err != nil {
+		t.Fatalf("update endpoint: (%v)", err)
+	}
+
+	if _, err := runTestReconcile(client, instance.Name, instance.Namespace); err != nil {
+		t.Fatalf("reconcile: (%v)", err)
+	}
+
+	// Then every group has its Service and Endpoints with the ready state of its addresses
+	service, err := getRuntimeService(client, "TestService-new", instance.Namespace)
+	if err != nil {
+		t.Fatalf("get group service: (%v)", err)
+	}
+	testutils.ExpectEqStr(service.Spec.ClusterIP, corev1.ClusterIPNone, t)
+
+	old, _ := getRuntimeEndpoint(client, "TestService-old", instance.Namespace)
+	testutils.ExpectEqInt(int32(len(old.Subsets[0].Addresses)), 1, t)
+	testutils.ExpectEqStr(old.Subsets[0].Addresses[0].IP, "10.0.100.10", t)
+	newGroup, _ := getRuntimeEndpoint(client, "TestService-new", instance.Namespace)
+	testutils.ExpectEqInt(int32(len(newGroup.Subsets[0].Addresses)), 0, t)
+	testutils.ExpectEqInt(int32(len(newGroup.Subsets[0].NotReadyAddresses)), 2, t)
+
+	// and the Ingress sends the share of the new group by a canary Ingress
+	ingress, _ := getRuntimeIngress(client, instance.Name, instance.Namespace)
+	testutils.ExpectEqStr(ingress.Spec.Rules[0].HTTP.Paths[0].Backend.ServiceName, "TestService-old", t)
+	canary, err := getRuntimeIngress(client, "TestService-canary", instance.Namespace)
+	if err != nil {
+		t.Fatalf("get canary ingress: (%v)", err)
+	}
+	testutils.ExpectEqStr(canary.Spec.Rules[0].HTTP.Paths[0].Backend.ServiceName, "TestService-new", t)
+	testutils.ExpectEqStr(canary.Annotations[nginxCanaryAnnotation], "true", t)
+	testutils.ExpectEqStr(canary.Annotations[nginxCanaryWeightAnnotation], "0", t)
+}
+
+func TestReconcileTrafficSplitWaitsForEndpoints(t *testing.T) {
+	// Given an ExternalService whose Endpoints are not in the cache yet
+	instance := getTestSplitExternalServiceCR()
+	client := testutils.InitFakeClient(instance)
+
+	if _, err := newTestReconciler(client).reconcileTrafficSplit(instance, log); err != nil {
+		t.Fatalf("reconcile traffic split: (%v)", err)
+	}
+
+	// Then no status is reported instead of no group being ready
+	testutils.ExpectEqInt(int32(len(instance.Status.TrafficSplit)), 0, t)
+	if instance.Status.GetCondition(esov1alpha1.TrafficSplitActive) != nil {
+		t.Errorf("Expected no TrafficSplitActive condition before the Endpoints exist")
+	}
+}
+
+func TestReconcileTrafficSplitRemovesGroups(t *testing.T) {
+	// Given a split ExternalService
+	instance := getTestSplitExternalServiceCR()
+	client := testutils.InitFakeClient(instance)
+	if _, err := runTestReconcile(client, instance.Name, instance.Namespace); err != nil {
+		t.Fatalf("reconcile: (%v)", err)
+	}
+
+	// When the split is removed
+	externalService, _ := getRuntimeExternalService(client, instance.Name, instance.Namespace)
+	externalService.Spec.TrafficSplit = nil
+	if err := updateObject(client, externalService); err != nil {
+		t.Fatalf("update external service: (%v)", err)
+	}
+	if _, err := runTestReconcile(client, instance.Name, instance.Namespace); err != nil {
+		t.Fatalf("reconcile: (%v)", err)
+	}
+
+	// Then the objects of the groups and the canary Ingress are deleted
+	if _, err := getRuntimeService(client, "TestService-old", instance.Namespace); !errors.IsNotFound(err) {
+		t.Errorf("Expected the Service of the removed group to be deleted, got (%v)", err)
+	}
+	if _, err := getRuntimeEndpoint(client, "TestService-new", instance.Namespace); !errors.IsNotFound(err) {
+		t.Errorf("Expected the Endpoints of the removed group to be deleted, got (%v)", err)
+	}
+	if _, err := getRuntimeIngress(client, "TestService-canary", instance.Namespace); !errors.IsNotFound(err) {
+		t.Errorf("Expected the canary Ingress to be deleted, got (%v)", err)
+	}
+	ingress, _ := getRuntimeIngress(client, instance.Name, instance.Namespace)
+	testutils.ExpectEqStr(ingress.Spec.Rules[0].HTTP.Paths[0].Backend.ServiceName, "TestService", t)
+	externalService, _ = getRuntimeExternalService(client, instance.Name, instance.Namespace)
+	testutils.ExpectEqInt(int32(len(externalService.Status.TrafficSplit)), 0, t)
+	if externalService.Status.GetCondition(esov1alpha1.TrafficSplitActive) != nil {
+		t.Errorf("Expected the TrafficSplitActive condition to be removed")
+	}
+}
+
+func TestCreateGatewayRouteCrWeightsGroups(t *testing.T) {
+	instance := getTestSplitExternalServiceCR()
+	instance.Spec.Gateway = getTestGatewayExternalServiceCR().Spec.Gateway
+	setTrafficSplitStatus(instance, trafficSplitGroups(instance), getTestSplitSubsets("10.0.100.10", "10.0.100.11"))
+
+	route := createGatewayRouteCr(instance)
+
+	rules, _, _ := unstructured.NestedSlice(route.Object, "spec", "rules")
+	backendRefs := rules[0].(map[string]interface{})["backendRefs"].([]interface{})
+	testutils.ExpectEqInt(int32(len(backendRefs)), 2, t)
+	testutils.ExpectEqStr(backendRefs[1].(map[string]interface{})["name"].(string), "TestService-new", t)
+	testutils.ExpectEqInt(int32(backendRefs[1].(map[string]interface{})["weight"].(int64)), 70, t)
+}
@@ -0,0 +1,99 @@
+package externalservice
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"time"
+
+	esov1alpha1 "github.com/CrowdfoxGmbH/external-service-operator/pkg/apis/eso/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// reconcileTerminatingAddresses keeps addresses removed from the ExternalService until
+// spec.drainSeconds passed, so open connections can finish. The prober keeps them in
+// NotReadyAddresses. The request is requeued for the time the next one can be deleted.
+func (r *ReconcileExternalService) reconcileTerminatingAddresses(instance *esov1alpha1.ExternalService, reqLogger logr.Logger) (reconcile.Result, error) {
+	endpoint := &corev1.Endpoints{}
+	err := r.client.Get(context.TODO(), types.NamespacedName{Name: instance.Name, Namespace: instance.Namespace}, endpoint)
+	if err != nil && !errors.IsNotFound(err) {
+		return reconcile.Result{}, err
+	}
+
+	started, requeueAfter, changed := updateTerminatingAddresses(instance, endpoint.Subsets, time.Now())
+	if !changed {
+		return reconcile.Result{RequeueAfter: requeueAfter}, nil
+	}
+
+	for _, ip := range started {
+		reqLogger.Info("Address removed, draining it", "ip", ip, "drainSeconds", instance.Spec.DrainSeconds)
+		r.recorder.Event(instance, corev1.EventTypeNormal, "AddressTerminating", fmt.Sprintf("Address %v removed, it is kept not ready for %vs", ip, instance.Spec.DrainSeconds))
+	}
+
+	reqLogger.Info("Updating terminating addresses")
+	return reconcile.Result{RequeueAfter: requeueAfter}, nil
+}
+
+// updateTerminatingAddresses adds the addresses the Endpoints serve, but which were removed,
+// to the terminating addresses and drops the ones whose drain time is over or which were
+// added again. Returns the IPs of the new terminating addresses, the time after which the
+// next one can be deleted and whether the status changed.
+func updateTerminatingAddresses(instance *esov1alpha1.ExternalService, subsets []corev1.EndpointSubset, now time.Time) ([]string, time.Duration, bool) {
+	if instance.Spec.DrainSeconds <= 0 {
+		changed := len(instance.Status.TerminatingAddresses) > 0
+		instance.Status.TerminatingAddresses = nil
+		return nil, 0, changed
+	}
+	drain := time.Duration(instance.Spec.DrainSeconds) * time.Second
+
+	active := map[string]bool{}
+	for _, address := range instance.GetActiveAddresses() {
+		active[address.IP] = true
+	}
+
+	// addresses whose drain time is over are still served until the Endpoints are updated,
+	// they are known as well, so they do not start terminating again
+	terminating := []esov1alpha1.TerminatingAddress{}
+	known := map[string]bool{}
+	for _, address := range instance.Status.TerminatingAddresses {
+		known[address.IP] = true
+		if !active[address.IP] && now.Before(address.RemovedAt.Add(drain)) {
+			terminating = append(terminating, address)
+		}
+	}
+
+	started := []string{}
+	for _, subset := range subsets {
+		for _, address := range append(append([]corev1.EndpointAddress{}, subset.Addresses...), subset.NotReadyAddresses...) {
+			if active[address.IP] || known[address.IP] {
+				continue
+			}
+			known[address.IP] = true
+			started = append(started, address.IP)
+			terminating = append(terminating, esov1alpha1.TerminatingAddress{
+				ExternalServiceAddress: servedAddress(address, subset),
+				RemovedAt:              metav1.NewTime(now),
+			})
+		}
+	}
+
+	var requeueAfter time.Duration
+	for _, address := range terminating {
+		if left := address.RemovedAt.Add(drain).Sub(now); requeueAfter == 0 || left < requeueAfter {
+			requeueAfter = left
+		}
+	}
+
+	if len(terminating) == 0 {
+		terminating = nil
+	}
+	changed := !reflect.DeepEqual(terminating, instance.Status.TerminatingAddresses)
+	instance.Status.TerminatingAddresses = terminating
+	return started, requeueAfter, changed
+}
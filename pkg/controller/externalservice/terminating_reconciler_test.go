@@ -0,0 +1,131 @@
+package externalservice
+
+import (
+	"testing"
+	"time"
+
+	esov1alpha1 "github.com/CrowdfoxGmbH/external-service-operator/pkg/apis/eso/v1alpha1"
+	"github.com/CrowdfoxGmbH/external-service-operator/pkg/testutils"
+)
+
+func TestUpdateTerminatingAddressesKeepsRemovedAddresses(t *testing.T) {
+	// Given an address removed from an ExternalService with a drain time
+	instance := getTestExternalServiceCR()
+	instance.Spec.Ips = []string{"10.0.100.10"}
+	instance.Spec.DrainSeconds = 30
+	now := time.Now()
+
+	started, requeueAfter, changed := updateTerminatingAddresses(instance, getTestRolloutSubsets("10.0.100.10", "10.0.100.11"), now)
+
+	// Then it is kept as terminating address until the drain time is over
+	testutils.ExpectTrue(changed, t)
+	testutils.ExpectEqInt(int32(len(started)), 1, t)
+	testutils.ExpectEqStr(started[0], "10.0.100.11", t)
+	testutils.ExpectTrue(requeueAfter == 30*time.Second, t)
+	testutils.ExpectTrue(instance.IsTerminating("10.0.100.11"), t)
+	testutils.ExpectEqInt(int32(len(instance.GetIps())), 2, t)
+
+	_, requeueAfter, changed = updateTerminatingAddresses(instance, getTestRolloutSubsets("10.0.100.10", "10.0.100.11"), now.Add(10*time.Second))
+	testutils.ExpectFalse(changed, t)
+	testutils.ExpectTrue(requeueAfter == 20*time.Second, t)
+
+	_, requeueAfter, changed = updateTerminatingAddresses(instance, getTestRolloutSubsets("10.0.100.10", "10.0.100.11"), now.Add(30*time.Second))
+	testutils.ExpectTrue(changed, t)
+	testutils.ExpectTrue(requeueAfter == 0, t)
+	testutils.ExpectEqInt(int32(len(instance.GetIps())), 1, t)
+}
+
+func TestUpdateTerminatingAddressesDropsAddedAddresses(t *testing.T) {
+	// Given a terminating address
+	instance := getTestExternalServiceCR()
+	instance.Spec.Ips = []string{"10.0.100.10"}
+	instance.Spec.DrainSeconds = 30
+	now := time.Now()
+	updateTerminatingAddresses(instance, getTestRolloutSubsets("10.0.100.10", "10.0.100.11"), now)
+
+	// When it is added again
+	instance.Spec.Ips = []string{"10.0.100.10", "10.0.100.11"}
+	updateTerminatingAddresses(instance, getTestRolloutSubsets("10.0.100.10", "10.0.100.11"), now.Add(time.Second))
+
+	// Then it is not terminating anymore
+	testutils.ExpectFalse(instance.IsTerminating("10.0.100.11"), t)
+	testutils.ExpectEqInt(int32(len(instance.Status.TerminatingAddresses)), 0, t)
+}
+
+func TestReconcileDrainsRemovedAddresses(t *testing.T) {
+	// Given an ExternalService with a drain time
+	instance := getTestExternalServiceCR()
+	instance.Spec.DrainSeconds = 60
+	client := testutils.InitFakeClient(instance)
+	if _, err := runTestReconcile(client, instance.Name, instance.Namespace); err != nil {
+		t.Fatalf("reconcile: (%v)", err)
+	}
+
+	// When an address is removed
+	externalService, _ := getRuntimeExternalService(client, instance.Name, instance.Namespace)
+	externalService.Spec.Ips = []string{"10.0.100.10", "10.0.100.11"}
+	if err := updateObject(client, externalService); err != nil {
+		t.Fatalf("update external service: (%v)", err)
+	}
+	result, err := runTestReconcile(client, instance.Name, instance.Namespace)
+	if err != nil {
+		t.Fatalf("reconcile: (%v)", err)
+	}
+
+	// Then it stays in NotReadyAddresses until the drain time is over
+	endpoint, _ := getRuntimeEndpoint(client, instance.Name, instance.Namespace)
+	found := false
+	for _, address := range endpoint.Subsets[0].NotReadyAddresses {
+		found = found || address.IP == "10.0.100.12"
+	}
+	testutils.ExpectTrue(found, t)
+	for _, address := range endpoint.Subsets[0].Addresses {
+		if address.IP == "10.0.100.12" {
+			t.Errorf("Expected the terminating address to be not ready")
+		}
+	}
+	testutils.ExpectTrue(result.RequeueAfter > 50*time.Second, t)
+	externalService, _ = getRuntimeExternalService(client, instance.Name, instance.Namespace)
+	testutils.ExpectEqInt(int32(len(externalService.Status.TerminatingAddresses)), 1, t)
+	testutils.ExpectEqStr(externalService.Status.TerminatingAddresses[0].IP, "10.0.100.12", t)
+}
+
+func TestReconcileWithoutDrainTimeRemovesAddresses(t *testing.T) {
+	instance := getTestExternalServiceCR()
+	client := testutils.InitFakeClient(instance)
+	if _, err := runTestReconcile(client, instance.Name, instance.Namespace); err != nil {
+		t.Fatalf("reconcile: (%v)", err)
+	}
+
+	externalService, _ := getRuntimeExternalService(client, instance.Name, instance.Namespace)
+	externalService.Spec.Ips = []string{"10.0.100.10"}
+	if err := updateObject(client, externalService); err != nil {
+		t.Fatalf("update external service: (%v)", err)
+	}
+	if _, err := runTestReconcile(client, instance.Name, instance.Namespace); err != nil {
+		t.Fatalf("reconcile: (%v)", err)
+	}
+
+	endpoint, _ := getRuntimeEndpoint(client, instance.Name, instance.Namespace)
+	testutils.ExpectEqInt(int32(len(endpoint.Subsets[0].Addresses)+len(endpoint.Subsets[0].NotReadyAddresses)), 1, t)
+	externalService, _ = getRuntimeExternalService(client, instance.Name, instance.Namespace)
+	testutils.ExpectEqInt(int32(len(externalService.Status.TerminatingAddresses)), 0, t)
+}
+
+// the address removed by a rollout drains as well, instead of the rollout starting over
+func TestRolloutRemovalDrainsAddress(t *testing.T) {
+	instance := getTestRolloutExternalServiceCR("10.0.100.20")
+	instance.Spec.DrainSeconds = 30
+	setTestHealthy(instance, "10.0.100.20")
+	now := time.Now()
+	subsets := getTestRolloutSubsets("10.0.100.10", "10.0.100.20")
+
+	removed, _, _ := updateRollout(instance, subsets, now)
+	testutils.ExpectEqStr(removed, "10.0.100.10", t)
+	updateTerminatingAddresses(instance, subsets, now)
+	testutils.ExpectTrue(instance.IsTerminating("10.0.100.10"), t)
+
+	_, _, changed := updateRollout(instance, subsets, now.Add(time.Second))
+	testutils.ExpectFalse(changed, t)
+	testutils.ExpectEqStr(string(instance.Status.Rollout.Phase), string(esov1alpha1.RolloutPhaseComplete), t)
+}
@@ -2,12 +2,17 @@ package externalservice
 
 import (
 	"context"
+	esov1alpha1 "github.com/CrowdfoxGmbH/external-service-operator/pkg/apis/eso/v1alpha1"
 	"github.com/CrowdfoxGmbH/external-service-operator/pkg/prober"
+	"github.com/CrowdfoxGmbH/external-service-operator/pkg/sources"
 	corev1 "k8s.io/api/core/v1"
 	extv1 "k8s.io/api/extensions/v1beta1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 )
@@ -20,13 +25,29 @@ func runTestReconcile(client client.Client, name string, namespace string) (reco
 		},
 	}
 
-	r := &ReconcileExternalService{client: client, scheme: scheme.Scheme, probeManager: prober.NewProber(client)}
+	return newTestReconciler(client).Reconcile(req)
+}
 
-	return r.Reconcile(req)
+func newTestReconciler(client client.Client) *ReconcileExternalService {
+	return &ReconcileExternalService{
+		client:        client,
+		sourceClient:  client,
+		scheme:        scheme.Scheme,
+		recorder:      record.NewFakeRecorder(100),
+		probeManager:  prober.NewProber(client),
+		sourceManager: sources.NewSourceManager(client),
+	}
 }
 
 // Helper functions and short cuts
 
+func getRuntimeExternalService(client client.Client, name string, namespace string) (*esov1alpha1.ExternalService, error) {
+	runtimeObject := &esov1alpha1.ExternalService{}
+	err := client.Get(context.TODO(), types.NamespacedName{Name: name, Namespace: namespace}, runtimeObject)
+
+	return runtimeObject, err
+}
+
 func getRuntimeEndpoint(client client.Client, name string, namespace string) (*corev1.Endpoints, error) {
 	runtimeObject := &corev1.Endpoints{}
 	err := client.Get(context.TODO(), types.NamespacedName{Name: name, Namespace: namespace}, runtimeObject)
@@ -55,3 +76,11 @@ func getRuntimeIngress(client client.Client, name string, namespace string) (*ex
 
 	return runtimeObject, err
 }
+
+func getRuntimeUnstructured(client client.Client, gvk schema.GroupVersionKind, name string, namespace string) (*unstructured.Unstructured, error) {
+	runtimeObject := &unstructured.Unstructured{}
+	runtimeObject.SetGroupVersionKind(gvk)
+	err := client.Get(context.TODO(), types.NamespacedName{Name: name, Namespace: namespace}, runtimeObject)
+
+	return runtimeObject, err
+}
@@ -0,0 +1,81 @@
+package externalservice
+
+import (
+	"fmt"
+
+	esov1alpha1 "github.com/CrowdfoxGmbH/external-service-operator/pkg/apis/eso/v1alpha1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+var traefikIngressRouteGVK = schema.GroupVersionKind{Group: "traefik.io", Version: "v1alpha1", Kind: "IngressRoute"}
+
+// createTraefikIngressRouteCrs creates a single IngressRoute with one route per host and path
+func createTraefikIngressRouteCrs(i *esov1alpha1.ExternalService) []*unstructured.Unstructured {
+	config := i.Spec.Ingress.Traefik
+	if config == nil {
+		config = &esov1alpha1.TraefikConfig{}
+	}
+
+	middlewares := []interface{}{}
+	for _, middleware := range config.Middlewares {
+		namespace := middleware.Namespace
+		if namespace == "" {
+			namespace = i.Namespace
+		}
+		middlewares = append(middlewares, map[string]interface{}{"name": middleware.Name, "namespace": namespace})
+	}
+
+	services := []interface{}{
+		map[string]interface{}{
+			"kind": "Service",
+			"name": i.Name,
+			"port": int64(i.Spec.Port),
+		},
+	}
+	if backends := splitBackends(i); backends != nil {
+		services = []interface{}{}
+		for _, backend := range backends {
+			services = append(services, map[string]interface{}{
+				"kind":   "Service",
+				"name":   backend.service,
+				"port":   int64(i.Spec.Port),
+				"weight": backend.weight,
+			})
+		}
+	}
+
+	routes := []interface{}{}
+	for _, hostpath := range i.Spec.Hosts {
+		route := map[string]interface{}{
+			"kind":     "Rule",
+			"match":    traefikMatch(hostpath),
+			"services": runtime.DeepCopyJSONValue(services),
+		}
+		if len(middlewares) > 0 {
+			route["middlewares"] = middlewares
+		}
+		routes = append(routes, route)
+	}
+
+	spec := map[string]interface{}{"routes": routes}
+	if len(config.EntryPoints) > 0 {
+		spec["entryPoints"] = stringSlice(config.EntryPoints)
+	}
+	if len(i.Spec.Ingress.TLS) > 0 {
+		spec["tls"] = map[string]interface{}{"secretName": i.Spec.Ingress.TLS[0].SecretName}
+	}
+
+	return []*unstructured.Unstructured{
+		newNamedUnstructured(i, traefikIngressRouteGVK, i.Name, spec, ingressLabels(i), ingressAnnotations(i)),
+	}
+}
+
+func traefikMatch(hostpath esov1alpha1.ExternalServiceHostPath) string {
+	match := fmt.Sprintf("Host(`%v`)", hostpath.Host)
+	if hostpath.Path != "" && hostpath.Path != "/" {
+		match += fmt.Sprintf(" && PathPrefix(`%v`)", hostpath.Path)
+	}
+	return match
+}
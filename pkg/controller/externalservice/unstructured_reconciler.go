@@ -0,0 +1,121 @@
+package externalservice
+
+import (
+	"context"
+	"time"
+
+	esov1alpha1 "github.com/CrowdfoxGmbH/external-service-operator/pkg/apis/eso/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// reconcileUnstructured creates or updates an object of a kind the operator has no Go types for,
+// e.g. routes of Gateway API. The operator owns the spec and the managed metadata of the object.
+func (r *ReconcileExternalService) reconcileUnstructured(instance *esov1alpha1.ExternalService, wanted *unstructured.Unstructured, reqLogger logr.Logger) (reconcile.Result, error) {
+	kind := wanted.GetKind()
+	if err := controllerutil.SetControllerReference(instance, wanted, r.scheme); err != nil {
+		return reconcile.Result{}, err
+	}
+
+	found := &unstructured.Unstructured{}
+	found.SetGroupVersionKind(wanted.GroupVersionKind())
+	err := r.client.Get(context.TODO(), types.NamespacedName{Name: wanted.GetName(), Namespace: wanted.GetNamespace()}, found)
+	if err != nil && errors.IsNotFound(err) {
+		reqLogger.Info("Creating a new "+kind, "namespace", wanted.GetNamespace(), "name", wanted.GetName())
+		return reconcile.Result{}, r.client.Create(context.TODO(), wanted)
+	} else if err != nil {
+		return reconcile.Result{}, err
+	}
+
+	merged := mergeUnstructured(found, wanted)
+	if err := controllerutil.SetControllerReference(instance, merged, r.scheme); err != nil {
+		return reconcile.Result{}, err
+	}
+
+	if equality.Semantic.DeepEqual(merged.Object, found.Object) {
+		reqLogger.Info("Skip reconcile: "+kind+" already exists", "namespace", found.GetNamespace(), "name", found.GetName())
+		return reconcile.Result{}, nil
+	}
+
+	reqLogger.Info("Specs changed. Trying to update "+kind, "namespace", found.GetNamespace(), "name", found.GetName())
+	if err = r.client.Update(context.TODO(), merged); err == nil {
+		reqLogger.Info("Updated "+kind, "namespace", found.GetNamespace(), "name", found.GetName())
+	}
+
+	return reconcile.Result{
+		RequeueAfter: time.Second,
+		Requeue:      true,
+	}, err
+}
+
+// deleteUnstructured deletes the object of the given kind named like the ExternalService, if the
+// ExternalService owns it. Kinds which are not installed in the cluster are ignored.
+func (r *ReconcileExternalService) deleteUnstructured(instance *esov1alpha1.ExternalService, gvk schema.GroupVersionKind, reqLogger logr.Logger) error {
+	_, err := r.deleteUnstructuredByName(instance, gvk, instance.Name, reqLogger)
+	return err
+}
+
+// deleteUnstructuredByName deletes the named object of the given kind, if the ExternalService
+// owns it. It returns whether the object existed.
+func (r *ReconcileExternalService) deleteUnstructuredByName(instance *esov1alpha1.ExternalService, gvk schema.GroupVersionKind, name string, reqLogger logr.Logger) (bool, error) {
+	found := &unstructured.Unstructured{}
+	found.SetGroupVersionKind(gvk)
+	err := r.client.Get(context.TODO(), types.NamespacedName{Name: name, Namespace: instance.Namespace}, found)
+	if errors.IsNotFound(err) || meta.IsNoMatchError(err) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+
+	if !metav1.IsControlledBy(found, instance) {
+		return true, nil
+	}
+
+	reqLogger.Info("Deleting "+gvk.Kind, "namespace", found.GetNamespace(), "name", found.GetName())
+	if err := r.client.Delete(context.TODO(), found); err != nil && !errors.IsNotFound(err) {
+		return true, err
+	}
+	return true, nil
+}
+
+// mergeUnstructured applies the spec and the managed metadata of the wanted object to the found one
+func mergeUnstructured(found *unstructured.Unstructured, wanted *unstructured.Unstructured) *unstructured.Unstructured {
+	merged := found.DeepCopy()
+
+	objectMeta := metav1.ObjectMeta{Labels: merged.GetLabels(), Annotations: merged.GetAnnotations()}
+	mergeManagedMetadata(&objectMeta, metav1.ObjectMeta{Labels: wanted.GetLabels(), Annotations: wanted.GetAnnotations()})
+	merged.SetLabels(objectMeta.Labels)
+	merged.SetAnnotations(objectMeta.Annotations)
+
+	merged.Object["spec"] = runtime.DeepCopyJSONValue(wanted.Object["spec"])
+	return merged
+}
+
+// newUnstructured returns an object of the given kind named like the ExternalService
+func newUnstructured(i *esov1alpha1.ExternalService, gvk schema.GroupVersionKind, spec map[string]interface{}) *unstructured.Unstructured {
+	return newNamedUnstructured(i, gvk, i.Name, spec, nil, nil)
+}
+
+// newNamedUnstructured returns an object of the given kind with the configured labels and annotations
+func newNamedUnstructured(i *esov1alpha1.ExternalService, gvk schema.GroupVersionKind, name string, spec map[string]interface{}, labels map[string]string, annotations map[string]string) *unstructured.Unstructured {
+	objectMeta := metav1.ObjectMeta{}
+	setManagedMetadata(&objectMeta, i, labels, annotations)
+
+	object := &unstructured.Unstructured{Object: map[string]interface{}{"spec": spec}}
+	object.SetGroupVersionKind(gvk)
+	object.SetName(name)
+	object.SetNamespace(i.Namespace)
+	object.SetLabels(objectMeta.Labels)
+	object.SetAnnotations(objectMeta.Annotations)
+	return object
+}
@@ -0,0 +1,55 @@
+package importer
+
+import (
+	"strings"
+)
+
+// diffLines returns a line diff of both texts. Removed lines start with "-", added lines
+// with "+" and unchanged lines with a space.
+func diffLines(a string, b string) string {
+	aLines := splitLines(a)
+	bLines := splitLines(b)
+
+	// common[i][j] is the length of the longest common subsequence of aLines[i:] and bLines[j:]
+	common := make([][]int, len(aLines)+1)
+	for i := range common {
+		common[i] = make([]int, len(bLines)+1)
+	}
+	for i := len(aLines) - 1; i >= 0; i-- {
+		for j := len(bLines) - 1; j >= 0; j-- {
+			if aLines[i] == bLines[j] {
+				common[i][j] = common[i+1][j+1] + 1
+			} else if common[i+1][j] >= common[i][j+1] {
+				common[i][j] = common[i+1][j]
+			} else {
+				common[i][j] = common[i][j+1]
+			}
+		}
+	}
+
+	diff := &strings.Builder{}
+	i, j := 0, 0
+	for i < len(aLines) || j < len(bLines) {
+		switch {
+		case i < len(aLines) && j < len(bLines) && aLines[i] == bLines[j]:
+			diff.WriteString(" " + aLines[i] + "\n")
+			i++
+			j++
+		case j == len(bLines) || i < len(aLines) && common[i+1][j] >= common[i][j+1]:
+			diff.WriteString("-" + aLines[i] + "\n")
+			i++
+		default:
+			diff.WriteString("+" + bLines[j] + "\n")
+			j++
+		}
+	}
+	return diff.String()
+}
+
+func splitLines(text string) []string {
+	text = strings.TrimSuffix(text, "\n")
+	if text == "" {
+		return []string{}
+	}
+	return strings.Split(text, "\n")
+}
@@ -0,0 +1,269 @@
+package importer
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	esov1alpha1 "github.com/CrowdfoxGmbH/external-service-operator/pkg/apis/eso/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	extv1 "k8s.io/api/extensions/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/ghodss/yaml"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// ignoredAnnotationPrefixes holds the prefixes of annotations which are not copied, as they
+// belong to the tools which created the Service
+var ignoredAnnotationPrefixes = []string{
+	"kubectl.kubernetes.io/",
+	"meta.helm.sh/",
+	"eso.crowdfox.com/",
+}
+
+// Importer creates ExternalServices for the hand-made selector-less Services of a namespace
+type Importer struct {
+	client client.Client
+}
+
+func NewImporter(c client.Client) *Importer {
+	return &Importer{client: c}
+}
+
+// Import returns an ExternalService for every Service of the namespace which has no selector
+// and is not created by an ExternalService. The addresses and ports come from the Endpoints of
+// the Service, the hosts from the Ingresses pointing at it.
+func (i *Importer) Import(namespace string) ([]*esov1alpha1.ExternalService, error) {
+	services := &corev1.ServiceList{}
+	if err := i.client.List(context.TODO(), &client.ListOptions{Namespace: namespace}, services); err != nil {
+		return nil, err
+	}
+	ingresses := &extv1.IngressList{}
+	if err := i.client.List(context.TODO(), &client.ListOptions{Namespace: namespace}, ingresses); err != nil {
+		return nil, err
+	}
+
+	externalServices := []*esov1alpha1.ExternalService{}
+	for _, service := range services.Items {
+		if !importable(service) {
+			continue
+		}
+
+		endpoint := &corev1.Endpoints{}
+		err := i.client.Get(context.TODO(), types.NamespacedName{Name: service.Name, Namespace: service.Namespace}, endpoint)
+		if err != nil && !errors.IsNotFound(err) {
+			return nil, err
+		}
+		if managedByOthers(endpoint.ObjectMeta) {
+			continue
+		}
+		externalServices = append(externalServices, createExternalService(service, endpoint, ingresses.Items))
+	}
+
+	sort.Slice(externalServices, func(a, b int) bool { return externalServices[a].Name < externalServices[b].Name })
+	return externalServices, nil
+}
+
+// Diff returns the changes applying the ExternalServices would make to the ones which already
+// exist, as a line diff of their YAML. New ExternalServices are completely added.
+func (i *Importer) Diff(externalServices []*esov1alpha1.ExternalService) (string, error) {
+	diff := &strings.Builder{}
+	for _, externalService := range externalServices {
+		wanted, err := ToYAML(externalService)
+		if err != nil {
+			return "", err
+		}
+
+		current := ""
+		found := &esov1alpha1.ExternalService{}
+		err = i.client.Get(context.TODO(), types.NamespacedName{Name: externalService.Name, Namespace: externalService.Namespace}, found)
+		if err == nil {
+			// only the fields the import sets are compared
+			found.Status = esov1alpha1.ExternalServiceStatus{}
+			found.ObjectMeta = metav1.ObjectMeta{Name: found.Name, Namespace: found.Namespace, Annotations: found.Annotations, Labels: found.Labels}
+			if current, err = ToYAML(found); err != nil {
+				return "", err
+			}
+		} else if !errors.IsNotFound(err) {
+			return "", err
+		}
+
+		if current == wanted {
+			continue
+		}
+		fmt.Fprintf(diff, "--- %v/%v (cluster)\n+++ %v/%v (import)\n", externalService.Namespace, externalService.Name, externalService.Namespace, externalService.Name)
+		diff.WriteString(diffLines(current, wanted))
+	}
+	return diff.String(), nil
+}
+
+// ToYAML returns the ExternalService as a manifest without status
+func ToYAML(externalService *esov1alpha1.ExternalService) (string, error) {
+	object := externalService.DeepCopy()
+	object.APIVersion = esov1alpha1.SchemeGroupVersion.String()
+	object.Kind = "ExternalService"
+
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(object)
+	if err != nil {
+		return "", err
+	}
+	delete(content, "status")
+	if metadata, ok := content["metadata"].(map[string]interface{}); ok {
+		delete(metadata, "creationTimestamp")
+	}
+
+	manifest, err := yaml.Marshal(content)
+	return string(manifest), err
+}
+
+// importable reports whether the Service is hand-made and has no selector, so its Endpoints
+// are maintained by hand as well. The Service of the apiserver has no selector either, its
+// Endpoints are maintained by the apiserver itself.
+func importable(service corev1.Service) bool {
+	if len(service.Spec.Selector) > 0 || service.Spec.Type == corev1.ServiceTypeExternalName {
+		return false
+	}
+	if service.Namespace == metav1.NamespaceDefault && service.Name == "kubernetes" {
+		return false
+	}
+	if managedByOthers(service.ObjectMeta) {
+		return false
+	}
+	controller := metav1.GetControllerOf(&service)
+	return controller == nil || controller.Kind != "ExternalService"
+}
+
+// managedByOthers reports whether a controller maintains the object, which an ExternalService
+// would fight with after taking it over
+func managedByOthers(meta metav1.ObjectMeta) bool {
+	if _, found := meta.Labels["endpoints.kubernetes.io/managed-by"]; found {
+		return true
+	}
+	for label := range meta.Labels {
+		if strings.HasPrefix(label, "service.kubernetes.io/") {
+			return true
+		}
+	}
+	controller := metav1.GetControllerOf(&meta)
+	return controller != nil && controller.Kind != "ExternalService"
+}
+
+func createExternalService(service corev1.Service, endpoint *corev1.Endpoints, ingresses []extv1.Ingress) *esov1alpha1.ExternalService {
+	externalService := &esov1alpha1.ExternalService{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      service.Name,
+			Namespace: service.Namespace,
+		},
+		Spec: esov1alpha1.ExternalServiceSpec{
+			Hosts: []esov1alpha1.ExternalServiceHostPath{},
+		},
+	}
+	if len(service.Spec.Ports) > 0 {
+		externalService.Spec.Port = service.Spec.Ports[0].Port
+	}
+
+	// keep the kind of the Service, a virtual IP would be replaced by a headless Service otherwise
+	config := &esov1alpha1.ServiceConfig{
+		Annotations: copyAnnotations(service.Annotations),
+		Labels:      service.Labels,
+	}
+	if service.Spec.ClusterIP == corev1.ClusterIPNone {
+		config.ClusterIP = corev1.ClusterIPNone
+	} else {
+		config.Type = service.Spec.Type
+	}
+	externalService.Spec.Service = config
+
+	setAddresses(externalService, endpoint.Subsets)
+	setHosts(externalService, ingresses)
+	return externalService
+}
+
+// setAddresses sets the addresses of the Endpoints. Addresses with the port of the Service are
+// listed as IPs, all others with their port.
+func setAddresses(externalService *esov1alpha1.ExternalService, subsets []corev1.EndpointSubset) {
+	known := map[string]bool{}
+	for _, subset := range subsets {
+		port := externalService.Spec.Port
+		if len(subset.Ports) > 0 {
+			port = subset.Ports[0].Port
+		}
+
+		for _, address := range append(append([]corev1.EndpointAddress{}, subset.Addresses...), subset.NotReadyAddresses...) {
+			if known[address.IP] {
+				continue
+			}
+			known[address.IP] = true
+
+			if port == externalService.Spec.Port && address.Hostname == "" {
+				externalService.Spec.Ips = append(externalService.Spec.Ips, address.IP)
+				continue
+			}
+			imported := esov1alpha1.ExternalServiceAddress{IP: address.IP, Hostname: address.Hostname}
+			if port != externalService.Spec.Port {
+				imported.Port = port
+			}
+			externalService.Spec.Addresses = append(externalService.Spec.Addresses, imported)
+		}
+	}
+}
+
+// setHosts sets the hosts and paths of the Ingresses routing to the Service. The annotations
+// and TLS settings of the Ingresses are taken over as well.
+func setHosts(externalService *esov1alpha1.ExternalService, ingresses []extv1.Ingress) {
+	config := &esov1alpha1.IngressConfig{}
+	for _, ingress := range ingresses {
+		routed := false
+		for _, rule := range ingress.Spec.Rules {
+			if rule.HTTP == nil {
+				continue
+			}
+			for _, path := range rule.HTTP.Paths {
+				if path.Backend.ServiceName == externalService.Name {
+					routed = true
+					externalService.Spec.Hosts = append(externalService.Spec.Hosts, esov1alpha1.ExternalServiceHostPath{Host: rule.Host, Path: path.Path})
+				}
+			}
+		}
+		if !routed {
+			continue
+		}
+
+		for key, value := range copyAnnotations(ingress.Annotations) {
+			if config.Annotations == nil {
+				config.Annotations = map[string]string{}
+			}
+			config.Annotations[key] = value
+		}
+		for _, tls := range ingress.Spec.TLS {
+			config.TLS = append(config.TLS, esov1alpha1.IngressTLS{Hosts: tls.Hosts, SecretName: tls.SecretName})
+		}
+	}
+
+	if len(config.Annotations) > 0 || len(config.TLS) > 0 {
+		externalService.Spec.Ingress = config
+	}
+}
+
+// copyAnnotations returns the annotations without the ones of the tools which created the object
+func copyAnnotations(annotations map[string]string) map[string]string {
+	copied := map[string]string{}
+	for key, value := range annotations {
+		ignored := false
+		for _, prefix := range ignoredAnnotationPrefixes {
+			ignored = ignored || strings.HasPrefix(key, prefix)
+		}
+		if !ignored {
+			copied[key] = value
+		}
+	}
+	if len(copied) == 0 {
+		return nil
+	}
+	return copied
+}
@@ -0,0 +1,194 @@
+package importer
+
+import (
+	"strings"
+	"testing"
+
+	esov1alpha1 "github.com/CrowdfoxGmbH/external-service-operator/pkg/apis/eso/v1alpha1"
+	"github.com/CrowdfoxGmbH/external-service-operator/pkg/testutils"
+	corev1 "k8s.io/api/core/v1"
+	extv1 "k8s.io/api/extensions/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+func getTestService(name string, selector map[string]string) *corev1.Service {
+	return &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: "external-services",
+			Annotations: map[string]string{
+				"foo.bar": "testvalue",
+				"kubectl.kubernetes.io/last-applied-configuration": "{}",
+			},
+		},
+		Spec: corev1.ServiceSpec{
+			Ports:     []corev1.ServicePort{corev1.ServicePort{Port: 80}},
+			ClusterIP: "10.96.0.10",
+			Type:      corev1.ServiceTypeClusterIP,
+			Selector:  selector,
+		},
+	}
+}
+
+func getTestIngress() *extv1.Ingress {
+	return &extv1.Ingress{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "TestService",
+			Namespace:   "external-services",
+			Annotations: map[string]string{"nginx.ingress.kubernetes.io/proxy-body-size": "8m"},
+		},
+		Spec: extv1.IngressSpec{
+			Rules: []extv1.IngressRule{
+				extv1.IngressRule{
+					Host: "sub.example.com",
+					IngressRuleValue: extv1.IngressRuleValue{
+						HTTP: &extv1.HTTPIngressRuleValue{
+							Paths: []extv1.HTTPIngressPath{
+								extv1.HTTPIngressPath{
+									Path:    "/",
+									Backend: extv1.IngressBackend{ServiceName: "TestService", ServicePort: intstr.FromInt(80)},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func TestImportSelectorlessServices(t *testing.T) {
+	// Given a hand-made Service with Endpoints and an Ingress, and a Service with a selector
+	client := testutils.InitFakeClient(
+		getTestService("TestService", nil),
+		testutils.CreateDefaultEndpoint(),
+		getTestIngress(),
+		getTestService("PodService", map[string]string{"app": "pods"}),
+	)
+
+	externalServices, err := NewImporter(client).Import("external-services")
+	if err != nil {
+		t.Fatalf("import: (%v)", err)
+	}
+
+	// Then only the selector-less Service is imported
+	testutils.ExpectEqInt(int32(len(externalServices)), 1, t)
+	externalService := externalServices[0]
+	testutils.ExpectEqStr(externalService.Name, "TestService", t)
+	testutils.ExpectEqInt(externalService.Spec.Port, 80, t)
+	testutils.ExpectEqInt(int32(len(externalService.Spec.Ips)), 4, t)
+	testutils.ExpectEqStr(externalService.Spec.Ips[0], "10.0.102.10", t)
+
+	// with the hosts of the Ingress and the copied annotations
+	testutils.ExpectEqInt(int32(len(externalService.Spec.Hosts)), 1, t)
+	testutils.ExpectEqStr(externalService.Spec.Hosts[0].Host, "sub.example.com", t)
+	testutils.ExpectEqStr(externalService.Spec.Ingress.Annotations["nginx.ingress.kubernetes.io/proxy-body-size"], "8m", t)
+	testutils.ExpectEqStr(externalService.Spec.Service.Annotations["foo.bar"], "testvalue", t)
+	testutils.ExpectEqInt(int32(len(externalService.Spec.Service.Annotations)), 1, t)
+
+	// and the virtual IP of the Service is kept
+	testutils.ExpectEqStr(externalService.Spec.Service.ClusterIP, "", t)
+	testutils.ExpectEqStr(string(externalService.Spec.Service.Type), string(corev1.ServiceTypeClusterIP), t)
+}
+
+func TestImportKeepsPortsOfAddresses(t *testing.T) {
+	endpoint := testutils.CreateDefaultEndpoint()
+	endpoint.Subsets = append(endpoint.Subsets, corev1.EndpointSubset{
+		Addresses: []corev1.EndpointAddress{corev1.EndpointAddress{IP: "10.0.102.20", Hostname: "db"}},
+		Ports:     []corev1.EndpointPort{corev1.EndpointPort{Port: 9090}},
+	})
+	client := testutils.InitFakeClient(getTestService("TestService", nil), endpoint)
+
+	externalServices, err := NewImporter(client).Import("external-services")
+	if err != nil {
+		t.Fatalf("import: (%v)", err)
+	}
+
+	addresses := externalServices[0].Spec.Addresses
+	testutils.ExpectEqInt(int32(len(addresses)), 1, t)
+	testutils.ExpectEqStr(addresses[0].IP, "10.0.102.20", t)
+	testutils.ExpectEqInt(addresses[0].Port, 9090, t)
+	testutils.ExpectEqStr(addresses[0].Hostname, "db", t)
+}
+
+func TestImportSkipsServicesOfExternalServices(t *testing.T) {
+	service := getTestService("TestService", nil)
+	controller := true
+	service.OwnerReferences = []metav1.OwnerReference{metav1.OwnerReference{APIVersion: "eso.crowdfox.com/v1alpha1", Kind: "ExternalService", Name: "TestService", Controller: &controller}}
+	client := testutils.InitFakeClient(service, testutils.CreateDefaultEndpoint())
+
+	externalServices, err := NewImporter(client).Import("external-services")
+	if err != nil {
+		t.Fatalf("import: (%v)", err)
+	}
+
+	testutils.ExpectEqInt(int32(len(externalServices)), 0, t)
+}
+
+func TestImportSkipsServicesManagedByOthers(t *testing.T) {
+	// Given the Service of the apiserver, a Service whose Endpoints are managed by a controller
+	// and a hand-made Service
+	apiserver := getTestService("kubernetes", nil)
+	apiserver.Namespace = "default"
+	apiserverEndpoint := testutils.CreateDefaultEndpoint()
+	apiserverEndpoint.Name = "kubernetes"
+	apiserverEndpoint.Namespace = "default"
+	mirrored := getTestService("Mirrored", nil)
+	mirroredEndpoint := testutils.CreateDefaultEndpoint()
+	mirroredEndpoint.Name = "Mirrored"
+	mirroredEndpoint.Labels = map[string]string{"endpoints.kubernetes.io/managed-by": "mirror-controller"}
+	proxied := getTestService("Proxied", nil)
+	proxied.Labels = map[string]string{"service.kubernetes.io/service-proxy-name": "other"}
+	client := testutils.InitFakeClient(apiserver, apiserverEndpoint, mirrored, mirroredEndpoint, proxied,
+		getTestService("TestService", nil), testutils.CreateDefaultEndpoint())
+
+	// Then none of them is imported from the default namespace
+	externalServices, err := NewImporter(client).Import("default")
+	if err != nil {
+		t.Fatalf("import: (%v)", err)
+	}
+	testutils.ExpectEqInt(int32(len(externalServices)), 0, t)
+
+	// and only the hand-made one from its namespace
+	externalServices, err = NewImporter(client).Import("external-services")
+	if err != nil {
+		t.Fatalf("import: (%v)", err)
+	}
+	testutils.ExpectEqInt(int32(len(externalServices)), 1, t)
+	testutils.ExpectEqStr(externalServices[0].Name, "TestService", t)
+}
+
+func TestDiffShowsChangesToExistingExternalServices(t *testing.T) {
+	// Given an ExternalService which already exists with fewer addresses
+	existing := &esov1alpha1.ExternalService{
+		ObjectMeta: metav1.ObjectMeta{Name: "TestService", Namespace: "external-services"},
+		Spec: esov1alpha1.ExternalServiceSpec{
+			Port:  8080,
+			Ips:   []string{"10.0.102.10"},
+			Hosts: []esov1alpha1.ExternalServiceHostPath{},
+		},
+	}
+	client := testutils.InitFakeClient(existing)
+	importer := NewImporter(client)
+	imported := existing.DeepCopy()
+	imported.Spec.Ips = append(imported.Spec.Ips, "10.0.102.12")
+
+	diff, err := importer.Diff([]*esov1alpha1.ExternalService{imported})
+	if err != nil {
+		t.Fatalf("diff: (%v)", err)
+	}
+
+	// Then only the added address is shown as change
+	testutils.ExpectTrue(strings.Contains(diff, "\n+  - 10.0.102.12\n"), t)
+	testutils.ExpectFalse(strings.Contains(diff, "\n-"), t)
+
+	// and unchanged ExternalServices are left out
+	diff, _ = importer.Diff([]*esov1alpha1.ExternalService{existing})
+	testutils.ExpectEqStr(diff, "", t)
+}
+
+func TestDiffLines(t *testing.T) {
+	testutils.ExpectEqStr(diffLines("a\nb\nc\n", "a\nc\nd\n"), " a\n-b\n c\n+d\n", t)
+	testutils.ExpectEqStr(diffLines("", "a\n"), "+a\n", t)
+}
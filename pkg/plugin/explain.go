@@ -0,0 +1,140 @@
+package plugin
+
+import (
+	"fmt"
+	"time"
+
+	esov1alpha1 "github.com/CrowdfoxGmbH/external-service-operator/pkg/apis/eso/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	ready    = "ready"
+	notReady = "not ready"
+)
+
+// explain returns the reasons for the state of the IP in the Endpoints, in the order the
+// operator applies them: terminating addresses are never ready, ForceNotReady wins over drains,
+// drains win over ForceReady, which wins over the probe and the outlier detection. Only the
+// active priority tier is ready.
+func explain(externalService *esov1alpha1.ExternalService, state string, ip string, now time.Time) ([]string, error) {
+	address, found := externalService.GetAddress(ip)
+	if !found {
+		if rollout := externalService.Status.Rollout; rollout != nil && rollout.Phase == esov1alpha1.RolloutPhaseAborted && contains(rollout.NewAddresses, ip) {
+			return []string{
+				fmt.Sprintf("%v is not in the Endpoints", ip),
+				"- it is held back, because the rollout was aborted: " + conditionMessage(externalService, esov1alpha1.RolloutProgressing),
+			}, nil
+		}
+		return nil, fmt.Errorf("%v is not an address of ExternalService %v", ip, externalService.Name)
+	}
+
+	reasons := []string{}
+	switch state {
+	case "":
+		reasons = append(reasons, fmt.Sprintf("%v is not in the Endpoints", ip))
+	default:
+		reasons = append(reasons, fmt.Sprintf("%v is %v", ip, state))
+	}
+
+	for _, terminating := range externalService.Status.TerminatingAddresses {
+		if terminating.IP == ip {
+			reasons = append(reasons, fmt.Sprintf("- it was removed at %v and is kept not ready until spec.drainSeconds passed", terminating.RemovedAt.Format(time.RFC3339)))
+		}
+	}
+
+	override := externalService.GetReadinessOverride(ip, now)
+	if override != nil && override.ForceNotReady {
+		reasons = append(reasons, "- a readiness override forces it not ready"+until(override))
+	}
+	if externalService.IsDrained(ip) {
+		reasons = append(reasons, "- it is drained"+drainedSince(externalService, ip, address))
+	}
+	if override != nil && override.ForceReady {
+		reasons = append(reasons, "- a readiness override forces it ready"+until(override))
+	}
+
+	status := addressStatus(externalService, ip)
+	switch {
+	case externalService.Spec.ReadinessProbe == (corev1.Probe{}):
+		reasons = append(reasons, "- the ExternalService has no readiness probe, so it is healthy")
+	case status.Healthy == nil:
+		reasons = append(reasons, "- its probe has no settled result yet")
+	case !*status.Healthy:
+		reasons = append(reasons, "- its probe fails"+probeDetails(status.LastProbe))
+	default:
+		reasons = append(reasons, "- its probe passes"+probeDetails(status.LastProbe))
+	}
+	if status.EjectedUntil != nil {
+		reasons = append(reasons, fmt.Sprintf("- the outlier detection ejected it until %v", status.EjectedUntil.Format(time.RFC3339)))
+	}
+
+	switch active := externalService.Status.ActivePriority; {
+	case active == nil:
+		reasons = append(reasons, "- no priority tier has healthy addresses")
+	case *active != address.Priority:
+		reasons = append(reasons, fmt.Sprintf("- its priority tier %v is not active, tier %v receives the traffic", address.Priority, *active))
+	}
+	if state == "" && address.HideNotReady {
+		reasons = append(reasons, "- it is hidden from the Endpoints while it is not ready")
+	}
+	return reasons, nil
+}
+
+func addressStatus(externalService *esov1alpha1.ExternalService, ip string) esov1alpha1.ExternalServiceAddressStatus {
+	for _, status := range externalService.Status.Addresses {
+		if status.IP == ip {
+			return status
+		}
+	}
+	return esov1alpha1.ExternalServiceAddressStatus{IP: ip}
+}
+
+func until(override *esov1alpha1.ReadinessOverride) string {
+	if override.ExpiresAt == nil {
+		return ""
+	}
+	return " until " + override.ExpiresAt.Format(time.RFC3339)
+}
+
+func drainedSince(externalService *esov1alpha1.ExternalService, ip string, address esov1alpha1.ExternalServiceAddress) string {
+	if address.Drain {
+		return " by spec.addresses"
+	}
+	for _, drained := range externalService.Status.DrainedAddresses {
+		if drained.IP != ip {
+			continue
+		}
+		if drained.DrainedBy == "" {
+			return " since " + drained.Since.Format(time.RFC3339)
+		}
+		return fmt.Sprintf(" by %v since %v", drained.DrainedBy, drained.Since.Format(time.RFC3339))
+	}
+	return ""
+}
+
+func probeDetails(lastProbe *esov1alpha1.ProbeResult) string {
+	if lastProbe == nil {
+		return ""
+	}
+	if lastProbe.Message == "" {
+		return fmt.Sprintf(" (%v after %vms)", lastProbe.Result, lastProbe.LatencyMilliseconds)
+	}
+	return fmt.Sprintf(": %v (%v after %vms)", lastProbe.Message, lastProbe.Result, lastProbe.LatencyMilliseconds)
+}
+
+func conditionMessage(externalService *esov1alpha1.ExternalService, conditionType esov1alpha1.ExternalServiceConditionType) string {
+	if condition := externalService.Status.GetCondition(conditionType); condition != nil {
+		return condition.Message
+	}
+	return ""
+}
+
+func contains(ips []string, ip string) bool {
+	for _, known := range ips {
+		if known == ip {
+			return true
+		}
+	}
+	return false
+}
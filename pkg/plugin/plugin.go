@@ -0,0 +1,231 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	esov1alpha1 "github.com/CrowdfoxGmbH/external-service-operator/pkg/apis/eso/v1alpha1"
+	"github.com/CrowdfoxGmbH/external-service-operator/pkg/prober"
+	corev1 "k8s.io/api/core/v1"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Plugin implements the commands of the kubectl-eso plugin for the ExternalServices of a namespace
+type Plugin struct {
+	client    client.Client
+	namespace string
+	out       io.Writer
+}
+
+func NewPlugin(c client.Client, namespace string, out io.Writer) *Plugin {
+	return &Plugin{client: c, namespace: namespace, out: out}
+}
+
+// Status prints every address of the ExternalService with its state in the Endpoints and the
+// last probe result from the status
+func (p *Plugin) Status(name string) error {
+	externalService, endpoint, err := p.get(name)
+	if err != nil {
+		return err
+	}
+
+	statuses := map[string]esov1alpha1.ExternalServiceAddressStatus{}
+	for _, status := range externalService.Status.Addresses {
+		statuses[status.IP] = status
+	}
+	endpointStates := endpointStates(endpoint)
+
+	w := tabwriter.NewWriter(p.out, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "IP\tENDPOINTS\tHEALTHY\tLAST PROBE\tLATENCY\tNOTES")
+	for _, ip := range externalService.GetIps() {
+		status := statuses[ip]
+		healthy, lastProbe, latency := "unknown", "-", "-"
+		if status.Healthy != nil {
+			healthy = fmt.Sprint(*status.Healthy)
+		}
+		if status.LastProbe != nil {
+			lastProbe = status.LastProbe.Result
+			latency = fmt.Sprintf("%vms", status.LastProbe.LatencyMilliseconds)
+		}
+		state := endpointStates[ip]
+		if state == "" {
+			state = "-"
+		}
+		fmt.Fprintf(w, "%v\t%v\t%v\t%v\t%v\t%v\n", ip, state, healthy, lastProbe, latency, strings.Join(notes(externalService, ip, status), ", "))
+	}
+	return w.Flush()
+}
+
+// Probe runs the readiness probe of the ExternalService once from this machine against the
+// given IPs, or all addresses if none are given
+func (p *Plugin) Probe(name string, ips []string) error {
+	externalService, _, err := p.get(name)
+	if err != nil {
+		return err
+	}
+	if len(ips) == 0 {
+		ips = externalService.GetIps()
+	}
+
+	w := tabwriter.NewWriter(p.out, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "IP\tRESULT\tLATENCY\tMESSAGE")
+	for _, ip := range ips {
+		if _, found := externalService.GetAddress(ip); !found {
+			return fmt.Errorf("%v is not an address of ExternalService %v", ip, name)
+		}
+		result, err := prober.ProbeAddress(externalService, ip)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "%v\t%v\t%vms\t%v\n", ip, result.Result, result.LatencyMilliseconds, result.Message)
+	}
+	return w.Flush()
+}
+
+// Drain adds the IP to the drain annotation of the ExternalService and records who drained it
+func (p *Plugin) Drain(name string, ip string, user string) error {
+	externalService, _, err := p.get(name)
+	if err != nil {
+		return err
+	}
+	if _, found := externalService.GetAddress(ip); !found {
+		return fmt.Errorf("%v is not an address of ExternalService %v", ip, name)
+	}
+	if externalService.IsDrained(ip) {
+		fmt.Fprintf(p.out, "%v is already drained\n", ip)
+		return nil
+	}
+
+	drained := append(drainedIps(externalService), ip)
+	setAnnotation(externalService, esov1alpha1.DrainAnnotation, strings.Join(drained, ","))
+	setAnnotation(externalService, esov1alpha1.DrainedByAnnotation, user)
+	if err := p.client.Update(context.TODO(), externalService); err != nil {
+		return err
+	}
+	fmt.Fprintf(p.out, "%v drained\n", ip)
+	return nil
+}
+
+// Undrain removes the IP from the drain annotation of the ExternalService. Addresses drained
+// in the spec have to be undrained there.
+func (p *Plugin) Undrain(name string, ip string, user string) error {
+	externalService, _, err := p.get(name)
+	if err != nil {
+		return err
+	}
+	if address, found := externalService.GetAddress(ip); found && address.Drain {
+		return fmt.Errorf("%v is drained by spec.addresses of ExternalService %v, remove drain: true there", ip, name)
+	}
+	if !externalService.IsDrained(ip) {
+		fmt.Fprintf(p.out, "%v is not drained\n", ip)
+		return nil
+	}
+
+	drained := []string{}
+	for _, drainedIP := range drainedIps(externalService) {
+		if drainedIP != ip {
+			drained = append(drained, drainedIP)
+		}
+	}
+	setAnnotation(externalService, esov1alpha1.DrainAnnotation, strings.Join(drained, ","))
+	setAnnotation(externalService, esov1alpha1.DrainedByAnnotation, user)
+	if err := p.client.Update(context.TODO(), externalService); err != nil {
+		return err
+	}
+	fmt.Fprintf(p.out, "%v undrained\n", ip)
+	return nil
+}
+
+// Why prints the reasons for the state of the IP in the Endpoints
+func (p *Plugin) Why(name string, ip string) error {
+	externalService, endpoint, err := p.get(name)
+	if err != nil {
+		return err
+	}
+
+	reasons, err := explain(externalService, endpointStates(endpoint)[ip], ip, time.Now())
+	if err != nil {
+		return err
+	}
+	for _, reason := range reasons {
+		fmt.Fprintln(p.out, reason)
+	}
+	return nil
+}
+
+// get returns the ExternalService and its Endpoints, which are empty if they do not exist
+func (p *Plugin) get(name string) (*esov1alpha1.ExternalService, *corev1.Endpoints, error) {
+	key := types.NamespacedName{Name: name, Namespace: p.namespace}
+	externalService := &esov1alpha1.ExternalService{}
+	if err := p.client.Get(context.TODO(), key, externalService); err != nil {
+		return nil, nil, err
+	}
+
+	endpoint := &corev1.Endpoints{}
+	if err := p.client.Get(context.TODO(), key, endpoint); err != nil && !errors.IsNotFound(err) {
+		return nil, nil, err
+	}
+	return externalService, endpoint, nil
+}
+
+// endpointStates returns ready or not ready for every IP of the Endpoints
+func endpointStates(endpoint *corev1.Endpoints) map[string]string {
+	states := map[string]string{}
+	for _, subset := range endpoint.Subsets {
+		for _, address := range subset.NotReadyAddresses {
+			states[address.IP] = notReady
+		}
+		for _, address := range subset.Addresses {
+			states[address.IP] = ready
+		}
+	}
+	return states
+}
+
+// notes returns the manual and temporary states of an address
+func notes(externalService *esov1alpha1.ExternalService, ip string, status esov1alpha1.ExternalServiceAddressStatus) []string {
+	notes := []string{}
+	if externalService.IsTerminating(ip) {
+		notes = append(notes, "terminating")
+	}
+	if externalService.IsDrained(ip) {
+		notes = append(notes, "drained")
+	}
+	if status.Override != "" {
+		notes = append(notes, status.Override)
+	}
+	if status.EjectedUntil != nil {
+		notes = append(notes, "ejected until "+status.EjectedUntil.Format(time.RFC3339))
+	}
+	return notes
+}
+
+// drainedIps returns the IPs of the drain annotation
+func drainedIps(externalService *esov1alpha1.ExternalService) []string {
+	ips := []string{}
+	for _, ip := range strings.Split(externalService.Annotations[esov1alpha1.DrainAnnotation], ",") {
+		if ip = strings.TrimSpace(ip); ip != "" {
+			ips = append(ips, ip)
+		}
+	}
+	return ips
+}
+
+// setAnnotation sets the annotation, or removes it if the value is empty
+func setAnnotation(externalService *esov1alpha1.ExternalService, key string, value string) {
+	if value == "" {
+		delete(externalService.Annotations, key)
+		return
+	}
+	if externalService.Annotations == nil {
+		externalService.Annotations = map[string]string{}
+	}
+	externalService.Annotations[key] = value
+}
@@ -0,0 +1,140 @@
+package plugin
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	esov1alpha1 "github.com/CrowdfoxGmbH/external-service-operator/pkg/apis/eso/v1alpha1"
+	"github.com/CrowdfoxGmbH/external-service-operator/pkg/testutils"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// getTestExternalService returns the default test ExternalService with a probe whose status
+// knows a healthy and an unhealthy address
+func getTestExternalService() *esov1alpha1.ExternalService {
+	externalService := testutils.CreateDefaultExternalService()
+	externalService.Spec.ReadinessProbe = testutils.CreateDefaultTestProbe()
+	healthy, unhealthy, priority := true, false, int32(0)
+	externalService.Status.ActivePriority = &priority
+	externalService.Status.Addresses = []esov1alpha1.ExternalServiceAddressStatus{
+		esov1alpha1.ExternalServiceAddressStatus{IP: "10.0.102.10", Ready: true, Healthy: &healthy, LastProbe: &esov1alpha1.ProbeResult{Result: "Success", LatencyMilliseconds: 12}},
+		esov1alpha1.ExternalServiceAddressStatus{IP: "10.0.102.12", Healthy: &unhealthy, LastProbe: &esov1alpha1.ProbeResult{Result: "Failure", Message: "connection refused", LatencyMilliseconds: 3}},
+	}
+	return externalService
+}
+
+func getTestEndpoint() *corev1.Endpoints {
+	return &corev1.Endpoints{
+		ObjectMeta: metav1.ObjectMeta{Name: "TestService", Namespace: "external-services"},
+		Subsets: []corev1.EndpointSubset{
+			corev1.EndpointSubset{
+				Addresses:         []corev1.EndpointAddress{corev1.EndpointAddress{IP: "10.0.102.10"}},
+				NotReadyAddresses: []corev1.EndpointAddress{corev1.EndpointAddress{IP: "10.0.102.12"}},
+			},
+		},
+	}
+}
+
+func getTestExternalServiceAnnotations(c client.Client, t *testing.T) map[string]string {
+	externalService := &esov1alpha1.ExternalService{}
+	if err := c.Get(context.TODO(), types.NamespacedName{Name: "TestService", Namespace: "external-services"}, externalService); err != nil {
+		t.Fatalf("get external service: (%v)", err)
+	}
+	return externalService.Annotations
+}
+
+func TestStatusShowsAddresses(t *testing.T) {
+	out := &bytes.Buffer{}
+	p := NewPlugin(testutils.InitFakeClient(getTestExternalService(), getTestEndpoint()), "external-services", out)
+
+	if err := p.Status("TestService"); err != nil {
+		t.Fatalf("status: (%v)", err)
+	}
+
+	lines := strings.Split(out.String(), "\n")
+	testutils.ExpectEqStr(strings.Join(strings.Fields(lines[1]), " "), "10.0.102.10 ready true Success 12ms", t)
+	testutils.ExpectEqStr(strings.Join(strings.Fields(lines[2]), " "), "10.0.102.12 not ready false Failure 3ms", t)
+	testutils.ExpectEqStr(strings.Join(strings.Fields(lines[3]), " "), "10.0.102.14 - unknown - -", t)
+}
+
+func TestDrainAndUndrain(t *testing.T) {
+	c := testutils.InitFakeClient(getTestExternalService())
+	p := NewPlugin(c, "external-services", &bytes.Buffer{})
+
+	if err := p.Drain("TestService", "10.0.102.10", "alice"); err != nil {
+		t.Fatalf("drain: (%v)", err)
+	}
+	if err := p.Drain("TestService", "10.0.102.12", "bob"); err != nil {
+		t.Fatalf("drain: (%v)", err)
+	}
+	annotations := getTestExternalServiceAnnotations(c, t)
+	testutils.ExpectEqStr(annotations[esov1alpha1.DrainAnnotation], "10.0.102.10,10.0.102.12", t)
+	testutils.ExpectEqStr(annotations[esov1alpha1.DrainedByAnnotation], "bob", t)
+
+	if err := p.Undrain("TestService", "10.0.102.10", "alice"); err != nil {
+		t.Fatalf("undrain: (%v)", err)
+	}
+	testutils.ExpectEqStr(getTestExternalServiceAnnotations(c, t)[esov1alpha1.DrainAnnotation], "10.0.102.12", t)
+
+	// unknown addresses are rejected
+	if err := p.Drain("TestService", "10.0.102.99", "alice"); err == nil {
+		t.Errorf("Expected an error for an unknown address")
+	}
+}
+
+func TestUndrainRejectsAddressesDrainedInSpec(t *testing.T) {
+	externalService := getTestExternalService()
+	externalService.Spec.Addresses = []esov1alpha1.ExternalServiceAddress{esov1alpha1.ExternalServiceAddress{IP: "10.0.102.20", Drain: true}}
+	p := NewPlugin(testutils.InitFakeClient(externalService), "external-services", &bytes.Buffer{})
+
+	if err := p.Undrain("TestService", "10.0.102.20", "alice"); err == nil {
+		t.Errorf("Expected an error for an address drained in the spec")
+	}
+}
+
+func TestExplainFailedProbe(t *testing.T) {
+	reasons, err := explain(getTestExternalService(), notReady, "10.0.102.12", time.Now())
+	if err != nil {
+		t.Fatalf("explain: (%v)", err)
+	}
+
+	testutils.ExpectEqStr(reasons[0], "10.0.102.12 is not ready", t)
+	testutils.ExpectEqStr(reasons[1], "- its probe fails: connection refused (Failure after 3ms)", t)
+	testutils.ExpectEqInt(int32(len(reasons)), 2, t)
+}
+
+func TestExplainManualState(t *testing.T) {
+	externalService := getTestExternalService()
+	externalService.Annotations[esov1alpha1.DrainAnnotation] = "10.0.102.10"
+	externalService.Status.DrainedAddresses = []esov1alpha1.DrainedAddress{
+		esov1alpha1.DrainedAddress{IP: "10.0.102.10", DrainedBy: "alice", Since: metav1.NewTime(time.Date(2026, 10, 19, 8, 0, 0, 0, time.UTC))},
+	}
+	externalService.Spec.ReadinessOverrides = []esov1alpha1.ReadinessOverride{esov1alpha1.ReadinessOverride{IP: "10.0.102.10", ForceReady: true}}
+
+	reasons, _ := explain(externalService, notReady, "10.0.102.10", time.Now())
+
+	testutils.ExpectEqStr(reasons[1], "- it is drained by alice since 2026-10-19T08:00:00Z", t)
+	testutils.ExpectEqStr(reasons[2], "- a readiness override forces it ready", t)
+}
+
+func TestExplainInactivePriorityTier(t *testing.T) {
+	externalService := getTestExternalService()
+	externalService.Spec.Addresses = []esov1alpha1.ExternalServiceAddress{esov1alpha1.ExternalServiceAddress{IP: "10.0.102.20", Priority: 1}}
+
+	reasons, _ := explain(externalService, notReady, "10.0.102.20", time.Now())
+
+	testutils.ExpectEqStr(reasons[1], "- its probe has no settled result yet", t)
+	testutils.ExpectEqStr(reasons[2], "- its priority tier 1 is not active, tier 0 receives the traffic", t)
+}
+
+func TestExplainUnknownAddress(t *testing.T) {
+	if _, err := explain(getTestExternalService(), "", "10.0.102.99", time.Now()); err == nil {
+		t.Errorf("Expected an error for an unknown address")
+	}
+}
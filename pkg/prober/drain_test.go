@@ -0,0 +1,78 @@
+package prober
+
+import (
+	"context"
+	"testing"
+
+	esov1alpha1 "github.com/CrowdfoxGmbH/external-service-operator/pkg/apis/eso/v1alpha1"
+	"github.com/CrowdfoxGmbH/external-service-operator/pkg/testutils"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func TestDrainedAddressStaysUnreadyOnSuccess(t *testing.T) {
+	fakeLogger := testLogger{}
+	log = &fakeLogger
+
+	externalService := testutils.CreateExternalService("TestService", "external-services", []string{"10.0.102.10", "10.0.102.12", "10.0.102.14", "10.0.102.16"}, 80, nil)
+	externalService.Annotations[esov1alpha1.DrainAnnotation] = "10.0.102.14"
+	endpoint := testutils.CreateDefaultEndpoint()
+	client := testutils.InitFakeClient(externalService, endpoint)
+
+	worker := worker{
+		parent: &externalServiceProber{
+			externalService: externalService,
+			httpprober:      newFakeHTTPProber(Success),
+		},
+		namespacedName: types.NamespacedName{Name: externalService.Name, Namespace: externalService.Namespace},
+		client:         client,
+		ip:             "10.0.102.14",
+		probe:          testutils.CreateTestProbe(1, 5, 3, 1, 3, corev1.URISchemeHTTP, 80, "/"),
+	}
+
+	if keepGoing := worker.doProbe(); !keepGoing {
+		t.Errorf("Expected drained worker to keep probing")
+	}
+
+	actualEndpoint := &corev1.Endpoints{}
+	if err := client.Get(context.TODO(), worker.namespacedName, actualEndpoint); err != nil {
+		t.Fatalf("Got Error '%v' getting updated Endpoint", err)
+	}
+	testutils.ExpectEqStr(actualEndpoint.Subsets[0].NotReadyAddresses[0].IP, "10.0.102.14", t)
+
+	// the status still shows the address is healthy
+	actualExternalService := &esov1alpha1.ExternalService{}
+	if err := client.Get(context.TODO(), worker.namespacedName, actualExternalService); err != nil {
+		t.Fatalf("Got Error '%v' getting updated ExternalService", err)
+	}
+	for _, address := range actualExternalService.Status.Addresses {
+		if address.IP == "10.0.102.14" {
+			testutils.ExpectFalse(address.Ready, t)
+			testutils.ExpectTrue(address.Healthy != nil && *address.Healthy, t)
+		}
+	}
+}
+
+func TestResyncMovesDrainedAddressToNotReady(t *testing.T) {
+	externalService := testutils.CreateExternalService("TestService", "external-services", []string{"10.0.102.12", "10.0.102.14", "10.0.102.16"}, 80, nil)
+	externalService.Spec.Addresses = []esov1alpha1.ExternalServiceAddress{
+		esov1alpha1.ExternalServiceAddress{IP: "10.0.102.10", Drain: true},
+	}
+	endpoint := testutils.CreateDefaultEndpoint()
+	client := testutils.InitFakeClient(externalService, endpoint)
+
+	prober := &externalServiceProber{externalService: externalService}
+	if err := prober.resync(client); err != nil {
+		t.Fatalf("Got error '%v' during resync", err)
+	}
+
+	actualEndpoint := &corev1.Endpoints{}
+	if err := client.Get(context.TODO(), types.NamespacedName{Name: endpoint.Name, Namespace: endpoint.Namespace}, actualEndpoint); err != nil {
+		t.Fatalf("Got Error '%v' getting updated Endpoint", err)
+	}
+	if len(actualEndpoint.Subsets[0].Addresses) != 1 || len(actualEndpoint.Subsets[0].NotReadyAddresses) != 3 {
+		t.Fatalf("Expected drained address to be moved to NotReadyAddresses, but got %v", actualEndpoint.Subsets[0])
+	}
+	testutils.ExpectEqStr(actualEndpoint.Subsets[0].Addresses[0].IP, "10.0.102.12", t)
+	testutils.ExpectEqStr(actualEndpoint.Subsets[0].NotReadyAddresses[2].IP, "10.0.102.10", t)
+}
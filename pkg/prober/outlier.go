@@ -0,0 +1,270 @@
+package prober
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"regexp"
+	"sync"
+	"time"
+
+	esov1alpha1 "github.com/CrowdfoxGmbH/external-service-operator/pkg/apis/eso/v1alpha1"
+
+	"github.com/go-logr/logr"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/runtime/log"
+)
+
+// outlierDetector scrapes request counters per address from a Prometheus metrics endpoint
+// and ejects addresses whose error rate violated the threshold too often in a row.
+// Ejected addresses are treated like addresses with a failing probe.
+type outlierDetector struct {
+	config     esov1alpha1.OutlierDetection
+	parent     *externalServiceProber
+	client     client.Client
+	httpClient *http.Client
+	stopCh     chan struct{}
+	now        func() time.Time
+	logger     logr.Logger
+
+	lastTotal    map[string]float64
+	lastErrors   map[string]float64
+	violations   map[string]int32
+	ejectedLock  sync.RWMutex
+	ejectedUntil map[string]time.Time
+}
+
+func newOutlierDetector(parent *externalServiceProber, client client.Client, config esov1alpha1.OutlierDetection) *outlierDetector {
+	if config.ErrorMetric == "" {
+		config.ErrorMetric = config.TotalMetric
+	}
+	if config.AddressLabel == "" {
+		config.AddressLabel = "upstream"
+	}
+	if config.MaxErrorPercent == 0 {
+		config.MaxErrorPercent = 50
+	}
+	if config.MinRequests == 0 {
+		config.MinRequests = 1
+	}
+	if config.ConsecutiveViolations == 0 {
+		config.ConsecutiveViolations = 3
+	}
+	if config.IntervalSeconds == 0 {
+		config.IntervalSeconds = 10
+	}
+	if config.EjectionSeconds == 0 {
+		config.EjectionSeconds = 30
+	}
+
+	return &outlierDetector{
+		config:       config,
+		parent:       parent,
+		client:       client,
+		httpClient:   &http.Client{Timeout: time.Duration(config.IntervalSeconds) * time.Second},
+		stopCh:       make(chan struct{}, 1),
+		now:          time.Now,
+		logger:       logf.Log.WithName("outlier detection"),
+		lastTotal:    map[string]float64{},
+		lastErrors:   map[string]float64{},
+		violations:   map[string]int32{},
+		ejectedUntil: map[string]time.Time{},
+	}
+}
+
+func (d *outlierDetector) run() {
+	ticker := time.NewTicker(time.Duration(d.config.IntervalSeconds) * time.Second)
+	defer ticker.Stop()
+
+	d.logger.Info("Start outlier detection", "metricsUrl", d.config.MetricsURL)
+	for {
+		changed, err := d.detect()
+		if err != nil {
+			d.logger.Error(err, "Could not scrape metrics", "metricsUrl", d.config.MetricsURL)
+		}
+		if changed {
+			if err := d.parent.resync(d.client); err != nil {
+				d.logger.Error(err, "Could not update Endpoint after ejection changed")
+			}
+		}
+
+		select {
+		case <-d.stopCh:
+			d.logger.Info("Stop outlier detection", "metricsUrl", d.config.MetricsURL)
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (d *outlierDetector) stop() {
+	select {
+	case d.stopCh <- struct{}{}:
+	default:
+	}
+}
+
+// detect scrapes the metrics once and returns whether the set of ejected addresses changed
+func (d *outlierDetector) detect() (bool, error) {
+	changed := d.expireEjections()
+
+	families, err := d.scrape()
+	if err != nil {
+		return changed, err
+	}
+
+	return d.evaluate(families) || changed, nil
+}
+
+func (d *outlierDetector) scrape() (map[string]*dto.MetricFamily, error) {
+	response, err := d.httpClient.Get(d.config.MetricsURL)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("metrics endpoint returned status %v", response.StatusCode)
+	}
+
+	var parser expfmt.TextParser
+	return parser.TextToMetricFamilies(response.Body)
+}
+
+// evaluate compares the counters with the ones of the last scrape and ejects addresses
+// exceeding the error rate for ConsecutiveViolations intervals
+func (d *outlierDetector) evaluate(families map[string]*dto.MetricFamily) bool {
+	total := d.sumByAddress(families[d.config.TotalMetric], nil)
+	errorLabels := map[string]*regexp.Regexp{}
+	for label, expression := range d.config.ErrorLabels {
+		matcher, err := regexp.Compile("^(?:" + expression + ")$")
+		if err != nil {
+			d.logger.Error(err, "Invalid error label expression", "label", label)
+			return false
+		}
+		errorLabels[label] = matcher
+	}
+	errors := d.sumByAddress(families[d.config.ErrorMetric], errorLabels)
+
+	changed := false
+	for _, ip := range d.parent.getExternalService().GetIps() {
+		lastTotal, known := d.lastTotal[ip]
+		lastErrors := d.lastErrors[ip]
+		d.lastTotal[ip] = total[ip]
+		d.lastErrors[ip] = errors[ip]
+		if !known {
+			continue
+		}
+
+		requests := counterDelta(lastTotal, total[ip])
+		failures := counterDelta(lastErrors, errors[ip])
+		if requests < float64(d.config.MinRequests) {
+			continue
+		}
+
+		if failures*100/requests <= float64(d.config.MaxErrorPercent) {
+			d.violations[ip] = 0
+			continue
+		}
+
+		d.violations[ip]++
+		if d.violations[ip] >= d.config.ConsecutiveViolations && !d.isEjected(ip) {
+			d.violations[ip] = 0
+			d.eject(ip)
+			changed = true
+		}
+	}
+
+	return changed
+}
+
+// sumByAddress sums up the values of all series of the family per IP
+func (d *outlierDetector) sumByAddress(family *dto.MetricFamily, labels map[string]*regexp.Regexp) map[string]float64 {
+	result := map[string]float64{}
+	if family == nil {
+		return result
+	}
+
+	for _, metric := range family.Metric {
+		values := map[string]string{}
+		for _, label := range metric.Label {
+			values[label.GetName()] = label.GetValue()
+		}
+
+		matches := true
+		for name, matcher := range labels {
+			if !matcher.MatchString(values[name]) {
+				matches = false
+				break
+			}
+		}
+		if !matches {
+			continue
+		}
+
+		ip := values[d.config.AddressLabel]
+		if host, _, err := net.SplitHostPort(ip); err == nil {
+			ip = host
+		}
+
+		switch {
+		case metric.Counter != nil:
+			result[ip] += metric.Counter.GetValue()
+		case metric.Untyped != nil:
+			result[ip] += metric.Untyped.GetValue()
+		}
+	}
+
+	return result
+}
+
+// counterDelta returns the increase of a counter. A decrease means the counter was reset
+func counterDelta(last float64, current float64) float64 {
+	if current < last {
+		return current
+	}
+	return current - last
+}
+
+func (d *outlierDetector) eject(ip string) {
+	d.ejectedLock.Lock()
+	defer d.ejectedLock.Unlock()
+
+	until := d.now().Add(time.Duration(d.config.EjectionSeconds) * time.Second)
+	d.logger.Info("Eject address", "ip", ip, "until", until)
+	d.ejectedUntil[ip] = until
+}
+
+func (d *outlierDetector) expireEjections() bool {
+	d.ejectedLock.Lock()
+	defer d.ejectedLock.Unlock()
+
+	changed := false
+	for ip, until := range d.ejectedUntil {
+		if !d.now().Before(until) {
+			d.logger.Info("Ejection of address expired", "ip", ip)
+			delete(d.ejectedUntil, ip)
+			changed = true
+		}
+	}
+	return changed
+}
+
+func (d *outlierDetector) isEjected(ip string) bool {
+	_, ejected := d.getEjectedUntil(ip)
+	return ejected
+}
+
+func (d *outlierDetector) getEjectedUntil(ip string) (time.Time, bool) {
+	if d == nil {
+		return time.Time{}, false
+	}
+
+	d.ejectedLock.RLock()
+	defer d.ejectedLock.RUnlock()
+
+	until, ejected := d.ejectedUntil[ip]
+	return until, ejected
+}
@@ -0,0 +1,117 @@
+package prober
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	esov1alpha1 "github.com/CrowdfoxGmbH/external-service-operator/pkg/apis/eso/v1alpha1"
+	"github.com/CrowdfoxGmbH/external-service-operator/pkg/testutils"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// fakeIngressMetrics serves per upstream request counters like an ingress controller does
+type fakeIngressMetrics struct {
+	ok     map[string]int
+	failed map[string]int
+}
+
+func (f *fakeIngressMetrics) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	fmt.Fprintln(w, "# TYPE upstream_requests_total counter")
+	for upstream, count := range f.ok {
+		fmt.Fprintf(w, "upstream_requests_total{upstream=\"%v\",status=\"200\"} %v\n", upstream, count)
+	}
+	for upstream, count := range f.failed {
+		fmt.Fprintf(w, "upstream_requests_total{upstream=\"%v\",status=\"503\"} %v\n", upstream, count)
+	}
+}
+
+func (f *fakeIngressMetrics) request(upstream string, ok int, failed int) {
+	f.ok[upstream] += ok
+	f.failed[upstream] += failed
+}
+
+func createOutlierTestDetector(metricsURL string) *outlierDetector {
+	externalService := testutils.CreateDefaultExternalService()
+	externalService.Spec.OutlierDetection = &esov1alpha1.OutlierDetection{
+		MetricsURL:            metricsURL,
+		TotalMetric:           "upstream_requests_total",
+		ErrorLabels:           map[string]string{"status": "5.."},
+		ConsecutiveViolations: 2,
+		EjectionSeconds:       30,
+	}
+
+	parent := &externalServiceProber{externalService: externalService}
+	parent.outlier = newOutlierDetector(parent, nil, *externalService.Spec.OutlierDetection)
+	return parent.outlier
+}
+
+func TestOutlierDetectionEjectsAfterConsecutiveViolations(t *testing.T) {
+	metrics := &fakeIngressMetrics{ok: map[string]int{}, failed: map[string]int{}}
+	server := httptest.NewServer(metrics)
+	defer server.Close()
+
+	detector := createOutlierTestDetector(server.URL)
+	now := time.Now()
+	detector.now = func() time.Time { return now }
+
+	metrics.request("10.0.102.10:8080", 10, 0)
+	metrics.request("10.0.102.12:8080", 10, 0)
+	if _, err := detector.detect(); err != nil {
+		t.Fatalf("Got error '%v' while scraping metrics", err)
+	}
+
+	// first violation
+	metrics.request("10.0.102.10:8080", 2, 8)
+	metrics.request("10.0.102.12:8080", 10, 1)
+	changed, _ := detector.detect()
+	testutils.ExpectFalse(changed, t)
+	testutils.ExpectFalse(detector.isEjected("10.0.102.10"), t)
+
+	// second violation ejects the address
+	metrics.request("10.0.102.10:8080", 0, 10)
+	metrics.request("10.0.102.12:8080", 10, 0)
+	changed, _ = detector.detect()
+	testutils.ExpectTrue(changed, t)
+	testutils.ExpectTrue(detector.isEjected("10.0.102.10"), t)
+	testutils.ExpectFalse(detector.isEjected("10.0.102.12"), t)
+
+	// ejected addresses are not eligible, no matter what the probe says
+	testutils.ExpectFalse(detector.parent.isEligible(corev1.EndpointAddress{IP: "10.0.102.10"}, true), t)
+
+	// the ejection is bounded
+	now = now.Add(31 * time.Second)
+	changed, _ = detector.detect()
+	testutils.ExpectTrue(changed, t)
+	testutils.ExpectFalse(detector.isEjected("10.0.102.10"), t)
+}
+
+func TestOutlierDetectionResetsViolationsOnHealthyInterval(t *testing.T) {
+	metrics := &fakeIngressMetrics{ok: map[string]int{}, failed: map[string]int{}}
+	server := httptest.NewServer(metrics)
+	defer server.Close()
+
+	detector := createOutlierTestDetector(server.URL)
+
+	detector.detect()
+	metrics.request("10.0.102.10", 0, 10)
+	detector.detect()
+	metrics.request("10.0.102.10", 10, 0)
+	detector.detect()
+	metrics.request("10.0.102.10", 0, 10)
+	detector.detect()
+
+	testutils.ExpectFalse(detector.isEjected("10.0.102.10"), t)
+}
+
+func TestOutlierDetectionScrapeError(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	defer server.Close()
+
+	detector := createOutlierTestDetector(server.URL)
+	if _, err := detector.detect(); err == nil {
+		t.Errorf("Expected error when metrics endpoint fails")
+	}
+}
@@ -0,0 +1,83 @@
+package prober
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	esov1alpha1 "github.com/CrowdfoxGmbH/external-service-operator/pkg/apis/eso/v1alpha1"
+	"github.com/CrowdfoxGmbH/external-service-operator/pkg/testutils"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func TestApplyManualState(t *testing.T) {
+	expired := metav1.NewTime(time.Now().Add(-time.Minute))
+	externalService := testutils.CreateDefaultExternalService()
+	externalService.Annotations[esov1alpha1.DrainAnnotation] = "10.0.102.14"
+	externalService.Spec.ReadinessOverrides = []esov1alpha1.ReadinessOverride{
+		esov1alpha1.ReadinessOverride{IP: "10.0.102.10", ForceReady: true},
+		esov1alpha1.ReadinessOverride{IP: "10.0.102.12", ForceNotReady: true},
+		esov1alpha1.ReadinessOverride{IP: "10.0.102.14", ForceReady: true},
+		esov1alpha1.ReadinessOverride{IP: "10.0.102.16", ForceNotReady: true, ExpiresAt: &expired},
+	}
+
+	testutils.ExpectTrue(applyManualState(externalService, "10.0.102.10", false), t)
+	testutils.ExpectFalse(applyManualState(externalService, "10.0.102.12", true), t)
+	// drains win over ForceReady
+	testutils.ExpectFalse(applyManualState(externalService, "10.0.102.14", true), t)
+	// expired overrides are ignored
+	testutils.ExpectTrue(applyManualState(externalService, "10.0.102.16", true), t)
+}
+
+func TestApplyManualStateKeepsTerminatingAddressesNotReady(t *testing.T) {
+	externalService := testutils.CreateDefaultExternalService()
+	externalService.Spec.ReadinessOverrides = []esov1alpha1.ReadinessOverride{
+		esov1alpha1.ReadinessOverride{IP: "10.0.102.20", ForceReady: true},
+	}
+	externalService.Status.TerminatingAddresses = []esov1alpha1.TerminatingAddress{
+		esov1alpha1.TerminatingAddress{ExternalServiceAddress: esov1alpha1.ExternalServiceAddress{IP: "10.0.102.20"}, RemovedAt: metav1.Now()},
+	}
+
+	testutils.ExpectFalse(applyManualState(externalService, "10.0.102.20", true), t)
+}
+
+func TestForceReadyOverridesFailingProbe(t *testing.T) {
+	fakeLogger := testLogger{}
+	log = &fakeLogger
+
+	externalService := testutils.CreateExternalService("TestService", "external-services", []string{"10.0.102.10", "10.0.102.12", "10.0.102.14", "10.0.102.16"}, 80, nil)
+	externalService.Spec.ReadinessOverrides = []esov1alpha1.ReadinessOverride{
+		esov1alpha1.ReadinessOverride{IP: "10.0.102.10", ForceReady: true},
+	}
+	endpoint := testutils.CreateDefaultEndpoint()
+	client := testutils.InitFakeClient(externalService, endpoint)
+
+	worker := worker{
+		parent: &externalServiceProber{
+			externalService: externalService,
+			httpprober:      newFakeHTTPProber(Failure),
+		},
+		namespacedName: types.NamespacedName{Name: externalService.Name, Namespace: externalService.Namespace},
+		client:         client,
+		ip:             "10.0.102.10",
+		probe:          testutils.CreateTestProbe(1, 5, 3, 1, 1, corev1.URISchemeHTTP, 80, "/"),
+	}
+
+	worker.doProbe()
+
+	actualEndpoint := &corev1.Endpoints{}
+	if err := client.Get(context.TODO(), worker.namespacedName, actualEndpoint); err != nil {
+		t.Fatalf("Got Error '%v' getting updated Endpoint", err)
+	}
+	testutils.ExpectEqStr(actualEndpoint.Subsets[0].Addresses[0].IP, "10.0.102.10", t)
+
+	actualExternalService := &esov1alpha1.ExternalService{}
+	if err := client.Get(context.TODO(), worker.namespacedName, actualExternalService); err != nil {
+		t.Fatalf("Got Error '%v' getting updated ExternalService", err)
+	}
+	testutils.ExpectEqStr(actualExternalService.Status.Addresses[0].IP, "10.0.102.10", t)
+	testutils.ExpectEqStr(actualExternalService.Status.Addresses[0].Override, "ForceReady", t)
+	testutils.ExpectFalse(*actualExternalService.Status.Addresses[0].Healthy, t)
+}
@@ -0,0 +1,120 @@
+package prober
+
+import (
+	esov1alpha1 "github.com/CrowdfoxGmbH/external-service-operator/pkg/apis/eso/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// priorities returns the priority tier of every address of the ExternalService
+func priorities(externalService *esov1alpha1.ExternalService) map[string]int32 {
+	result := map[string]int32{}
+	if externalService == nil {
+		return result
+	}
+
+	for _, address := range externalService.GetAddresses() {
+		result[address.IP] = address.Priority
+	}
+	return result
+}
+
+// restoreHiddenAddresses adds the addresses hidden from NotReadyAddresses back to the
+// subsets as not ready, so they can become ready again
+func restoreHiddenAddresses(externalService *esov1alpha1.ExternalService, subsets []corev1.EndpointSubset) []corev1.EndpointSubset {
+	if externalService == nil {
+		return subsets
+	}
+
+	restored := []corev1.EndpointSubset{}
+	for _, subset := range subsets {
+		subset.NotReadyAddresses = append([]corev1.EndpointAddress{}, subset.NotReadyAddresses...)
+		restored = append(restored, subset)
+	}
+
+	for _, address := range externalService.GetAddresses() {
+		if !address.HideNotReady || containsAddress(restored, address.IP) {
+			continue
+		}
+
+		port := externalService.Spec.GetPort(address)
+		index := -1
+		for i, subset := range restored {
+			if len(subset.Ports) > 0 && subset.Ports[0].Port == port {
+				index = i
+				break
+			}
+		}
+		if index < 0 {
+			index = len(restored)
+			restored = append(restored, corev1.EndpointSubset{Ports: []corev1.EndpointPort{corev1.EndpointPort{Port: port}}})
+		}
+		restored[index].NotReadyAddresses = append(restored[index].NotReadyAddresses, address.ToEndpointAddress())
+	}
+	return restored
+}
+
+// splitVisibleAddresses splits the addresses like splitAddresses, including the hidden ones,
+// and hides the not ready ones afterwards
+func splitVisibleAddresses(externalService *esov1alpha1.ExternalService, subsets []corev1.EndpointSubset, healthy func(address corev1.EndpointAddress, ready bool) bool) ([]corev1.EndpointSubset, *int32) {
+	newSubsets, activePriority := splitAddresses(restoreHiddenAddresses(externalService, subsets), healthy, priorities(externalService))
+	if externalService != nil {
+		newSubsets = externalService.HideNotReadyAddresses(newSubsets)
+	}
+	return newSubsets, activePriority
+}
+
+// splitAddresses decides for every address of the subsets whether it is ready or not.
+// healthy reports if an address may receive traffic. Only healthy addresses of the tier
+// with the lowest priority number which still has healthy members are marked ready.
+// Addresses keep their order, addresses changing their state are appended to the end.
+func splitAddresses(subsets []corev1.EndpointSubset, healthy func(address corev1.EndpointAddress, ready bool) bool, priority map[string]int32) (newSubsets []corev1.EndpointSubset, activePriority *int32) {
+	healthyIps := map[string]bool{}
+	for _, subset := range subsets {
+		for _, address := range subset.Addresses {
+			healthyIps[address.IP] = healthy(address, true)
+		}
+		for _, address := range subset.NotReadyAddresses {
+			healthyIps[address.IP] = healthy(address, false)
+		}
+	}
+
+	for ip, isHealthy := range healthyIps {
+		if isHealthy && (activePriority == nil || priority[ip] < *activePriority) {
+			p := priority[ip]
+			activePriority = &p
+		}
+	}
+
+	isReady := func(address corev1.EndpointAddress) bool {
+		return activePriority != nil && healthyIps[address.IP] && priority[address.IP] == *activePriority
+	}
+
+	newSubsets = []corev1.EndpointSubset{}
+	for _, subset := range subsets {
+		ready := []corev1.EndpointAddress{}
+		notReady := []corev1.EndpointAddress{}
+		becameReady := []corev1.EndpointAddress{}
+		becameNotReady := []corev1.EndpointAddress{}
+
+		for _, address := range subset.Addresses {
+			if isReady(address) {
+				ready = append(ready, address)
+			} else {
+				becameNotReady = append(becameNotReady, address)
+			}
+		}
+		for _, address := range subset.NotReadyAddresses {
+			if isReady(address) {
+				becameReady = append(becameReady, address)
+			} else {
+				notReady = append(notReady, address)
+			}
+		}
+
+		subset.Addresses = append(ready, becameReady...)
+		subset.NotReadyAddresses = append(notReady, becameNotReady...)
+		newSubsets = append(newSubsets, subset)
+	}
+
+	return newSubsets, activePriority
+}
@@ -0,0 +1,136 @@
+package prober
+
+import (
+	"context"
+	"testing"
+
+	esov1alpha1 "github.com/CrowdfoxGmbH/external-service-operator/pkg/apis/eso/v1alpha1"
+	"github.com/CrowdfoxGmbH/external-service-operator/pkg/testutils"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func createPriorityExternalService() *esov1alpha1.ExternalService {
+	externalService := testutils.CreateExternalService("TestService", "external-services", []string{}, 80, nil)
+	externalService.Spec.Addresses = []esov1alpha1.ExternalServiceAddress{
+		esov1alpha1.ExternalServiceAddress{IP: "10.0.102.10"},
+		esov1alpha1.ExternalServiceAddress{IP: "10.0.102.12"},
+		esov1alpha1.ExternalServiceAddress{IP: "10.0.102.14", Priority: 1},
+		esov1alpha1.ExternalServiceAddress{IP: "10.0.102.16", Priority: 1},
+	}
+	return externalService
+}
+
+func TestSplitAddressesOnlyBestTierIsReady(t *testing.T) {
+	endpoint := testutils.CreateDefaultEndpoint()
+	allHealthy := func(corev1.EndpointAddress, bool) bool { return true }
+
+	subsets, activePriority := splitAddresses(endpoint.Subsets, allHealthy, priorities(createPriorityExternalService()))
+	ready, notReady := subsets[0].Addresses, subsets[0].NotReadyAddresses
+
+	testutils.ExpectEqInt(int32(len(ready)), 2, t)
+	testutils.ExpectEqStr(ready[0].IP, "10.0.102.10", t)
+	testutils.ExpectEqStr(ready[1].IP, "10.0.102.12", t)
+	testutils.ExpectEqInt(int32(len(notReady)), 2, t)
+	testutils.ExpectEqInt(*activePriority, 0, t)
+}
+
+func TestSplitAddressesFailoverToBackupTier(t *testing.T) {
+	endpoint := testutils.CreateDefaultEndpoint()
+	primariesDown := func(address corev1.EndpointAddress, _ bool) bool {
+		return address.IP == "10.0.102.14" || address.IP == "10.0.102.16"
+	}
+
+	subsets, activePriority := splitAddresses(endpoint.Subsets, primariesDown, priorities(createPriorityExternalService()))
+	ready, notReady := subsets[0].Addresses, subsets[0].NotReadyAddresses
+
+	testutils.ExpectEqStr(ready[0].IP, "10.0.102.14", t)
+	testutils.ExpectEqStr(ready[1].IP, "10.0.102.16", t)
+	testutils.ExpectEqStr(notReady[0].IP, "10.0.102.10", t)
+	testutils.ExpectEqStr(notReady[1].IP, "10.0.102.12", t)
+	testutils.ExpectEqInt(*activePriority, 1, t)
+}
+
+func TestSplitAddressesWithoutHealthyAddresses(t *testing.T) {
+	endpoint := testutils.CreateDefaultEndpoint()
+	noneHealthy := func(corev1.EndpointAddress, bool) bool { return false }
+
+	subsets, activePriority := splitAddresses(endpoint.Subsets, noneHealthy, priorities(createPriorityExternalService()))
+	ready, notReady := subsets[0].Addresses, subsets[0].NotReadyAddresses
+
+	testutils.ExpectEqInt(int32(len(ready)), 0, t)
+	testutils.ExpectEqInt(int32(len(notReady)), 4, t)
+	if activePriority != nil {
+		t.Errorf("Expected no active priority but got %v", *activePriority)
+	}
+}
+
+func TestEnsureUnreadyOfLastPrimaryActivatesBackupTier(t *testing.T) {
+	externalService := createPriorityExternalService()
+	endpoint := testutils.CreateDefaultEndpoint()
+	client := testutils.InitFakeClient(externalService, endpoint)
+
+	parent := &externalServiceProber{
+		externalService: externalService,
+		health: map[string]bool{
+			"10.0.102.12": false,
+			"10.0.102.14": true,
+			"10.0.102.16": true,
+		},
+	}
+	worker := worker{
+		parent:         parent,
+		client:         client,
+		namespacedName: types.NamespacedName{Name: externalService.Name, Namespace: externalService.Namespace},
+		ip:             "10.0.102.10",
+	}
+
+	if err := worker.ensureUnready(endpoint); err != nil {
+		t.Errorf("Got error '%v' while executing function under test", err)
+	}
+
+	actualEndpoint := &corev1.Endpoints{}
+	if err := client.Get(context.TODO(), worker.namespacedName, actualEndpoint); err != nil {
+		t.Fatalf("Got Error '%v' getting updated Endpoint", err)
+	}
+
+	testutils.ExpectEqStr(actualEndpoint.Subsets[0].Addresses[0].IP, "10.0.102.14", t)
+	testutils.ExpectEqStr(actualEndpoint.Subsets[0].Addresses[1].IP, "10.0.102.16", t)
+
+	actualExternalService := &esov1alpha1.ExternalService{}
+	if err := client.Get(context.TODO(), worker.namespacedName, actualExternalService); err != nil {
+		t.Fatalf("Got Error '%v' getting updated ExternalService", err)
+	}
+
+	if actualExternalService.Status.ActivePriority == nil {
+		t.Fatalf("Expected active priority to be set in status")
+	}
+	testutils.ExpectEqInt(*actualExternalService.Status.ActivePriority, 1, t)
+
+	condition := actualExternalService.Status.GetCondition(esov1alpha1.PriorityTierActive)
+	if condition == nil {
+		t.Fatalf("Expected condition %v to be set", esov1alpha1.PriorityTierActive)
+	}
+	testutils.ExpectEqStr(string(condition.Status), string(corev1.ConditionTrue), t)
+}
+
+func TestSplitVisibleAddressesRestoresHiddenAddresses(t *testing.T) {
+	externalService := testutils.CreateExternalService("TestService", "external-services", []string{}, 80, nil)
+	externalService.Spec.Ranges = []esov1alpha1.AddressRange{
+		esov1alpha1.AddressRange{From: "10.0.102.10", To: "10.0.102.12", HideNotReady: true},
+	}
+	subsets := []corev1.EndpointSubset{
+		corev1.EndpointSubset{
+			Addresses: []corev1.EndpointAddress{corev1.EndpointAddress{IP: "10.0.102.10"}},
+			Ports:     []corev1.EndpointPort{corev1.EndpointPort{Port: 80}},
+		},
+	}
+
+	// the hidden 10.0.102.12 becomes healthy, 10.0.102.10 fails
+	healthy := func(address corev1.EndpointAddress, _ bool) bool { return address.IP == "10.0.102.12" }
+	subsets, _ = splitVisibleAddresses(externalService, subsets, healthy)
+
+	testutils.ExpectEqInt(int32(len(subsets[0].Addresses)), 1, t)
+	testutils.ExpectEqStr(subsets[0].Addresses[0].IP, "10.0.102.12", t)
+	testutils.ExpectEqInt(int32(len(subsets[0].NotReadyAddresses)), 0, t)
+}
@@ -0,0 +1,52 @@
+package prober
+
+import (
+	"errors"
+	"time"
+
+	esov1alpha1 "github.com/CrowdfoxGmbH/external-service-operator/pkg/apis/eso/v1alpha1"
+	"k8s.io/kubernetes/pkg/probe"
+	httpprober "k8s.io/kubernetes/pkg/probe/http"
+	tcpprober "k8s.io/kubernetes/pkg/probe/tcp"
+)
+
+// errUnknownProbeType is returned for probes which are neither HTTP nor TCP probes
+var errUnknownProbeType = errors.New("ExternalService has no HTTP or TCP readiness probe")
+
+// ProbeAddress runs the readiness probe of the ExternalService once against the IP, the same
+// way the workers of the operator do. Thresholds, overrides and drains are not applied.
+func ProbeAddress(externalService *esov1alpha1.ExternalService, ip string) (esov1alpha1.ProbeResult, error) {
+	w := &worker{
+		parent: &externalServiceProber{
+			externalService: externalService,
+			httpprober:      httpprober.New(),
+			tcpprober:       tcpprober.New(),
+		},
+		probe: externalService.Spec.ReadinessProbe,
+		ip:    ip,
+	}
+	_, result, err := w.probeOnce()
+	return result, err
+}
+
+// probeOnce runs the probe of the worker once and measures how long it took. Besides the
+// result for the status, the raw result is returned for the thresholds of the worker.
+func (w *worker) probeOnce() (probe.Result, esov1alpha1.ProbeResult, error) {
+	var result probe.Result
+	var message string
+	var err error
+
+	start := time.Now()
+	switch {
+	case w.probe.HTTPGet != nil:
+		result, message, err = w.runHttpProbe()
+	case w.probe.TCPSocket != nil:
+		result, message, err = w.runTcpProbe()
+	default:
+		return probe.Unknown, esov1alpha1.ProbeResult{}, errUnknownProbeType
+	}
+	if err != nil {
+		return probe.Unknown, esov1alpha1.ProbeResult{}, err
+	}
+	return result, newProbeResult(result, message, time.Since(start)), nil
+}
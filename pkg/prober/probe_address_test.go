@@ -0,0 +1,68 @@
+package prober
+
+import (
+	"net"
+	"testing"
+
+	esov1alpha1 "github.com/CrowdfoxGmbH/external-service-operator/pkg/apis/eso/v1alpha1"
+	"github.com/CrowdfoxGmbH/external-service-operator/pkg/testutils"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestProbeAddressWithTcpProbe(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: (%v)", err)
+	}
+	defer listener.Close()
+
+	externalService := testutils.CreateDefaultExternalService()
+	externalService.Spec.ReadinessProbe = corev1.Probe{
+		TimeoutSeconds: 1,
+		Handler: corev1.Handler{
+			TCPSocket: &corev1.TCPSocketAction{Port: intstr.FromInt(listener.Addr().(*net.TCPAddr).Port)},
+		},
+	}
+
+	result, err := ProbeAddress(externalService, "127.0.0.1")
+	if err != nil {
+		t.Fatalf("probe: (%v)", err)
+	}
+	testutils.ExpectEqStr(result.Result, "Success", t)
+}
+
+func TestProbeAddressWithoutProbe(t *testing.T) {
+	externalService := testutils.CreateDefaultExternalService()
+
+	if _, err := ProbeAddress(externalService, "127.0.0.1"); err == nil {
+		t.Errorf("Expected an error for an ExternalService without readiness probe")
+	}
+}
+
+func TestDoProbeKeepsProbeWhichChangedHealth(t *testing.T) {
+	log = &testLogger{}
+	endpoint := testutils.CreateDefaultEndpoint()
+	worker := worker{
+		parent: &externalServiceProber{
+			httpprober: newFakeHTTPProber(Failure),
+		},
+		namespacedName: types.NamespacedName{Name: "TestService", Namespace: "external-services"},
+		client:         fake.NewFakeClient(endpoint),
+		ip:             "10.0.102.10",
+		probe:          testutils.CreateTestProbe(1, 5, 3, 1, 1, corev1.URISchemeHTTP, 80, "/"),
+	}
+
+	worker.doProbe()
+
+	lastProbe := worker.parent.getLastProbe("10.0.102.10")
+	testutils.ExpectEqStr(lastProbe.Result, "Failure", t)
+	testutils.ExpectEqStr(lastProbe.Message, "Fake error", t)
+
+	// a further failure does not replace it
+	worker.parent.setLastProbe("10.0.102.10", esov1alpha1.ProbeResult{Result: "Failure", Message: "first"})
+	worker.doProbe()
+	testutils.ExpectEqStr(worker.parent.getLastProbe("10.0.102.10").Message, "first", t)
+}
@@ -2,6 +2,8 @@ package prober
 
 import (
 	"context"
+	"reflect"
+	"time"
 
 	esov1alpha1 "github.com/CrowdfoxGmbH/external-service-operator/pkg/apis/eso/v1alpha1"
 	corev1 "k8s.io/api/core/v1"
@@ -36,15 +38,20 @@ func (p *ProbeManager) markAddressesReady(externalService *esov1alpha1.ExternalS
 		return
 	}
 
-	for _, notReady := range found.Subsets[0].NotReadyAddresses {
-		found.Subsets[0].Addresses = append(found.Subsets[0].Addresses, notReady)
-	}
-	found.Subsets[0].NotReadyAddresses = []corev1.EndpointAddress{}
+	// without probes every address is healthy, but only the best priority tier gets traffic
+	subsets, activePriority := splitVisibleAddresses(externalService, found.Subsets, func(address corev1.EndpointAddress, _ bool) bool {
+		return applyManualState(externalService, address.IP, true)
+	})
 
-	if err := p.client.Update(context.TODO(), found); err != nil {
+	if err := updateEndpoint(p.client, found, subsets); err != nil {
 		p.logger.Error(err, "Could update Endpoint")
 		return
 	}
+
+	status := buildStatus(externalService, subsets, activePriority, func(string) (bool, bool) { return false, false }, func(string) (time.Time, bool) { return time.Time{}, false }, func(string) *esov1alpha1.ProbeResult { return nil })
+	if err := updateStatus(p.client, key, status); err != nil {
+		p.logger.Error(err, "Could not update status of ExternalService")
+	}
 }
 
 func (p *ProbeManager) AddProbes(externalService *esov1alpha1.ExternalService) {
@@ -63,12 +70,19 @@ func (p *ProbeManager) AddProbes(externalService *esov1alpha1.ExternalService) {
 	prober := &externalServiceProber{
 		externalService: externalService,
 		workers:         map[string]*worker{},
+		health:          map[string]bool{},
 		httpprober:      httpprober.New(),
 		tcpprober:       tcpprober.New(),
 	}
 
+	// the outlier detection has to exist before workers ask it for ejected addresses
+	prober.startOutlierDetection(p.client)
 	prober.addWorkers(p.client, externalService.Spec.ReadinessProbe)
 	p.probes[key] = prober
+
+	if err := prober.resync(p.client); err != nil {
+		p.logger.Error(err, "Could not resync Endpoint", "externalservice", externalService.Name)
+	}
 }
 
 func (p *ProbeManager) RemoveProbes(externalService *esov1alpha1.ExternalService) {
@@ -86,7 +100,17 @@ func (p *ProbeManager) RemoveProbesByNamespacedName(key types.NamespacedName) {
 func (p *ProbeManager) UpdateProbes(externalService *esov1alpha1.ExternalService) {
 	key := types.NamespacedName{Name: externalService.Name, Namespace: externalService.Namespace}
 
-	if _, ok := p.probes[key]; ok {
+	if prober, ok := p.probes[key]; ok {
+		running := prober.getExternalService()
+		if reflect.DeepEqual(running.Spec.ReadinessProbe, externalService.Spec.ReadinessProbe) && reflect.DeepEqual(running.Spec.OutlierDetection, externalService.Spec.OutlierDetection) {
+			p.logger.Info("Updating probes", "externalservice", externalService.Name)
+			prober.updateExternalService(p.client, externalService)
+			if err := prober.resync(p.client); err != nil {
+				p.logger.Error(err, "Could not resync Endpoint", "externalservice", externalService.Name)
+			}
+			return
+		}
+
 		p.logger.Info("Removing probes", "externalservice", externalService.Name)
 		p.RemoveProbes(externalService)
 	}
@@ -1,6 +1,9 @@
 package prober
 
 import (
+	"context"
+	"time"
+
 	esov1alpha1 "github.com/CrowdfoxGmbH/external-service-operator/pkg/apis/eso/v1alpha1"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/types"
@@ -14,6 +17,12 @@ type externalServiceProber struct {
 	workerLock      sync.RWMutex
 	externalService *esov1alpha1.ExternalService
 	workers         map[string]*worker
+	health          map[string]bool
+	lastProbes      map[string]esov1alpha1.ProbeResult
+	outlier         *outlierDetector
+	statusLock      sync.Mutex
+	status          proberStatus
+	reported        bool
 	httpprober      http.Prober
 	tcpprober       tcp.Prober
 }
@@ -22,21 +31,38 @@ func (e *externalServiceProber) removeWorker(w *worker) {
 	e.workerLock.Lock()
 	defer e.workerLock.Unlock()
 
-	delete(e.workers, w.ip)
+	// a new worker for the same IP might have been started in the meantime
+	if e.workers[w.ip] == w {
+		delete(e.workers, w.ip)
+		delete(e.health, w.ip)
+		delete(e.lastProbes, w.ip)
+	}
 }
 
 func (e *externalServiceProber) shutdownAllWorkers() {
-	for _, ip := range e.externalService.Spec.Ips {
-		if worker, ok := e.workers[ip]; ok {
-			worker.stop()
-			// we don't have to delete the workers as they
-			// are calling themself removeWorker() during stop procedure
-		}
+	e.workerLock.RLock()
+	defer e.workerLock.RUnlock()
+
+	if e.outlier != nil {
+		e.outlier.stop()
+	}
+
+	for _, worker := range e.workers {
+		worker.stop()
+		// we don't have to delete the workers as they
+		// are calling themself removeWorker() during stop procedure
 	}
 }
 
 func (e *externalServiceProber) addWorkers(client client.Client, probe corev1.Probe) {
-	for _, ip := range e.externalService.Spec.Ips {
+	e.workerLock.Lock()
+	defer e.workerLock.Unlock()
+
+	for _, ip := range e.externalService.GetIps() {
+		if _, found := e.workers[ip]; found {
+			continue
+		}
+
 		worker := &worker{
 			stopCh:         make(chan struct{}, 1),
 			parent:         e,
@@ -49,3 +75,172 @@ func (e *externalServiceProber) addWorkers(client client.Client, probe corev1.Pr
 		e.workers[ip] = worker
 	}
 }
+
+// updateExternalService replaces the ExternalService definition of a running prober.
+// Workers of removed IPs get stopped, workers for new IPs get started, all
+// others keep running, so their probe history is not lost.
+func (e *externalServiceProber) updateExternalService(client client.Client, externalService *esov1alpha1.ExternalService) {
+	e.workerLock.Lock()
+	e.externalService = externalService
+
+	ips := map[string]bool{}
+	for _, ip := range externalService.GetIps() {
+		ips[ip] = true
+	}
+
+	for ip, worker := range e.workers {
+		if !ips[ip] {
+			worker.stop()
+		}
+	}
+	e.workerLock.Unlock()
+
+	e.addWorkers(client, externalService.Spec.ReadinessProbe)
+}
+
+// isEligible reports whether an address may receive traffic. Addresses without a probe
+// result yet keep their current state.
+func (e *externalServiceProber) isEligible(address corev1.EndpointAddress, ready bool) bool {
+	healthy, known := e.getHealth(address.IP)
+	if !known {
+		healthy = ready
+	}
+	return applyManualState(e.getExternalService(), address.IP, healthy && !e.isEjected(address.IP))
+}
+
+// isEjected reports whether the outlier detection currently ejects the address
+func (e *externalServiceProber) isEjected(ip string) bool {
+	if e == nil {
+		return false
+	}
+	return e.outlier.isEjected(ip)
+}
+
+// startOutlierDetection starts the outlier detection if the ExternalService asks for it
+func (e *externalServiceProber) startOutlierDetection(client client.Client) {
+	if e.externalService.Spec.OutlierDetection == nil {
+		return
+	}
+
+	e.outlier = newOutlierDetector(e, client, *e.externalService.Spec.OutlierDetection)
+	go e.outlier.run()
+}
+
+// applyManualState applies readiness overrides and drains to the health of an address.
+// ForceNotReady wins over drains, drains win over ForceReady. Terminating addresses are
+// never ready.
+func applyManualState(externalService *esov1alpha1.ExternalService, ip string, healthy bool) bool {
+	if externalService == nil {
+		return healthy
+	}
+	if externalService.IsTerminating(ip) {
+		return false
+	}
+
+	override := externalService.GetReadinessOverride(ip, time.Now())
+	if override != nil && override.ForceNotReady {
+		return false
+	}
+	if externalService.IsDrained(ip) {
+		return false
+	}
+	if override != nil && override.ForceReady {
+		return true
+	}
+	return healthy
+}
+
+// resync recalculates the ready addresses of the Endpoint without waiting for the
+// next probe result, e.g. after an address got drained.
+func (e *externalServiceProber) resync(c client.Client) error {
+	externalService := e.getExternalService()
+	endpoint := &corev1.Endpoints{}
+	key := types.NamespacedName{Name: externalService.Name, Namespace: externalService.Namespace}
+	if err := c.Get(context.TODO(), key, endpoint); err != nil {
+		return err
+	}
+
+	subsets, activePriority := splitVisibleAddresses(externalService, endpoint.Subsets, e.isEligible)
+	if err := updateEndpoint(c, endpoint, subsets); err != nil {
+		return err
+	}
+
+	return e.reportStatus(c, subsets, activePriority)
+}
+
+func (e *externalServiceProber) getExternalService() *esov1alpha1.ExternalService {
+	if e == nil {
+		return nil
+	}
+
+	e.workerLock.RLock()
+	defer e.workerLock.RUnlock()
+
+	return e.externalService
+}
+
+// getAddress returns the definition of the address with the given IP
+func (e *externalServiceProber) getAddress(ip string) (esov1alpha1.ExternalServiceAddress, bool) {
+	externalService := e.getExternalService()
+	if externalService == nil {
+		return esov1alpha1.ExternalServiceAddress{}, false
+	}
+
+	return externalService.GetAddress(ip)
+}
+
+// setHealth remembers the last settled probe result of an IP
+func (e *externalServiceProber) setHealth(ip string, healthy bool) {
+	if e == nil {
+		return
+	}
+
+	e.workerLock.Lock()
+	defer e.workerLock.Unlock()
+
+	if e.health == nil {
+		e.health = map[string]bool{}
+	}
+	e.health[ip] = healthy
+}
+
+// setLastProbe remembers the probe which settled the health of an IP
+func (e *externalServiceProber) setLastProbe(ip string, result esov1alpha1.ProbeResult) {
+	if e == nil {
+		return
+	}
+
+	e.workerLock.Lock()
+	defer e.workerLock.Unlock()
+
+	if e.lastProbes == nil {
+		e.lastProbes = map[string]esov1alpha1.ProbeResult{}
+	}
+	e.lastProbes[ip] = result
+}
+
+func (e *externalServiceProber) getLastProbe(ip string) *esov1alpha1.ProbeResult {
+	if e == nil {
+		return nil
+	}
+
+	e.workerLock.RLock()
+	defer e.workerLock.RUnlock()
+
+	if result, known := e.lastProbes[ip]; known {
+		return &result
+	}
+	return nil
+}
+
+func (e *externalServiceProber) getHealth(ip string) (healthy bool, known bool) {
+	if e == nil {
+		return false, false
+	}
+
+	e.workerLock.RLock()
+	defer e.workerLock.RUnlock()
+
+	healthy, known = e.health[ip]
+	return healthy, known
+}
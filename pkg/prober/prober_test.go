@@ -2,8 +2,26 @@ package prober
 
 import (
 	"testing"
+
+	esov1alpha1 "github.com/CrowdfoxGmbH/external-service-operator/pkg/apis/eso/v1alpha1"
 )
 
 func TestAddEndpoint(t *testing.T) {
 
 }
+
+func TestRemoveWorkerForgetsLastProbe(t *testing.T) {
+	prober := &externalServiceProber{workers: map[string]*worker{}, health: map[string]bool{}}
+	w := &worker{ip: "10.0.102.10", parent: prober}
+	prober.workers[w.ip] = w
+	prober.setLastProbe(w.ip, esov1alpha1.ProbeResult{Result: "Success"})
+
+	prober.removeWorker(w)
+
+	if result := prober.getLastProbe(w.ip); result != nil {
+		t.Errorf("Expected the last probe of a removed worker to be forgotten but got %+v", result)
+	}
+	if len(prober.lastProbes) != 0 {
+		t.Errorf("Expected no last probes to be left but got %v", prober.lastProbes)
+	}
+}
@@ -0,0 +1,130 @@
+package prober
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"time"
+
+	esov1alpha1 "github.com/CrowdfoxGmbH/external-service-operator/pkg/apis/eso/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// proberStatus is the part of the ExternalService status owned by the prober
+type proberStatus struct {
+	activePriority *int32
+	addresses      []esov1alpha1.ExternalServiceAddressStatus
+}
+
+// buildStatus collects the state of every address of the ExternalService
+func buildStatus(externalService *esov1alpha1.ExternalService, subsets []corev1.EndpointSubset, activePriority *int32, health func(ip string) (bool, bool), ejectedUntil func(ip string) (time.Time, bool), lastProbe func(ip string) *esov1alpha1.ProbeResult) proberStatus {
+	ready := map[string]bool{}
+	for _, subset := range subsets {
+		for _, address := range subset.Addresses {
+			ready[address.IP] = true
+		}
+	}
+
+	status := proberStatus{
+		activePriority: activePriority,
+		addresses:      []esov1alpha1.ExternalServiceAddressStatus{},
+	}
+	for _, ip := range externalService.GetIps() {
+		addressStatus := esov1alpha1.ExternalServiceAddressStatus{
+			IP:    ip,
+			Ready: ready[ip],
+		}
+		if healthy, known := health(ip); known {
+			addressStatus.Healthy = &healthy
+		}
+		if override := externalService.GetReadinessOverride(ip, time.Now()); override != nil {
+			addressStatus.Override = override.Name()
+		}
+		if until, ejected := ejectedUntil(ip); ejected {
+			ejectedUntil := metav1.NewTime(until)
+			addressStatus.EjectedUntil = &ejectedUntil
+		}
+		addressStatus.LastProbe = lastProbe(ip)
+		status.addresses = append(status.addresses, addressStatus)
+	}
+
+	return status
+}
+
+func (e *externalServiceProber) getEjectedUntil(ip string) (time.Time, bool) {
+	if e == nil {
+		return time.Time{}, false
+	}
+	return e.outlier.getEjectedUntil(ip)
+}
+
+// reportStatus updates the status of the ExternalService when the state of its addresses changed
+func (e *externalServiceProber) reportStatus(c client.Client, subsets []corev1.EndpointSubset, activePriority *int32) error {
+	externalService := e.getExternalService()
+	if externalService == nil {
+		return nil
+	}
+
+	status := buildStatus(externalService, subsets, activePriority, e.getHealth, e.getEjectedUntil, e.getLastProbe)
+
+	e.statusLock.Lock()
+	defer e.statusLock.Unlock()
+
+	if e.reported && reflect.DeepEqual(e.status, status) {
+		return nil
+	}
+
+	key := types.NamespacedName{Name: externalService.Name, Namespace: externalService.Namespace}
+	if err := updateStatus(c, key, status); err != nil {
+		return err
+	}
+
+	e.status = status
+	e.reported = true
+	return nil
+}
+
+// updateStatus writes the state of the addresses and the currently active priority tier to the status of the ExternalService
+func updateStatus(c client.Client, key types.NamespacedName, status proberStatus) error {
+	externalService := &esov1alpha1.ExternalService{}
+	if err := c.Get(context.TODO(), key, externalService); err != nil {
+		return err
+	}
+
+	condition := esov1alpha1.ExternalServiceCondition{
+		Type:    esov1alpha1.PriorityTierActive,
+		Status:  corev1.ConditionFalse,
+		Reason:  "NoHealthyAddresses",
+		Message: "No priority tier has healthy addresses",
+	}
+	if status.activePriority != nil {
+		condition.Status = corev1.ConditionTrue
+		condition.Reason = "TierHealthy"
+		condition.Message = fmt.Sprintf("Priority tier %d is active", *status.activePriority)
+	}
+
+	changed := externalService.Status.SetCondition(condition)
+	if !int32PtrEqual(externalService.Status.ActivePriority, status.activePriority) {
+		externalService.Status.ActivePriority = status.activePriority
+		changed = true
+	}
+	if !reflect.DeepEqual(externalService.Status.Addresses, status.addresses) {
+		externalService.Status.Addresses = status.addresses
+		changed = true
+	}
+
+	if !changed {
+		return nil
+	}
+	return c.Status().Update(context.TODO(), externalService)
+}
+
+func int32PtrEqual(a *int32, b *int32) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
@@ -8,10 +8,12 @@ import (
 	"net"
 	"net/http"
 	"net/url"
+	"reflect"
 	"strconv"
 	"strings"
 	"time"
 
+	esov1alpha1 "github.com/CrowdfoxGmbH/external-service-operator/pkg/apis/eso/v1alpha1"
 	corev1 "k8s.io/api/core/v1"
 	kerrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/types"
@@ -32,6 +34,7 @@ type worker struct {
 	ip              string
 	lastResultType  probe.Result
 	lastResultCount int32
+	lastProbe       esov1alpha1.ProbeResult
 }
 
 func (w *worker) run() {
@@ -47,17 +50,17 @@ func (w *worker) run() {
 	defer func() {
 		// Clean up.
 		probeTicker.Stop()
-		log.Info("Stop Prober", "endpoint", w.parent.externalService.Name, "IP", w.ip)
+		log.Info("Stop Prober", "endpoint", w.namespacedName.Name, "IP", w.ip)
 		w.parent.removeWorker(w)
 	}()
 
-	log.Info("Start Prober", "endpoint", w.parent.externalService.Name, "IP", w.ip)
+	log.Info("Start Prober", "endpoint", w.namespacedName.Name, "IP", w.ip)
 probeLoop:
 	for w.doProbe() {
 		// Wait for next probe tick.
 		select {
 		case <-w.stopCh:
-			log.V(1).Info("Received Stop", "endpoint", w.parent.externalService.Name, "IP", w.ip)
+			log.V(1).Info("Received Stop", "endpoint", w.namespacedName.Name, "IP", w.ip)
 			break probeLoop
 		case <-probeTicker.C:
 			// continue
@@ -66,7 +69,7 @@ probeLoop:
 }
 
 func (w *worker) stop() {
-	log.V(1).Info("Sending Stop Signal", "endpoint", w.parent.externalService.Name, "ip", w.ip)
+	log.V(1).Info("Sending Stop Signal", "endpoint", w.namespacedName.Name, "ip", w.ip)
 	select {
 	case w.stopCh <- struct{}{}:
 	default:
@@ -94,24 +97,17 @@ func (w *worker) doProbe() (keepGoing bool) {
 		}
 	}
 
-	var result probe.Result
-	message := "Unknown Probe Type."
-	err = errors.New("Unexpected Error")
-
-	if w.probe.HTTPGet != nil {
-		result, message, err = w.runHttpProbe()
-	} else if w.probe.TCPSocket != nil {
-		result, message, err = w.runTcpProbe()
-	} else {
-		runLogger.Error(err, message)
+	result, lastProbe, err := w.probeOnce()
+	if err == errUnknownProbeType {
+		runLogger.Error(err, "Unknown Probe Type.")
 		return false
 	}
-
 	if err != nil {
-		runLogger.Error(err, "Runtimeerror during probe", "message", message)
+		runLogger.Error(err, "Runtimeerror during probe")
 		// TODO: add Note to ExternalServiceRessource that something is wrong with the Probe
 		return false
 	}
+	w.lastProbe = lastProbe
 
 	if w.lastResultType == result {
 		//prevent overflow
@@ -154,6 +150,9 @@ func (w *worker) runHttpProbe() (probe.Result, string, error) {
 	path := w.probe.HTTPGet.Path
 	url := formatURL(scheme, w.ip, port, path)
 	headers := buildHeader(w.probe.HTTPGet)
+	if address, found := w.parent.getAddress(w.ip); found && address.ProbeHost != "" {
+		headers.Set("Host", address.ProbeHost)
+	}
 	timeout := time.Duration(w.probe.TimeoutSeconds) * time.Second
 
 	return w.parent.httpprober.Probe(url, headers, timeout)
@@ -195,85 +194,100 @@ func formatURL(scheme string, host string, port int, path string) *url.URL {
 }
 
 func (w *worker) ensureReady(endpoint *corev1.Endpoints) error {
-	newNotReadyAddresses := []corev1.EndpointAddress{}
-	newReadyAddresses := endpoint.Subsets[0].Addresses
-
-	found := false
-
-	for _, address := range endpoint.Subsets[0].NotReadyAddresses {
-		if address.IP != w.ip {
-			newNotReadyAddresses = append(newNotReadyAddresses, address)
-		} else {
-			newReadyAddresses = append(newReadyAddresses, address)
-			found = true
-		}
-	}
-
-	for _, address := range endpoint.Subsets[0].Addresses {
-		if address.IP == w.ip {
-			found = true
-			break
-		}
-	}
-
-	if !found {
+	if !containsAddress(restoreHiddenAddresses(w.parent.getExternalService(), endpoint.Subsets), w.ip) {
 		return errors.New("couldn't find endpoint while marking it to ready")
 	}
 
-	return w.updateEndpoint(endpoint, newNotReadyAddresses, newReadyAddresses)
+	return w.applyHealth(endpoint, true)
 }
 
 func (w *worker) ensureUnready(endpoint *corev1.Endpoints) error {
-	newNotReadyAddresses := endpoint.Subsets[0].NotReadyAddresses
-	newReadyAddresses := []corev1.EndpointAddress{}
+	if !containsAddress(restoreHiddenAddresses(w.parent.getExternalService(), endpoint.Subsets), w.ip) {
+		return errors.New("couldn't find endpoint while marking it to unready")
+	}
 
-	found := false
+	return w.applyHealth(endpoint, false)
+}
 
-	for _, address := range endpoint.Subsets[0].Addresses {
-		if address.IP != w.ip {
-			newReadyAddresses = append(newReadyAddresses, address)
-		} else {
-			newNotReadyAddresses = append(newNotReadyAddresses, address)
-			found = true
-		}
+// applyHealth stores the health of the worker's IP and recalculates which addresses
+// of the endpoint are ready. Other addresses keep their state unless their worker
+// already reported a result.
+func (w *worker) applyHealth(endpoint *corev1.Endpoints, healthy bool) error {
+	// only the probe which changes the health is kept, so the status is not updated on every probe
+	if previous, known := w.parent.getHealth(w.ip); !known || previous != healthy {
+		w.parent.setLastProbe(w.ip, w.lastProbe)
 	}
+	w.parent.setHealth(w.ip, healthy)
+	externalService := w.parent.getExternalService()
 
-	for _, address := range endpoint.Subsets[0].NotReadyAddresses {
+	subsets, activePriority := splitVisibleAddresses(externalService, endpoint.Subsets, func(address corev1.EndpointAddress, ready bool) bool {
 		if address.IP == w.ip {
-			found = true
-			break
+			return applyManualState(externalService, w.ip, healthy && !w.parent.isEjected(w.ip))
 		}
+		return w.parent.isEligible(address, ready)
+	})
+
+	if err := updateEndpoint(w.client, endpoint, subsets); err != nil {
+		return err
 	}
 
-	if !found {
-		return errors.New("couldn't find endpoint while marking it to unready")
+	return w.parent.reportStatus(w.client, subsets, activePriority)
+}
+
+// newProbeResult records the outcome of a probe for the status
+func newProbeResult(result probe.Result, message string, latency time.Duration) esov1alpha1.ProbeResult {
+	probeResult := esov1alpha1.ProbeResult{
+		Result:              "Unknown",
+		Message:             message,
+		LatencyMilliseconds: int64(latency / time.Millisecond),
+	}
+	switch result {
+	case probe.Success:
+		probeResult.Result = "Success"
+	case probe.Failure:
+		probeResult.Result = "Failure"
 	}
+	return probeResult
+}
 
-	return w.updateEndpoint(endpoint, newNotReadyAddresses, newReadyAddresses)
+func containsAddress(subsets []corev1.EndpointSubset, ip string) bool {
+	for _, subset := range subsets {
+		for _, address := range subset.Addresses {
+			if address.IP == ip {
+				return true
+			}
+		}
+		for _, address := range subset.NotReadyAddresses {
+			if address.IP == ip {
+				return true
+			}
+		}
+	}
+	return false
 }
 
-func (w *worker) updateEndpoint(endpoint *corev1.Endpoints, notReady []corev1.EndpointAddress, ready []corev1.EndpointAddress) error {
-	changed := !addressesEqual(endpoint.Subsets[0].NotReadyAddresses, notReady) || !addressesEqual(endpoint.Subsets[0].Addresses, ready)
+func updateEndpoint(c client.Client, endpoint *corev1.Endpoints, subsets []corev1.EndpointSubset) error {
+	changed := len(endpoint.Subsets) != len(subsets)
+	for i := 0; !changed && i < len(subsets); i++ {
+		changed = !addressesEqual(endpoint.Subsets[i].NotReadyAddresses, subsets[i].NotReadyAddresses) || !addressesEqual(endpoint.Subsets[i].Addresses, subsets[i].Addresses)
+	}
 
 	if changed {
-		endpoint.Subsets[0].NotReadyAddresses = notReady
-		endpoint.Subsets[0].Addresses = ready
+		endpoint.Subsets = subsets
 		log.Info("Update Endpoint, because availability changed", "endpoint", endpoint.Name, "namespace", endpoint.Namespace)
-		return w.client.Update(context.TODO(), endpoint)
+		return c.Update(context.TODO(), endpoint)
 	}
 	return nil
 }
 
 // This function asserts that both arrays are sorted
 func addressesEqual(a []corev1.EndpointAddress, b []corev1.EndpointAddress) bool {
-	if cap(a) != cap(b) {
+	if len(a) != len(b) {
 		return false
 	}
 
 	for i, valueA := range a {
-		valueB := b[i]
-
-		if valueB != valueA {
+		if !reflect.DeepEqual(valueA, b[i]) {
 			return false
 		}
 	}
@@ -7,6 +7,7 @@ import (
 	"net/url"
 	"time"
 
+	esov1alpha1 "github.com/CrowdfoxGmbH/external-service-operator/pkg/apis/eso/v1alpha1"
 	"github.com/CrowdfoxGmbH/external-service-operator/pkg/testutils"
 
 	corev1 "k8s.io/api/core/v1"
@@ -524,6 +525,32 @@ func TestRunHttpProbe(t *testing.T) {
 	}
 }
 
+func TestRunHttpProbeWithAddressProbeHost(t *testing.T) {
+	externalService := testutils.CreateDefaultExternalService()
+	externalService.Spec.Addresses = []esov1alpha1.ExternalServiceAddress{
+		esov1alpha1.ExternalServiceAddress{IP: "10.0.102.16", ProbeHost: "db-0.example.com"},
+	}
+	probe := testutils.CreateDefaultTestProbe()
+	probe.HTTPGet.Host = "www.example.com"
+	httpProber := newFakeHTTPProber(Success)
+
+	worker := worker{
+		parent: &externalServiceProber{
+			externalService: externalService,
+			httpprober:      httpProber,
+		},
+		ip:    "10.0.102.16",
+		probe: probe,
+	}
+
+	worker.runHttpProbe()
+	testutils.ExpectEqStr(httpProber.headers.Get("Host"), "db-0.example.com", t)
+
+	worker.ip = "10.0.102.10"
+	worker.runHttpProbe()
+	testutils.ExpectEqStr(httpProber.headers.Get("Host"), "www.example.com", t)
+}
+
 type testLogger struct {
 	errorLogs []string
 	infoLogs  []string
@@ -595,7 +622,8 @@ const (
 )
 
 type fakeHTTPProber struct {
-	answer fakeHTTPAnswer
+	answer  fakeHTTPAnswer
+	headers http.Header
 }
 
 func newFakeHTTPProber(answer fakeHTTPAnswer) *fakeHTTPProber {
@@ -604,7 +632,8 @@ func newFakeHTTPProber(answer fakeHTTPAnswer) *fakeHTTPProber {
 	}
 }
 
-func (p *fakeHTTPProber) Probe(_ *url.URL, _ http.Header, _ time.Duration) (probe.Result, string, error) {
+func (p *fakeHTTPProber) Probe(_ *url.URL, headers http.Header, _ time.Duration) (probe.Result, string, error) {
+	p.headers = headers
 	switch p.answer {
 	case Error:
 		return probe.Failure, "Fake error", errors.New("Error")
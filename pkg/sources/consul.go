@@ -0,0 +1,174 @@
+package sources
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	esov1alpha1 "github.com/CrowdfoxGmbH/external-service-operator/pkg/apis/eso/v1alpha1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// consulServiceEntry is an entry of the response of /v1/health/service/<service>
+type consulServiceEntry struct {
+	Node struct {
+		Node    string
+		Address string
+	}
+	Service struct {
+		Address string
+		Port    int32
+		Tags    []string
+	}
+	Checks []struct {
+		Status string
+	}
+}
+
+// consulSource watches the instances of a service in the Consul catalog with blocking queries
+type consulSource struct {
+	client     client.Client
+	namespace  string
+	config     esov1alpha1.ConsulSource
+	wait       time.Duration
+	httpClient *http.Client
+	index      uint64
+	// moved is true if the last response moved the index forward. Otherwise the next query
+	// may not block, so the worker has to wait for the interval.
+	moved bool
+}
+
+func newConsulSource(c client.Client, namespace string, config esov1alpha1.ConsulSource, wait time.Duration) *consulSource {
+	return &consulSource{
+		client:     c,
+		namespace:  namespace,
+		config:     config,
+		wait:       wait,
+		httpClient: &http.Client{},
+	}
+}
+
+func (s *consulSource) blocking() bool {
+	return s.moved
+}
+
+// discover waits until the catalog changed since the last discovery or the wait time is over
+func (s *consulSource) discover(ctx context.Context) ([]esov1alpha1.ExternalServiceAddress, error) {
+	request, err := s.newRequest(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := s.httpClient.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Consul returned %v", response.Status)
+	}
+
+	entries := []consulServiceEntry{}
+	if err := json.NewDecoder(response.Body).Decode(&entries); err != nil {
+		return nil, fmt.Errorf("could not decode response of Consul: %v", err)
+	}
+
+	// a missing index means Consul does not block, a lower index means its state was reset,
+	// so start over
+	index, _ := strconv.ParseUint(response.Header.Get("X-Consul-Index"), 10, 64)
+	s.moved = index > s.index
+	if index < s.index {
+		index = 0
+	}
+	s.index = index
+
+	return s.addresses(entries)
+}
+
+func (s *consulSource) newRequest(ctx context.Context) (*http.Request, error) {
+	query := url.Values{}
+	if s.config.Datacenter != "" {
+		query.Set("dc", s.config.Datacenter)
+	}
+	if s.index > 0 {
+		query.Set("index", strconv.FormatUint(s.index, 10))
+		query.Set("wait", fmt.Sprintf("%ds", int(s.wait.Seconds())))
+	}
+
+	requestURL := fmt.Sprintf("%v/v1/health/service/%v?%v", strings.TrimSuffix(s.config.Address, "/"), url.PathEscape(s.config.Service), query.Encode())
+	request, err := http.NewRequest(http.MethodGet, requestURL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if s.config.TokenSecretRef != nil {
+		token, err := readSecretKey(ctx, s.client, s.namespace, *s.config.TokenSecretRef)
+		if err != nil {
+			return nil, err
+		}
+		request.Header.Set("X-Consul-Token", strings.TrimSpace(token))
+	}
+	return request.WithContext(ctx), nil
+}
+
+// addresses returns the addresses of the instances having all tags. The address of the
+// service wins over the one of its node. Consul allows hostnames there, which Endpoints can
+// not hold, so a single one fails the discovery.
+func (s *consulSource) addresses(entries []consulServiceEntry) ([]esov1alpha1.ExternalServiceAddress, error) {
+	addresses := []esov1alpha1.ExternalServiceAddress{}
+	known := map[string]bool{}
+	for _, entry := range entries {
+		if !hasTags(entry.Service.Tags, s.config.Tags) || (s.config.PassingOnly && !isPassing(entry)) {
+			continue
+		}
+
+		address := entry.Service.Address
+		if address == "" {
+			address = entry.Node.Address
+		}
+		parsed := net.ParseIP(address)
+		if parsed == nil {
+			return nil, fmt.Errorf("instance on node %v has no IP address but %q", entry.Node.Node, address)
+		}
+		ip := parsed.String()
+		if known[ip] {
+			continue
+		}
+		known[ip] = true
+
+		addresses = append(addresses, esov1alpha1.ExternalServiceAddress{IP: ip, Port: entry.Service.Port})
+	}
+	return addresses, nil
+}
+
+func hasTags(tags []string, wanted []string) bool {
+	for _, tag := range wanted {
+		found := false
+		for _, existing := range tags {
+			if existing == tag {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+func isPassing(entry consulServiceEntry) bool {
+	for _, check := range entry.Checks {
+		if check.Status != "passing" {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,198 @@
+package sources
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	esov1alpha1 "github.com/CrowdfoxGmbH/external-service-operator/pkg/apis/eso/v1alpha1"
+	"github.com/CrowdfoxGmbH/external-service-operator/pkg/testutils"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// testConsulServer implements /v1/health/service/<service> including blocking queries
+type testConsulServer struct {
+	*httptest.Server
+	lock    sync.Mutex
+	changed *sync.Cond
+	index   uint64
+	entries []map[string]interface{}
+}
+
+func startTestConsulServer(t *testing.T, token string) *testConsulServer {
+	server := &testConsulServer{index: 1}
+	server.changed = sync.NewCond(&server.lock)
+	server.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/health/service/web" || r.URL.Query().Get("dc") != "dc2" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if r.Header.Get("X-Consul-Token") != token {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+
+		server.lock.Lock()
+		defer server.lock.Unlock()
+
+		// a blocking query waits until the index moved past the given one
+		if index, err := strconv.ParseUint(r.URL.Query().Get("index"), 10, 64); err == nil {
+			for server.index <= index {
+				server.changed.Wait()
+			}
+		}
+
+		w.Header().Set("X-Consul-Index", strconv.FormatUint(server.index, 10))
+		json.NewEncoder(w).Encode(server.entries)
+	}))
+	return server
+}
+
+func (s *testConsulServer) setEntries(entries ...map[string]interface{}) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.entries = entries
+	s.index++
+	s.changed.Broadcast()
+}
+
+func consulEntry(nodeAddress string, serviceAddress string, port int, status string, tags ...string) map[string]interface{} {
+	return map[string]interface{}{
+		"Node":    map[string]interface{}{"Node": "node-" + nodeAddress, "Address": nodeAddress},
+		"Service": map[string]interface{}{"Service": "web", "Address": serviceAddress, "Port": port, "Tags": tags},
+		"Checks":  []interface{}{map[string]interface{}{"Status": status}},
+	}
+}
+
+func newTestConsulSource(server *testConsulServer, config esov1alpha1.ConsulSource) *consulSource {
+	config.Address = server.URL
+	config.Service = "web"
+	config.Datacenter = "dc2"
+	config.TokenSecretRef = &corev1.SecretKeySelector{LocalObjectReference: corev1.LocalObjectReference{Name: "consul"}, Key: "token"}
+
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "consul", Namespace: "external-services"},
+		Data:       map[string][]byte{"token": []byte("s3cr3t\n")},
+	}
+	return newConsulSource(testutils.InitFakeClient(secret), "external-services", config, time.Second)
+}
+
+func TestConsulSourceFiltersInstances(t *testing.T) {
+	server := startTestConsulServer(t, "s3cr3t")
+	defer server.Close()
+	server.setEntries(
+		consulEntry("10.0.0.1", "", 8080, "passing", "v2", "blue"),
+		consulEntry("10.0.0.2", "10.1.0.2", 8081, "passing", "v2"),
+		consulEntry("10.0.0.3", "", 8080, "critical", "v2"),
+		consulEntry("10.0.0.4", "", 8080, "passing", "v1"),
+	)
+
+	source := newTestConsulSource(server, esov1alpha1.ConsulSource{Tags: []string{"v2"}, PassingOnly: true})
+	addresses, err := source.discover(context.TODO())
+	if err != nil {
+		t.Fatalf("discover: (%v)", err)
+	}
+
+	testutils.ExpectEqInt(int32(len(addresses)), 2, t)
+	testutils.ExpectEqStr(addresses[0].IP, "10.0.0.1", t)
+	testutils.ExpectEqStr(addresses[1].IP, "10.1.0.2", t)
+	testutils.ExpectEqInt(addresses[1].Port, 8081, t)
+}
+
+func TestConsulSourceWaitsForChanges(t *testing.T) {
+	server := startTestConsulServer(t, "s3cr3t")
+	defer server.Close()
+	server.setEntries(consulEntry("10.0.0.1", "", 8080, "passing"))
+
+	source := newTestConsulSource(server, esov1alpha1.ConsulSource{})
+	if _, err := source.discover(context.TODO()); err != nil {
+		t.Fatalf("discover: (%v)", err)
+	}
+
+	// the next discovery blocks until the catalog changes
+	result := make(chan []esov1alpha1.ExternalServiceAddress)
+	go func() {
+		addresses, _ := source.discover(context.TODO())
+		result <- addresses
+	}()
+
+	select {
+	case <-result:
+		t.Fatalf("Expected discovery to block until the catalog changes")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	server.setEntries(consulEntry("10.0.0.1", "", 8080, "passing"), consulEntry("10.0.0.2", "", 8080, "passing"))
+	select {
+	case addresses := <-result:
+		testutils.ExpectEqInt(int32(len(addresses)), 2, t)
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Expected discovery to return after the catalog changed")
+	}
+}
+
+func TestConsulSourceFailsOnHostname(t *testing.T) {
+	server := startTestConsulServer(t, "s3cr3t")
+	defer server.Close()
+	server.setEntries(
+		consulEntry("10.0.0.1", "", 8080, "passing"),
+		consulEntry("10.0.0.2", "web-2.example.com", 8080, "passing"),
+	)
+
+	source := newTestConsulSource(server, esov1alpha1.ConsulSource{})
+	_, err := source.discover(context.TODO())
+	testutils.ExpectEqStr(fmt.Sprint(err), `instance on node node-10.0.0.2 has no IP address but "web-2.example.com"`, t)
+}
+
+func TestConsulSourceFailsWithoutToken(t *testing.T) {
+	server := startTestConsulServer(t, "another-token")
+	defer server.Close()
+
+	source := newTestConsulSource(server, esov1alpha1.ConsulSource{})
+	_, err := source.discover(context.TODO())
+	testutils.ExpectEqStr(fmt.Sprint(err), "Consul returned 403 Forbidden", t)
+}
+
+func TestConsulSourcePollsWithoutIndex(t *testing.T) {
+	// Given a server which does not send X-Consul-Index, unless index is set
+	var lock sync.Mutex
+	index := ""
+	setIndex := func(value string) {
+		lock.Lock()
+		defer lock.Unlock()
+		index = value
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		defer lock.Unlock()
+		if index != "" {
+			w.Header().Set("X-Consul-Index", index)
+		}
+		json.NewEncoder(w).Encode([]map[string]interface{}{consulEntry("10.0.0.1", "", 8080, "passing")})
+	}))
+	defer server.Close()
+
+	source := newConsulSource(testutils.InitFakeClient(), "external-services", esov1alpha1.ConsulSource{Address: server.URL, Service: "web"}, time.Second)
+	if _, err := source.discover(context.TODO()); err != nil {
+		t.Fatalf("discover: (%v)", err)
+	}
+
+	// Then queries don't block, so the worker has to wait for the interval
+	testutils.ExpectFalse(source.blocking(), t)
+
+	// When the index moves forward, queries block
+	setIndex("5")
+	source.discover(context.TODO())
+	testutils.ExpectTrue(source.blocking(), t)
+
+	// When it stays the same, the query may have returned right away
+	source.discover(context.TODO())
+	testutils.ExpectFalse(source.blocking(), t)
+}
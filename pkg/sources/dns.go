@@ -0,0 +1,62 @@
+package sources
+
+import (
+	"context"
+	"fmt"
+	"net"
+
+	esov1alpha1 "github.com/CrowdfoxGmbH/external-service-operator/pkg/apis/eso/v1alpha1"
+)
+
+// dnsSRVSource resolves the targets of a SRV record to addresses
+type dnsSRVSource struct {
+	name     string
+	resolver *net.Resolver
+}
+
+func newDNSSRVSource(config esov1alpha1.DNSSRVSource) *dnsSRVSource {
+	resolver := net.DefaultResolver
+	if config.Server != "" {
+		resolver = &net.Resolver{
+			PreferGo: true,
+			Dial: func(ctx context.Context, network string, _ string) (net.Conn, error) {
+				dialer := net.Dialer{}
+				return dialer.DialContext(ctx, network, config.Server)
+			},
+		}
+	}
+
+	return &dnsSRVSource{name: config.Name, resolver: resolver}
+}
+
+// discover returns every IP of every target. If a single target can not be resolved, the
+// discovery fails, so a partial result never replaces the last known addresses.
+func (s *dnsSRVSource) discover(ctx context.Context) ([]esov1alpha1.ExternalServiceAddress, error) {
+	_, records, err := s.resolver.LookupSRV(ctx, "", "", s.name)
+	if err != nil {
+		return nil, err
+	}
+
+	addresses := []esov1alpha1.ExternalServiceAddress{}
+	known := map[string]bool{}
+	for _, record := range records {
+		ips, err := s.resolver.LookupIPAddr(ctx, record.Target)
+		if err != nil {
+			return nil, fmt.Errorf("could not resolve target %v: %v", record.Target, err)
+		}
+
+		for _, ip := range ips {
+			if known[ip.IP.String()] {
+				continue
+			}
+			known[ip.IP.String()] = true
+
+			addresses = append(addresses, esov1alpha1.ExternalServiceAddress{
+				IP:       ip.IP.String(),
+				Port:     int32(record.Port),
+				Priority: int32(record.Priority),
+			})
+		}
+	}
+	return addresses, nil
+}
@@ -0,0 +1,172 @@
+package sources
+
+import (
+	"context"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	esov1alpha1 "github.com/CrowdfoxGmbH/external-service-operator/pkg/apis/eso/v1alpha1"
+	"github.com/CrowdfoxGmbH/external-service-operator/pkg/testutils"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// testDNSServer answers SRV and A queries from its records. While failing it answers
+// every query with SERVFAIL.
+type testDNSServer struct {
+	conn    net.PacketConn
+	lock    sync.Mutex
+	srv     map[string][]dnsmessage.SRVResource
+	a       map[string][][4]byte
+	failing bool
+}
+
+func startTestDNSServer(t *testing.T) *testDNSServer {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: (%v)", err)
+	}
+
+	server := &testDNSServer{conn: conn, srv: map[string][]dnsmessage.SRVResource{}, a: map[string][][4]byte{}}
+	go server.serve()
+	return server
+}
+
+func (s *testDNSServer) address() string {
+	return s.conn.LocalAddr().String()
+}
+
+func (s *testDNSServer) setFailing(failing bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.failing = failing
+}
+
+func (s *testDNSServer) serve() {
+	buffer := make([]byte, 512)
+	for {
+		n, addr, err := s.conn.ReadFrom(buffer)
+		if err != nil {
+			return
+		}
+
+		var query dnsmessage.Message
+		if err := query.Unpack(buffer[:n]); err != nil || len(query.Questions) != 1 {
+			continue
+		}
+		if response, err := s.answer(query); err == nil {
+			s.conn.WriteTo(response, addr)
+		}
+	}
+}
+
+func (s *testDNSServer) answer(query dnsmessage.Message) ([]byte, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	question := query.Questions[0]
+	header := dnsmessage.Header{ID: query.ID, Response: true, Authoritative: true}
+	name := question.Name.String()
+
+	switch {
+	case s.failing:
+		header.RCode = dnsmessage.RCodeServerFailure
+	case question.Type == dnsmessage.TypeSRV && s.srv[name] == nil:
+		header.RCode = dnsmessage.RCodeNameError
+	case question.Type == dnsmessage.TypeA && s.a[name] == nil:
+		header.RCode = dnsmessage.RCodeNameError
+	}
+
+	builder := dnsmessage.NewBuilder(nil, header)
+	builder.EnableCompression()
+	if err := builder.StartQuestions(); err != nil {
+		return nil, err
+	}
+	if err := builder.Question(question); err != nil {
+		return nil, err
+	}
+	if err := builder.StartAnswers(); err != nil {
+		return nil, err
+	}
+
+	resourceHeader := dnsmessage.ResourceHeader{Name: question.Name, Class: dnsmessage.ClassINET, TTL: 1}
+	if !s.failing {
+		switch question.Type {
+		case dnsmessage.TypeSRV:
+			for _, record := range s.srv[name] {
+				if err := builder.SRVResource(resourceHeader, record); err != nil {
+					return nil, err
+				}
+			}
+		case dnsmessage.TypeA:
+			for _, ip := range s.a[name] {
+				if err := builder.AResource(resourceHeader, dnsmessage.AResource{A: ip}); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+	return builder.Finish()
+}
+
+// removeA removes the A records of a name
+func (s *testDNSServer) removeA(name string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	delete(s.a, name)
+}
+
+func newTestDNSServerWithLDAP(t *testing.T) *testDNSServer {
+	server := startTestDNSServer(t)
+	// the server is already serving, so the records are only set under its lock
+	server.lock.Lock()
+	defer server.lock.Unlock()
+	server.srv["_ldap._tcp.example.com."] = []dnsmessage.SRVResource{
+		dnsmessage.SRVResource{Priority: 10, Weight: 1, Port: 389, Target: dnsmessage.MustNewName("ldap1.example.com.")},
+		dnsmessage.SRVResource{Priority: 20, Weight: 1, Port: 1389, Target: dnsmessage.MustNewName("ldap2.example.com.")},
+	}
+	server.a["ldap1.example.com."] = [][4]byte{[4]byte{10, 0, 0, 1}, [4]byte{10, 0, 0, 2}}
+	server.a["ldap2.example.com."] = [][4]byte{[4]byte{10, 0, 0, 3}}
+	return server
+}
+
+func TestDNSSRVSourceDiscoversTargets(t *testing.T) {
+	server := newTestDNSServerWithLDAP(t)
+	defer server.conn.Close()
+
+	source := newDNSSRVSource(esov1alpha1.DNSSRVSource{Name: "_ldap._tcp.example.com.", Server: server.address()})
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	addresses, err := source.discover(ctx)
+	if err != nil {
+		t.Fatalf("discover: (%v)", err)
+	}
+
+	testutils.ExpectEqInt(int32(len(addresses)), 3, t)
+	ports := map[string]int32{}
+	priorities := map[string]int32{}
+	for _, address := range addresses {
+		ports[address.IP] = address.Port
+		priorities[address.IP] = address.Priority
+	}
+	testutils.ExpectEqInt(ports["10.0.0.2"], 389, t)
+	testutils.ExpectEqInt(ports["10.0.0.3"], 1389, t)
+	testutils.ExpectEqInt(priorities["10.0.0.1"], 10, t)
+	testutils.ExpectEqInt(priorities["10.0.0.3"], 20, t)
+}
+
+func TestDNSSRVSourceFailsOnUnresolvableTarget(t *testing.T) {
+	server := newTestDNSServerWithLDAP(t)
+	defer server.conn.Close()
+	server.removeA("ldap2.example.com.")
+
+	source := newDNSSRVSource(esov1alpha1.DNSSRVSource{Name: "_ldap._tcp.example.com.", Server: server.address()})
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if _, err := source.discover(ctx); err == nil {
+		t.Errorf("Expected discovery to fail if a target can not be resolved")
+	}
+}
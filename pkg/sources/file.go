@@ -0,0 +1,162 @@
+package sources
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	esov1alpha1 "github.com/CrowdfoxGmbH/external-service-operator/pkg/apis/eso/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// fileSource reads a list of addresses from a key of a ConfigMap or a Secret
+type fileSource struct {
+	client    client.Client
+	namespace string
+	config    esov1alpha1.FileSource
+}
+
+func newFileSource(c client.Client, namespace string, config esov1alpha1.FileSource) (*fileSource, error) {
+	if (config.ConfigMapKeyRef == nil) == (config.SecretKeyRef == nil) {
+		return nil, fmt.Errorf("exactly one of configMapKeyRef and secretKeyRef has to be set")
+	}
+	switch config.Format {
+	case "", esov1alpha1.FileFormatLines, esov1alpha1.FileFormatCSV, esov1alpha1.FileFormatJSON:
+	default:
+		return nil, fmt.Errorf("unknown format %q", config.Format)
+	}
+	return &fileSource{client: c, namespace: namespace, config: config}, nil
+}
+
+func (s *fileSource) discover(ctx context.Context) ([]esov1alpha1.ExternalServiceAddress, error) {
+	var value string
+	var err error
+	if s.config.SecretKeyRef != nil {
+		value, err = readSecretKey(ctx, s.client, s.namespace, *s.config.SecretKeyRef)
+	} else {
+		value, err = s.readConfigMapKey(ctx, *s.config.ConfigMapKeyRef)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// a single invalid entry fails the whole list, so a half written file is never applied
+	return parseAddressList(value, s.config.Format)
+}
+
+func (s *fileSource) readConfigMapKey(ctx context.Context, selector corev1.ConfigMapKeySelector) (string, error) {
+	configMap := &corev1.ConfigMap{}
+	if err := s.client.Get(ctx, types.NamespacedName{Name: selector.Name, Namespace: s.namespace}, configMap); err != nil {
+		return "", fmt.Errorf("could not read ConfigMap %v: %v", selector.Name, err)
+	}
+
+	if value, found := configMap.Data[selector.Key]; found {
+		return value, nil
+	}
+	if value, found := configMap.BinaryData[selector.Key]; found {
+		return string(value), nil
+	}
+	return "", fmt.Errorf("ConfigMap %v has no key %v", selector.Name, selector.Key)
+}
+
+// parseAddressList parses a list of addresses in the given format, detecting it if it is empty
+func parseAddressList(value string, format esov1alpha1.FileFormat) ([]esov1alpha1.ExternalServiceAddress, error) {
+	if format == "" {
+		format = detectFormat(value)
+	}
+
+	switch format {
+	case esov1alpha1.FileFormatJSON:
+		return parseJSONAddressList(value)
+	case esov1alpha1.FileFormatCSV:
+		return parseAddressValues(strings.FieldsFunc(value, func(r rune) bool { return r == ',' || r == '\n' }))
+	}
+
+	values := []string{}
+	for _, line := range strings.Split(value, "\n") {
+		if !strings.HasPrefix(strings.TrimSpace(line), "#") {
+			values = append(values, line)
+		}
+	}
+	return parseAddressValues(values)
+}
+
+func detectFormat(value string) esov1alpha1.FileFormat {
+	trimmed := strings.TrimSpace(value)
+	switch {
+	case strings.HasPrefix(trimmed, "["):
+		return esov1alpha1.FileFormatJSON
+	case strings.Contains(trimmed, ","):
+		return esov1alpha1.FileFormatCSV
+	}
+	return esov1alpha1.FileFormatLines
+}
+
+// parseAddressValues parses every non empty value as <ip>[:<port>]
+func parseAddressValues(values []string) ([]esov1alpha1.ExternalServiceAddress, error) {
+	addresses := []esov1alpha1.ExternalServiceAddress{}
+	for _, value := range values {
+		if strings.TrimSpace(value) == "" {
+			continue
+		}
+		address, err := parseAddress(value)
+		if err != nil {
+			return nil, err
+		}
+		addresses = append(addresses, address)
+	}
+	return addresses, nil
+}
+
+// jsonAddress is an entry of a JSON list, either "<ip>[:<port>]" or an object
+type jsonAddress struct {
+	esov1alpha1.ExternalServiceAddress
+}
+
+func (a *jsonAddress) UnmarshalJSON(data []byte) error {
+	var value string
+	if err := json.Unmarshal(data, &value); err == nil {
+		a.ExternalServiceAddress, err = parseAddress(value)
+		return err
+	}
+
+	var entry struct {
+		IP       string `json:"ip"`
+		Port     int32  `json:"port,omitempty"`
+		Hostname string `json:"hostname,omitempty"`
+	}
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return fmt.Errorf("invalid entry %s", string(data))
+	}
+
+	address, err := parseAddress(entry.IP)
+	if err != nil {
+		return err
+	}
+	if entry.Port != 0 {
+		if entry.Port < 1 || entry.Port > 65535 {
+			return fmt.Errorf("invalid port %d", entry.Port)
+		}
+		address.Port = entry.Port
+	}
+	address.Hostname = entry.Hostname
+	a.ExternalServiceAddress = address
+	return nil
+}
+
+func parseJSONAddressList(value string) ([]esov1alpha1.ExternalServiceAddress, error) {
+	entries := []jsonAddress{}
+	if err := json.Unmarshal([]byte(value), &entries); err != nil {
+		return nil, fmt.Errorf("invalid JSON list: %v", err)
+	}
+
+	addresses := make([]esov1alpha1.ExternalServiceAddress, 0, len(entries))
+	for _, entry := range entries {
+		addresses = append(addresses, entry.ExternalServiceAddress)
+	}
+	return addresses, nil
+}
@@ -0,0 +1,62 @@
+package sources
+
+import (
+	"context"
+	"testing"
+
+	esov1alpha1 "github.com/CrowdfoxGmbH/external-service-operator/pkg/apis/eso/v1alpha1"
+	"github.com/CrowdfoxGmbH/external-service-operator/pkg/testutils"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestParseAddressListFormats(t *testing.T) {
+	lists := map[string]string{
+		"lines": "# terraform output\n10.0.0.1\n\n10.0.0.2:8080\n",
+		"csv":   "10.0.0.1, 10.0.0.2:8080",
+		"json":  `["10.0.0.1", {"ip": "10.0.0.2", "port": 8080, "hostname": "db-2"}]`,
+	}
+
+	for name, list := range lists {
+		addresses, err := parseAddressList(list, "")
+		if err != nil {
+			t.Fatalf("%v: parse: (%v)", name, err)
+		}
+		testutils.ExpectEqInt(int32(len(addresses)), 2, t)
+		testutils.ExpectEqStr(addresses[0].IP, "10.0.0.1", t)
+		testutils.ExpectEqInt(addresses[0].Port, 0, t)
+		testutils.ExpectEqStr(addresses[1].IP, "10.0.0.2", t)
+		testutils.ExpectEqInt(addresses[1].Port, 8080, t)
+	}
+}
+
+func TestParseAddressListFailsOnInvalidEntry(t *testing.T) {
+	for _, list := range []string{"10.0.0.1\nnot-an-ip", "10.0.0.1,10.0.0.2:99999", `["10.0.0.1", {"ip": "10.0.0"}]`} {
+		if _, err := parseAddressList(list, ""); err == nil {
+			t.Errorf("Expected %q to fail", list)
+		}
+	}
+}
+
+func TestFileSourceReadsSecret(t *testing.T) {
+	client := testutils.InitFakeClient(&corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "db-hosts", Namespace: "external-services"},
+		Data:       map[string][]byte{"hosts": []byte("10.0.0.1\n10.0.0.2")},
+	})
+
+	source, err := newFileSource(client, "external-services", esov1alpha1.FileSource{
+		SecretKeyRef: &corev1.SecretKeySelector{LocalObjectReference: corev1.LocalObjectReference{Name: "db-hosts"}, Key: "hosts"},
+	})
+	if err != nil {
+		t.Fatalf("new source: (%v)", err)
+	}
+	addresses, err := source.discover(context.TODO())
+	if err != nil {
+		t.Fatalf("discover: (%v)", err)
+	}
+	testutils.ExpectEqInt(int32(len(addresses)), 2, t)
+
+	if _, err := newFileSource(client, "external-services", esov1alpha1.FileSource{}); err == nil {
+		t.Errorf("Expected a file source without reference to be invalid")
+	}
+}
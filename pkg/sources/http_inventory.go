@@ -0,0 +1,255 @@
+package sources
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"reflect"
+	"strings"
+	"text/template"
+
+	esov1alpha1 "github.com/CrowdfoxGmbH/external-service-operator/pkg/apis/eso/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/ghodss/yaml"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/jsonpath"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// maxInventorySize limits how much of an inventory is read
+const maxInventorySize = 10 << 20
+
+// httpInventorySource polls a JSON or YAML document and extracts the addresses from it
+type httpInventorySource struct {
+	client     client.Client
+	namespace  string
+	config     esov1alpha1.HTTPInventorySource
+	httpClient *http.Client
+	items      *jsonpath.JSONPath
+	ip         *jsonpath.JSONPath
+	port       *jsonpath.JSONPath
+	hostname   *jsonpath.JSONPath
+	template   *template.Template
+}
+
+func newHTTPInventorySource(c client.Client, namespace string, config esov1alpha1.HTTPInventorySource) (*httpInventorySource, error) {
+	s := &httpInventorySource{
+		client:     c,
+		namespace:  namespace,
+		config:     config,
+		httpClient: &http.Client{},
+	}
+
+	var err error
+	switch {
+	case config.JSONPath != nil && config.Template != "":
+		return nil, fmt.Errorf("only one of jsonPath and template may be set")
+	case config.JSONPath != nil:
+		if s.items, err = parseJSONPath("items", config.JSONPath.Items); err != nil {
+			return nil, err
+		}
+		if s.ip, err = parseJSONPath("ip", config.JSONPath.IP); err != nil {
+			return nil, err
+		}
+		if s.port, err = parseJSONPath("port", config.JSONPath.Port); err != nil {
+			return nil, err
+		}
+		if s.hostname, err = parseJSONPath("hostname", config.JSONPath.Hostname); err != nil {
+			return nil, err
+		}
+	case config.Template != "":
+		if s.template, err = template.New("inventory").Option("missingkey=zero").Parse(config.Template); err != nil {
+			return nil, fmt.Errorf("invalid template: %v", err)
+		}
+	default:
+		return nil, fmt.Errorf("one of jsonPath and template has to be set")
+	}
+	return s, nil
+}
+
+// parseJSONPath parses the expression or returns nil if it is empty
+func parseJSONPath(name string, expression string) (*jsonpath.JSONPath, error) {
+	if expression == "" {
+		return nil, nil
+	}
+
+	path := jsonpath.New(name).AllowMissingKeys(true)
+	if err := path.Parse(expression); err != nil {
+		return nil, fmt.Errorf("invalid JSONPath of %v: %v", name, err)
+	}
+	return path, nil
+}
+
+// discover fetches the inventory. Any invalid entry fails the whole discovery, so the
+// addresses are never replaced by a partial result.
+func (s *httpInventorySource) discover(ctx context.Context) ([]esov1alpha1.ExternalServiceAddress, error) {
+	document, err := s.fetch(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if s.template != nil {
+		return s.executeTemplate(document)
+	}
+	return s.evaluateJSONPath(document)
+}
+
+func (s *httpInventorySource) fetch(ctx context.Context) (interface{}, error) {
+	request, err := http.NewRequest(http.MethodGet, s.config.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.setHeaders(ctx, request); err != nil {
+		return nil, err
+	}
+
+	response, err := s.httpClient.Do(request.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("inventory returned %v", response.Status)
+	}
+
+	body, err := ioutil.ReadAll(http.MaxBytesReader(nil, response.Body, maxInventorySize))
+	if err != nil {
+		return nil, err
+	}
+
+	// JSON is valid YAML, so both are read the same way
+	data, err := yaml.YAMLToJSON(body)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse inventory: %v", err)
+	}
+	var document interface{}
+	if err := json.Unmarshal(data, &document); err != nil {
+		return nil, fmt.Errorf("could not parse inventory: %v", err)
+	}
+	return document, nil
+}
+
+func (s *httpInventorySource) setHeaders(ctx context.Context, request *http.Request) error {
+	if s.config.HeadersSecretRef == nil {
+		return nil
+	}
+
+	secret := &corev1.Secret{}
+	if err := s.client.Get(ctx, types.NamespacedName{Name: s.config.HeadersSecretRef.Name, Namespace: s.namespace}, secret); err != nil {
+		return fmt.Errorf("could not read Secret %v: %v", s.config.HeadersSecretRef.Name, err)
+	}
+	for key, value := range secret.Data {
+		request.Header.Set(key, strings.TrimSpace(string(value)))
+	}
+	return nil
+}
+
+func (s *httpInventorySource) evaluateJSONPath(document interface{}) ([]esov1alpha1.ExternalServiceAddress, error) {
+	items, err := findValues(s.items, document)
+	if err != nil {
+		return nil, err
+	}
+
+	addresses := []esov1alpha1.ExternalServiceAddress{}
+	for _, item := range items {
+		ips, err := findStrings(s.ip, item)
+		if err != nil || len(ips) != 1 {
+			return nil, fmt.Errorf("entry %v has no single IP", item)
+		}
+		address, err := parseAddress(ips[0])
+		if err != nil {
+			return nil, err
+		}
+
+		if ports, err := findStrings(s.port, item); err != nil {
+			return nil, err
+		} else if len(ports) > 0 {
+			if address.Port, err = parsePort(ports[0]); err != nil {
+				return nil, err
+			}
+		}
+		if hostnames, err := findStrings(s.hostname, item); err != nil {
+			return nil, err
+		} else if len(hostnames) > 0 {
+			address.Hostname = hostnames[0]
+		}
+
+		addresses = append(addresses, address)
+	}
+	return addresses, nil
+}
+
+// findValues returns all values the JSONPath selects
+func findValues(path *jsonpath.JSONPath, data interface{}) ([]interface{}, error) {
+	values := []interface{}{}
+	if path == nil {
+		return values, nil
+	}
+
+	results, err := path.FindResults(data)
+	if err != nil {
+		return nil, err
+	}
+	for _, result := range results {
+		for _, value := range result {
+			if value.Kind() == reflect.Interface && value.IsNil() {
+				continue
+			}
+			values = append(values, value.Interface())
+		}
+	}
+	return values, nil
+}
+
+// findStrings returns all values the JSONPath selects as strings
+func findStrings(path *jsonpath.JSONPath, data interface{}) ([]string, error) {
+	values, err := findValues(path, data)
+	if err != nil {
+		return nil, err
+	}
+
+	result := []string{}
+	for _, value := range values {
+		// JSON numbers are float64, print them without exponent
+		if number, ok := value.(float64); ok {
+			result = append(result, fmt.Sprintf("%.0f", number))
+			continue
+		}
+		result = append(result, fmt.Sprint(value))
+	}
+	return result, nil
+}
+
+// executeTemplate parses the output of the template, one address per line
+func (s *httpInventorySource) executeTemplate(document interface{}) ([]esov1alpha1.ExternalServiceAddress, error) {
+	output := &bytes.Buffer{}
+	if err := s.template.Execute(output, document); err != nil {
+		return nil, fmt.Errorf("could not execute template: %v", err)
+	}
+
+	addresses := []esov1alpha1.ExternalServiceAddress{}
+	for _, line := range strings.Split(output.String(), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) > 2 {
+			return nil, fmt.Errorf("invalid line %q of template output", line)
+		}
+
+		address, err := parseAddress(fields[0])
+		if err != nil {
+			return nil, err
+		}
+		if len(fields) == 2 {
+			address.Hostname = fields[1]
+		}
+		addresses = append(addresses, address)
+	}
+	return addresses, nil
+}
@@ -0,0 +1,106 @@
+package sources
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	esov1alpha1 "github.com/CrowdfoxGmbH/external-service-operator/pkg/apis/eso/v1alpha1"
+	"github.com/CrowdfoxGmbH/external-service-operator/pkg/testutils"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const testInventory = `
+hosts:
+- name: billing-1
+  ip: 10.0.0.1
+  port: 8080
+  env: prod
+- name: billing-2
+  ip: 10.0.0.2
+  env: prod
+- name: billing-3
+  ip: 10.0.0.3
+  env: staging
+`
+
+func startTestInventoryServer(body string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer s3cr3t" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(body))
+	}))
+}
+
+func newTestHTTPInventorySource(t *testing.T, server *httptest.Server, config esov1alpha1.HTTPInventorySource) *httpInventorySource {
+	config.URL = server.URL
+	config.HeadersSecretRef = &corev1.LocalObjectReference{Name: "cmdb"}
+
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "cmdb", Namespace: "external-services"},
+		Data:       map[string][]byte{"Authorization": []byte("Bearer s3cr3t")},
+	}
+	source, err := newHTTPInventorySource(testutils.InitFakeClient(secret), "external-services", config)
+	if err != nil {
+		t.Fatalf("new source: (%v)", err)
+	}
+	return source
+}
+
+func TestHTTPInventorySourceWithJSONPath(t *testing.T) {
+	server := startTestInventoryServer(testInventory)
+	defer server.Close()
+
+	source := newTestHTTPInventorySource(t, server, esov1alpha1.HTTPInventorySource{
+		JSONPath: &esov1alpha1.HTTPInventoryJSONPath{
+			Items:    `{.hosts[?(@.env=="prod")]}`,
+			IP:       "{.ip}",
+			Port:     "{.port}",
+			Hostname: "{.name}",
+		},
+	})
+	addresses, err := source.discover(context.TODO())
+	if err != nil {
+		t.Fatalf("discover: (%v)", err)
+	}
+
+	testutils.ExpectEqInt(int32(len(addresses)), 2, t)
+	testutils.ExpectEqStr(addresses[0].IP, "10.0.0.1", t)
+	testutils.ExpectEqInt(addresses[0].Port, 8080, t)
+	testutils.ExpectEqStr(addresses[0].Hostname, "billing-1", t)
+	testutils.ExpectEqInt(addresses[1].Port, 0, t)
+}
+
+func TestHTTPInventorySourceWithTemplate(t *testing.T) {
+	server := startTestInventoryServer(`{"hosts": [{"ip": "10.0.0.1", "port": 8080}, {"ip": "fd00::1", "port": 8081}]}`)
+	defer server.Close()
+
+	source := newTestHTTPInventorySource(t, server, esov1alpha1.HTTPInventorySource{
+		Template: `{{ range .hosts }}{{ if .ip }}[{{ .ip }}]:{{ .port }}{{ end }}
+{{ end }}`,
+	})
+	addresses, err := source.discover(context.TODO())
+	if err != nil {
+		t.Fatalf("discover: (%v)", err)
+	}
+
+	testutils.ExpectEqInt(int32(len(addresses)), 2, t)
+	testutils.ExpectEqStr(addresses[1].IP, "fd00::1", t)
+	testutils.ExpectEqInt(addresses[1].Port, 8081, t)
+}
+
+func TestHTTPInventorySourceFailsOnInvalidEntry(t *testing.T) {
+	server := startTestInventoryServer(`{"hosts": [{"ip": "10.0.0.1"}, {"ip": "not-an-ip"}]}`)
+	defer server.Close()
+
+	source := newTestHTTPInventorySource(t, server, esov1alpha1.HTTPInventorySource{
+		JSONPath: &esov1alpha1.HTTPInventoryJSONPath{Items: "{.hosts[*]}", IP: "{.ip}"},
+	})
+	if _, err := source.discover(context.TODO()); err == nil {
+		t.Errorf("Expected discovery to fail on an invalid entry")
+	}
+}
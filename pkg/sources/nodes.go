@@ -0,0 +1,68 @@
+package sources
+
+import (
+	"context"
+	"fmt"
+
+	esov1alpha1 "github.com/CrowdfoxGmbH/external-service-operator/pkg/apis/eso/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// nodesSource uses the addresses of the Ready Nodes matching a selector
+type nodesSource struct {
+	client      client.Client
+	selector    labels.Selector
+	addressType corev1.NodeAddressType
+}
+
+func newNodesSource(c client.Client, config esov1alpha1.NodesSource) (*nodesSource, error) {
+	selector := labels.Everything()
+	if config.Selector != nil {
+		var err error
+		if selector, err = metav1.LabelSelectorAsSelector(config.Selector); err != nil {
+			return nil, fmt.Errorf("invalid selector: %v", err)
+		}
+	}
+
+	addressType := config.AddressType
+	if addressType == "" {
+		addressType = corev1.NodeInternalIP
+	}
+
+	return &nodesSource{client: c, selector: selector, addressType: addressType}, nil
+}
+
+func (s *nodesSource) discover(ctx context.Context) ([]esov1alpha1.ExternalServiceAddress, error) {
+	nodes := &corev1.NodeList{}
+	if err := s.client.List(ctx, &client.ListOptions{LabelSelector: s.selector}, nodes); err != nil {
+		return nil, err
+	}
+
+	addresses := []esov1alpha1.ExternalServiceAddress{}
+	for _, node := range nodes.Items {
+		if !s.selector.Matches(labels.Set(node.Labels)) || !isNodeReady(node) {
+			continue
+		}
+
+		for _, nodeAddress := range node.Status.Addresses {
+			if nodeAddress.Type == s.addressType {
+				addresses = append(addresses, esov1alpha1.ExternalServiceAddress{IP: nodeAddress.Address, NodeName: node.Name})
+				break
+			}
+		}
+	}
+	return addresses, nil
+}
+
+func isNodeReady(node corev1.Node) bool {
+	for _, condition := range node.Status.Conditions {
+		if condition.Type == corev1.NodeReady {
+			return condition.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
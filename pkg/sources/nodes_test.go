@@ -0,0 +1,110 @@
+package sources
+
+import (
+	"context"
+	"testing"
+
+	esov1alpha1 "github.com/CrowdfoxGmbH/external-service-operator/pkg/apis/eso/v1alpha1"
+	"github.com/CrowdfoxGmbH/external-service-operator/pkg/testutils"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func getTestNode(name string, role string, ready corev1.ConditionStatus, internalIP string) *corev1.Node {
+	return &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Labels: map[string]string{"role": role}},
+		Status: corev1.NodeStatus{
+			Conditions: []corev1.NodeCondition{corev1.NodeCondition{Type: corev1.NodeReady, Status: ready}},
+			Addresses: []corev1.NodeAddress{
+				corev1.NodeAddress{Type: corev1.NodeHostName, Address: name},
+				corev1.NodeAddress{Type: corev1.NodeInternalIP, Address: internalIP},
+				corev1.NodeAddress{Type: corev1.NodeExternalIP, Address: "203.0.113." + internalIP[len(internalIP)-1:]},
+			},
+		},
+	}
+}
+
+func TestNodesSourceUsesReadyMatchingNodes(t *testing.T) {
+	client := testutils.InitFakeClient(
+		getTestNode("agent-1", "agent", corev1.ConditionTrue, "10.0.0.1"),
+		getTestNode("agent-2", "agent", corev1.ConditionFalse, "10.0.0.2"),
+		getTestNode("worker-1", "worker", corev1.ConditionTrue, "10.0.0.3"),
+	)
+
+	source, err := newNodesSource(client, esov1alpha1.NodesSource{
+		Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"role": "agent"}},
+	})
+	if err != nil {
+		t.Fatalf("new source: (%v)", err)
+	}
+	addresses, err := source.discover(context.TODO())
+	if err != nil {
+		t.Fatalf("discover: (%v)", err)
+	}
+
+	testutils.ExpectEqInt(int32(len(addresses)), 1, t)
+	testutils.ExpectEqStr(addresses[0].IP, "10.0.0.1", t)
+	testutils.ExpectEqStr(addresses[0].NodeName, "agent-1", t)
+}
+
+// listOptionsClient remembers the options of the last List
+type listOptionsClient struct {
+	client.Client
+	options *client.ListOptions
+}
+
+func (c *listOptionsClient) List(ctx context.Context, opts *client.ListOptions, list runtime.Object) error {
+	c.options = opts
+	return c.Client.List(ctx, opts, list)
+}
+
+func TestNodesSourceListsWithSelector(t *testing.T) {
+	c := &listOptionsClient{Client: testutils.InitFakeClient(getTestNode("agent-1", "agent", corev1.ConditionTrue, "10.0.0.1"))}
+
+	source, _ := newNodesSource(c, esov1alpha1.NodesSource{
+		Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"role": "agent"}},
+	})
+	if _, err := source.discover(context.TODO()); err != nil {
+		t.Fatalf("discover: (%v)", err)
+	}
+
+	testutils.ExpectEqStr(c.options.LabelSelector.String(), "role=agent", t)
+}
+
+func TestNodesSourceWithExternalIP(t *testing.T) {
+	client := testutils.InitFakeClient(getTestNode("agent-1", "agent", corev1.ConditionTrue, "10.0.0.1"))
+
+	source, _ := newNodesSource(client, esov1alpha1.NodesSource{AddressType: corev1.NodeExternalIP})
+	addresses, err := source.discover(context.TODO())
+	if err != nil {
+		t.Fatalf("discover: (%v)", err)
+	}
+
+	testutils.ExpectEqStr(addresses[0].IP, "203.0.113.1", t)
+}
+
+func TestSyncWatchedSourcesRemovesLastNotReadyNode(t *testing.T) {
+	// Given an ExternalService using the agent Nodes
+	instance := getTestSourceExternalServiceCR("")
+	instance.Spec.Sources = []esov1alpha1.AddressSource{esov1alpha1.AddressSource{Name: "agents", Nodes: &esov1alpha1.NodesSource{
+		Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"role": "agent"}},
+	}}}
+	node := getTestNode("agent-1", "agent", corev1.ConditionTrue, "10.0.0.1")
+	client := testutils.InitFakeClient(node)
+
+	testutils.ExpectTrue(SyncWatchedSources(client, client, instance), t)
+	testutils.ExpectEqInt(int32(len(instance.Status.Sources[0].Addresses)), 1, t)
+
+	// When the last Node goes NotReady
+	node.Status.Conditions[0].Status = corev1.ConditionFalse
+	if err := client.Update(context.TODO(), node); err != nil {
+		t.Fatalf("update Node: (%v)", err)
+	}
+
+	// Then its address is removed instead of kept as last known address
+	testutils.ExpectTrue(SyncWatchedSources(client, client, instance), t)
+	testutils.ExpectEqInt(int32(len(instance.Status.Sources[0].Addresses)), 0, t)
+	testutils.ExpectEqStr(instance.Status.Sources[0].Error, "", t)
+}
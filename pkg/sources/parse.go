@@ -0,0 +1,40 @@
+package sources
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	esov1alpha1 "github.com/CrowdfoxGmbH/external-service-operator/pkg/apis/eso/v1alpha1"
+)
+
+// parseAddress parses an IP with an optional port like 10.0.0.1, 10.0.0.1:8080 or [fd00::1]:8080
+func parseAddress(value string) (esov1alpha1.ExternalServiceAddress, error) {
+	value = strings.TrimSpace(value)
+	if ip := net.ParseIP(strings.Trim(value, "[]")); ip != nil {
+		return esov1alpha1.ExternalServiceAddress{IP: ip.String()}, nil
+	}
+
+	host, portValue, err := net.SplitHostPort(value)
+	if err != nil {
+		return esov1alpha1.ExternalServiceAddress{}, fmt.Errorf("invalid address %q", value)
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return esov1alpha1.ExternalServiceAddress{}, fmt.Errorf("invalid IP %q", host)
+	}
+	port, err := parsePort(portValue)
+	if err != nil {
+		return esov1alpha1.ExternalServiceAddress{}, err
+	}
+	return esov1alpha1.ExternalServiceAddress{IP: ip.String(), Port: port}, nil
+}
+
+func parsePort(value string) (int32, error) {
+	port, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil || port < 1 || port > 65535 {
+		return 0, fmt.Errorf("invalid port %q", value)
+	}
+	return int32(port), nil
+}
@@ -0,0 +1,245 @@
+package sources
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"time"
+
+	esov1alpha1 "github.com/CrowdfoxGmbH/external-service-operator/pkg/apis/eso/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// newRemoteClientset creates the client of a remote cluster from its kubeconfig
+var newRemoteClientset = func(kubeconfig []byte) (kubernetes.Interface, error) {
+	config, err := remoteRESTConfig(kubeconfig)
+	if err != nil {
+		return nil, err
+	}
+	return kubernetes.NewForConfig(config)
+}
+
+// remoteRESTConfig builds the client config of a remote cluster from a kubeconfig stored in a
+// Secret. Anyone able to write Secrets could otherwise run commands in the operator or read its
+// files, so credential plugins and file references are rejected.
+func remoteRESTConfig(kubeconfig []byte) (*rest.Config, error) {
+	config, err := clientcmd.Load(kubeconfig)
+	if err != nil {
+		return nil, fmt.Errorf("invalid kubeconfig: %v", err)
+	}
+
+	for name, cluster := range config.Clusters {
+		if cluster.CertificateAuthority != "" {
+			return nil, fmt.Errorf("invalid kubeconfig: cluster %v references a file, use certificate-authority-data", name)
+		}
+	}
+	for name, user := range config.AuthInfos {
+		switch {
+		case user.Exec != nil:
+			return nil, fmt.Errorf("invalid kubeconfig: user %v runs a command, which is not allowed", name)
+		case user.AuthProvider != nil:
+			return nil, fmt.Errorf("invalid kubeconfig: user %v uses an auth provider, which is not allowed", name)
+		case user.ClientCertificate != "" || user.ClientKey != "" || user.TokenFile != "":
+			return nil, fmt.Errorf("invalid kubeconfig: user %v references a file, use client-certificate-data, client-key-data or token", name)
+		}
+	}
+
+	restConfig, err := clientcmd.NewDefaultClientConfig(*config, &clientcmd.ConfigOverrides{}).ClientConfig()
+	if err != nil {
+		return nil, fmt.Errorf("invalid kubeconfig: %v", err)
+	}
+	return restConfig, nil
+}
+
+// remoteClusterSource watches the Endpoints or the Service of another cluster
+type remoteClusterSource struct {
+	client          client.Client
+	namespace       string
+	config          esov1alpha1.RemoteClusterSource
+	wait            time.Duration
+	kubeconfig      string
+	remote          kubernetes.Interface
+	synced          bool
+	resourceVersion string
+}
+
+func newRemoteClusterSource(c client.Client, namespace string, config esov1alpha1.RemoteClusterSource, wait time.Duration) (*remoteClusterSource, error) {
+	switch config.Mode {
+	case "", esov1alpha1.RemoteClusterModeEndpoints, esov1alpha1.RemoteClusterModeLoadBalancer:
+	default:
+		return nil, fmt.Errorf("unknown mode %q", config.Mode)
+	}
+	if config.Namespace == "" {
+		config.Namespace = namespace
+	}
+	return &remoteClusterSource{client: c, namespace: namespace, config: config, wait: wait}, nil
+}
+
+func (s *remoteClusterSource) blocking() bool {
+	return true
+}
+
+// discover waits until the remote object changed since the last discovery or the wait time
+// is over, then reads it
+func (s *remoteClusterSource) discover(ctx context.Context) ([]esov1alpha1.ExternalServiceAddress, error) {
+	remote, err := s.remoteClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if s.synced {
+		s.waitForChange(ctx, remote)
+	}
+
+	var addresses []esov1alpha1.ExternalServiceAddress
+	var meta metav1.ObjectMeta
+	if s.config.Mode == esov1alpha1.RemoteClusterModeLoadBalancer {
+		service, err := remote.CoreV1().Services(s.config.Namespace).Get(s.config.Service, metav1.GetOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("could not read remote Service %v/%v: %v", s.config.Namespace, s.config.Service, err)
+		}
+		meta = service.ObjectMeta
+		addresses, err = loadBalancerAddresses(service, s.config.PortName)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		endpoints, err := remote.CoreV1().Endpoints(s.config.Namespace).Get(s.config.Service, metav1.GetOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("could not read remote Endpoints %v/%v: %v", s.config.Namespace, s.config.Service, err)
+		}
+		meta = endpoints.ObjectMeta
+		addresses = endpointsAddresses(endpoints, s.config.PortName)
+	}
+
+	s.synced = true
+	s.resourceVersion = meta.ResourceVersion
+	return addresses, nil
+}
+
+// remoteClient returns the client of the remote cluster. The kubeconfig is read on every
+// discovery, a new client is only created when it changed.
+func (s *remoteClusterSource) remoteClient(ctx context.Context) (kubernetes.Interface, error) {
+	kubeconfig, err := readSecretKey(ctx, s.client, s.namespace, s.config.KubeconfigSecretRef)
+	if err != nil {
+		return nil, err
+	}
+	if s.remote != nil && kubeconfig == s.kubeconfig {
+		return s.remote, nil
+	}
+
+	remote, err := newRemoteClientset([]byte(kubeconfig))
+	if err != nil {
+		return nil, err
+	}
+	s.remote = remote
+	s.kubeconfig = kubeconfig
+	s.synced = false
+	return remote, nil
+}
+
+// waitForChange watches the remote object until it changes or the wait time is over. Failures
+// are not reported, the following read of the object reports them.
+func (s *remoteClusterSource) waitForChange(ctx context.Context, remote kubernetes.Interface) {
+	timeoutSeconds := int64(s.wait / time.Second)
+	options := metav1.ListOptions{
+		FieldSelector:   fields.OneTermEqualSelector("metadata.name", s.config.Service).String(),
+		ResourceVersion: s.resourceVersion,
+		TimeoutSeconds:  &timeoutSeconds,
+	}
+
+	var watcher watch.Interface
+	var err error
+	if s.config.Mode == esov1alpha1.RemoteClusterModeLoadBalancer {
+		watcher, err = remote.CoreV1().Services(s.config.Namespace).Watch(options)
+	} else {
+		watcher, err = remote.CoreV1().Endpoints(s.config.Namespace).Watch(options)
+	}
+	timer := time.NewTimer(s.wait)
+	defer timer.Stop()
+
+	// a failed watch did not see a change, so wait for the rest of the time instead of
+	// reading the object again right away
+	if err == nil {
+		defer watcher.Stop()
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+			return
+		case event, ok := <-watcher.ResultChan():
+			if ok && event.Type != watch.Error {
+				return
+			}
+		}
+	}
+
+	select {
+	case <-ctx.Done():
+	case <-timer.C:
+	}
+}
+
+// endpointsAddresses returns the ready addresses of the remote Endpoints. Not ready addresses
+// are left out, the local probe decides about the mirrored ones.
+func endpointsAddresses(endpoints *corev1.Endpoints, portName string) []esov1alpha1.ExternalServiceAddress {
+	addresses := []esov1alpha1.ExternalServiceAddress{}
+	for _, subset := range endpoints.Subsets {
+		port, found := selectPort(subset.Ports, portName)
+		if !found {
+			continue
+		}
+
+		for _, address := range subset.Addresses {
+			addresses = append(addresses, esov1alpha1.ExternalServiceAddress{IP: address.IP, Hostname: address.Hostname, Port: port})
+		}
+	}
+	return addresses
+}
+
+// loadBalancerAddresses returns the ingress IPs of the remote LoadBalancer Service
+func loadBalancerAddresses(service *corev1.Service, portName string) ([]esov1alpha1.ExternalServiceAddress, error) {
+	ports := []corev1.EndpointPort{}
+	for _, servicePort := range service.Spec.Ports {
+		ports = append(ports, corev1.EndpointPort{Name: servicePort.Name, Port: servicePort.Port})
+	}
+	port, found := selectPort(ports, portName)
+	if !found {
+		return nil, fmt.Errorf("remote Service %v has no port %v", service.Name, portName)
+	}
+
+	addresses := []esov1alpha1.ExternalServiceAddress{}
+	for _, ingress := range service.Status.LoadBalancer.Ingress {
+		if net.ParseIP(ingress.IP) == nil {
+			return nil, fmt.Errorf("LoadBalancer ingress %v of remote Service %v has no IP", ingress.Hostname, service.Name)
+		}
+		addresses = append(addresses, esov1alpha1.ExternalServiceAddress{IP: ingress.IP, Port: port})
+	}
+	return addresses, nil
+}
+
+// selectPort returns the port with the given name. Without a name the only port is used,
+// with several ports 0 is returned, so spec.port applies.
+func selectPort(ports []corev1.EndpointPort, name string) (int32, bool) {
+	if name == "" {
+		if len(ports) == 1 {
+			return ports[0].Port, true
+		}
+		return 0, true
+	}
+
+	for _, port := range ports {
+		if port.Name == name {
+			return port.Port, true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,187 @@
+package sources
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	esov1alpha1 "github.com/CrowdfoxGmbH/external-service-operator/pkg/apis/eso/v1alpha1"
+	"github.com/CrowdfoxGmbH/external-service-operator/pkg/testutils"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+// newTestRemoteClusterSource returns a source reading from a fake remote cluster with the
+// given objects. Watches of the remote cluster are served by the returned watcher.
+func newTestRemoteClusterSource(t *testing.T, config esov1alpha1.RemoteClusterSource, objects ...runtime.Object) (*remoteClusterSource, *fake.Clientset, *watch.FakeWatcher) {
+	remote := fake.NewSimpleClientset(objects...)
+	watcher := watch.NewFake()
+	remote.PrependWatchReactor("*", k8stesting.DefaultWatchReactor(watcher, nil))
+
+	newRemoteClientset = func(kubeconfig []byte) (kubernetes.Interface, error) {
+		testutils.ExpectEqStr(string(kubeconfig), "cluster-a", t)
+		return remote, nil
+	}
+
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "cluster-a", Namespace: "external-services"},
+		Data:       map[string][]byte{"kubeconfig": []byte("cluster-a")},
+	}
+	config.KubeconfigSecretRef = corev1.SecretKeySelector{LocalObjectReference: corev1.LocalObjectReference{Name: "cluster-a"}, Key: "kubeconfig"}
+	config.Service = "web"
+	config.Namespace = "shop"
+
+	source, err := newRemoteClusterSource(testutils.InitFakeClient(secret), "external-services", config, 10*time.Second)
+	if err != nil {
+		t.Fatalf("new source: (%v)", err)
+	}
+	return source, remote, watcher
+}
+
+func getTestRemoteEndpoints(ips ...string) *corev1.Endpoints {
+	addresses := []corev1.EndpointAddress{}
+	for _, ip := range ips {
+		addresses = append(addresses, corev1.EndpointAddress{IP: ip})
+	}
+	return &corev1.Endpoints{
+		ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "shop"},
+		Subsets: []corev1.EndpointSubset{
+			corev1.EndpointSubset{
+				Addresses:         addresses,
+				NotReadyAddresses: []corev1.EndpointAddress{corev1.EndpointAddress{IP: "10.8.0.99"}},
+				Ports:             []corev1.EndpointPort{corev1.EndpointPort{Name: "http", Port: 8080}, corev1.EndpointPort{Name: "metrics", Port: 9090}},
+			},
+		},
+	}
+}
+
+func TestRemoteClusterSourceFollowsEndpoints(t *testing.T) {
+	source, remote, watcher := newTestRemoteClusterSource(t, esov1alpha1.RemoteClusterSource{PortName: "http"}, getTestRemoteEndpoints("10.8.0.1"))
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	addresses, err := source.discover(ctx)
+	if err != nil {
+		t.Fatalf("discover: (%v)", err)
+	}
+	testutils.ExpectEqInt(int32(len(addresses)), 1, t)
+	testutils.ExpectEqStr(addresses[0].IP, "10.8.0.1", t)
+	testutils.ExpectEqInt(addresses[0].Port, 8080, t)
+
+	// the next discovery waits for the remote Endpoints to change
+	go func() {
+		updated := getTestRemoteEndpoints("10.8.0.1", "10.8.0.2")
+		remote.CoreV1().Endpoints("shop").Update(updated)
+		watcher.Modify(updated)
+	}()
+
+	addresses, err = source.discover(ctx)
+	if err != nil {
+		t.Fatalf("discover: (%v)", err)
+	}
+	testutils.ExpectEqInt(int32(len(addresses)), 2, t)
+}
+
+func TestRemoteClusterSourceMirrorsLoadBalancer(t *testing.T) {
+	service := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "shop"},
+		Spec:       corev1.ServiceSpec{Ports: []corev1.ServicePort{corev1.ServicePort{Name: "https", Port: 443}}},
+		Status: corev1.ServiceStatus{LoadBalancer: corev1.LoadBalancerStatus{
+			Ingress: []corev1.LoadBalancerIngress{corev1.LoadBalancerIngress{IP: "203.0.113.10"}},
+		}},
+	}
+	source, _, _ := newTestRemoteClusterSource(t, esov1alpha1.RemoteClusterSource{Mode: esov1alpha1.RemoteClusterModeLoadBalancer}, service)
+
+	addresses, err := source.discover(context.TODO())
+	if err != nil {
+		t.Fatalf("discover: (%v)", err)
+	}
+	testutils.ExpectEqInt(int32(len(addresses)), 1, t)
+	testutils.ExpectEqStr(addresses[0].IP, "203.0.113.10", t)
+	testutils.ExpectEqInt(addresses[0].Port, 443, t)
+}
+
+func TestRemoteClusterSourceFailsWithoutRemoteService(t *testing.T) {
+	source, _, _ := newTestRemoteClusterSource(t, esov1alpha1.RemoteClusterSource{})
+
+	if _, err := source.discover(context.TODO()); err == nil {
+		t.Errorf("Expected discovery to fail if the remote Endpoints do not exist")
+	}
+}
+
+const testRemoteKubeconfig = `apiVersion: v1
+kind: Config
+clusters:
+- name: cluster-a
+  cluster:
+    server: https://cluster-a.example.com
+contexts:
+- name: cluster-a
+  context:
+    cluster: cluster-a
+    user: operator
+current-context: cluster-a
+users:
+- name: operator
+  user:
+`
+
+func TestRemoteClusterSourceWaitsWhenWatchFails(t *testing.T) {
+	// Given a kubeconfig which may get the remote Endpoints but not watch them
+	source, remote, _ := newTestRemoteClusterSource(t, esov1alpha1.RemoteClusterSource{}, getTestRemoteEndpoints("10.8.0.1"))
+	remote.PrependWatchReactor("*", func(action k8stesting.Action) (bool, watch.Interface, error) {
+		return true, nil, fmt.Errorf("watch is forbidden")
+	})
+	source.wait = 200 * time.Millisecond
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if _, err := source.discover(ctx); err != nil {
+		t.Fatalf("discover: (%v)", err)
+	}
+
+	// Then the next discovery waits for the interval instead of reading them again right away
+	start := time.Now()
+	if _, err := source.discover(ctx); err != nil {
+		t.Fatalf("discover: (%v)", err)
+	}
+	if elapsed := time.Since(start); elapsed < source.wait {
+		t.Errorf("Expected discovery to wait %v after the failed watch, it took %v", source.wait, elapsed)
+	}
+}
+
+func TestRemoteRESTConfigUsesToken(t *testing.T) {
+	config, err := remoteRESTConfig([]byte(testRemoteKubeconfig + "    token: secret\n"))
+	if err != nil {
+		t.Fatalf("remote config: (%v)", err)
+	}
+
+	testutils.ExpectEqStr(config.Host, "https://cluster-a.example.com", t)
+	testutils.ExpectEqStr(config.BearerToken, "secret", t)
+}
+
+func TestRemoteRESTConfigRejectsCommandsAndFiles(t *testing.T) {
+	for _, user := range []string{
+		"    exec:\n      apiVersion: client.authentication.k8s.io/v1beta1\n      command: sh\n      args: [\"-c\", \"id\"]\n",
+		"    auth-provider:\n      name: gcp\n      config:\n        cmd-path: /bin/sh\n",
+		"    tokenFile: /var/run/secrets/kubernetes.io/serviceaccount/token\n",
+		"    client-certificate: /etc/ssl/client.crt\n    client-key: /etc/ssl/client.key\n",
+	} {
+		if _, err := remoteRESTConfig([]byte(testRemoteKubeconfig + user)); err == nil {
+			t.Errorf("Expected kubeconfig with user %q to be rejected", user)
+		}
+	}
+
+	withFile := strings.Replace(testRemoteKubeconfig, "    server:", "    certificate-authority: /etc/ssl/ca.crt\n    server:", 1)
+	if _, err := remoteRESTConfig([]byte(withFile + "    token: secret\n")); err == nil {
+		t.Errorf("Expected kubeconfig with a certificate authority file to be rejected")
+	}
+}
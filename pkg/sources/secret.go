@@ -0,0 +1,25 @@
+package sources
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// readSecretKey returns the value of the selected key. It is read on every discovery, so
+// rotated credentials are picked up without restarting the source.
+func readSecretKey(ctx context.Context, c client.Client, namespace string, selector corev1.SecretKeySelector) (string, error) {
+	secret := &corev1.Secret{}
+	if err := c.Get(ctx, types.NamespacedName{Name: selector.Name, Namespace: namespace}, secret); err != nil {
+		return "", fmt.Errorf("could not read Secret %v: %v", selector.Name, err)
+	}
+
+	value, found := secret.Data[selector.Key]
+	if !found {
+		return "", fmt.Errorf("Secret %v has no key %v", selector.Name, selector.Key)
+	}
+	return string(value), nil
+}
@@ -0,0 +1,113 @@
+package sources
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sync"
+
+	esov1alpha1 "github.com/CrowdfoxGmbH/external-service-operator/pkg/apis/eso/v1alpha1"
+
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/runtime/log"
+)
+
+// source discovers the addresses of an ExternalService
+type source interface {
+	discover(ctx context.Context) ([]esov1alpha1.ExternalServiceAddress, error)
+}
+
+// blockingSource waits for changes itself, so its worker does not wait between two successful discoveries
+type blockingSource interface {
+	source
+	blocking() bool
+}
+
+// newSource creates the source of the configured kind
+func newSource(c client.Client, namespace string, config esov1alpha1.AddressSource) (source, error) {
+	switch config.Kind() {
+	case esov1alpha1.DNSSRVSourceKind:
+		return newDNSSRVSource(*config.DNSSRV), nil
+	case esov1alpha1.ConsulSourceKind:
+		return newConsulSource(c, namespace, *config.Consul, waitTime(config)), nil
+	case esov1alpha1.HTTPInventorySourceKind:
+		return newHTTPInventorySource(c, namespace, *config.HTTPInventory)
+	case esov1alpha1.NodesSourceKind:
+		return newNodesSource(c, *config.Nodes)
+	case esov1alpha1.FileSourceKind:
+		return newFileSource(c, namespace, *config.File)
+	case esov1alpha1.RemoteClusterSourceKind:
+		return newRemoteClusterSource(c, namespace, *config.RemoteCluster, waitTime(config))
+	}
+	return nil, fmt.Errorf("no kind of source is set")
+}
+
+// SourceManager runs a worker per address source of every ExternalService. The workers write
+// the discovered addresses to the status, from where they are picked up like spec.ips.
+type SourceManager struct {
+	client  client.Client
+	lock    sync.Mutex
+	workers map[types.NamespacedName]map[string]*worker
+	logger  logr.Logger
+}
+
+func NewSourceManager(client client.Client) *SourceManager {
+	return &SourceManager{
+		client:  client,
+		workers: map[types.NamespacedName]map[string]*worker{},
+		logger:  logf.Log.WithName("Source Manager"),
+	}
+}
+
+// UpdateSources starts workers for new sources, restarts workers of changed sources and stops
+// workers of removed sources. Workers of unchanged sources keep running.
+func (m *SourceManager) UpdateSources(externalService *esov1alpha1.ExternalService) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	key := types.NamespacedName{Name: externalService.Name, Namespace: externalService.Namespace}
+	running := m.workers[key]
+	workers := map[string]*worker{}
+
+	for index, config := range externalService.Spec.Sources {
+		if IsWatched(config) {
+			continue
+		}
+
+		name := config.GetName(index)
+		if w, found := running[name]; found && reflect.DeepEqual(w.config, config) {
+			workers[name] = w
+			delete(running, name)
+			continue
+		}
+
+		m.logger.Info("Starting source", "externalservice", key.Name, "namespace", key.Namespace, "source", name)
+		w := newWorker(m.client, key, name, config)
+		go w.run()
+		workers[name] = w
+	}
+
+	for name, w := range running {
+		m.logger.Info("Stopping source", "externalservice", key.Name, "namespace", key.Namespace, "source", name)
+		w.stop()
+	}
+
+	if len(workers) == 0 {
+		delete(m.workers, key)
+		return
+	}
+	m.workers[key] = workers
+}
+
+// RemoveSources stops all workers of the ExternalService
+func (m *SourceManager) RemoveSources(key types.NamespacedName) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	for _, w := range m.workers[key] {
+		w.stop()
+	}
+	delete(m.workers, key)
+}
@@ -0,0 +1,122 @@
+package sources
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+
+	esov1alpha1 "github.com/CrowdfoxGmbH/external-service-operator/pkg/apis/eso/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// updateSourceStatus writes the result of a discovery to the status of the ExternalService
+func updateSourceStatus(c client.Client, key types.NamespacedName, name string, addresses []esov1alpha1.ExternalServiceAddress, discoveryErr error) error {
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		externalService := &esov1alpha1.ExternalService{}
+		if err := c.Get(context.TODO(), key, externalService); err != nil {
+			return err
+		}
+		if !isConfigured(externalService, name) {
+			return nil
+		}
+
+		status := externalService.Status.GetSourceStatus(name)
+		if status == nil {
+			externalService.Status.Sources = append(externalService.Status.Sources, esov1alpha1.AddressSourceStatus{Name: name})
+			status = &externalService.Status.Sources[len(externalService.Status.Sources)-1]
+		}
+
+		changed := applyDiscovery(status, addresses, false, discoveryErr)
+		changed = setSyncedCondition(&externalService.Status) || changed
+		if !changed {
+			return nil
+		}
+		return c.Status().Update(context.TODO(), externalService)
+	})
+}
+
+// applyDiscovery applies the result of a discovery to the status of its source. If the
+// discovery failed, or found nothing and allowEmpty is false, the last known addresses are
+// kept, so an outage of the source does not take down the ExternalService. Returns true if
+// anything changed.
+func applyDiscovery(status *esov1alpha1.AddressSourceStatus, addresses []esov1alpha1.ExternalServiceAddress, allowEmpty bool, discoveryErr error) bool {
+	if discoveryErr == nil && len(addresses) == 0 && !allowEmpty {
+		discoveryErr = fmt.Errorf("source returned no addresses")
+	}
+
+	if discoveryErr != nil {
+		if status.Error == discoveryErr.Error() {
+			return false
+		}
+		status.Error = discoveryErr.Error()
+		return true
+	}
+
+	changed := status.Error != ""
+	status.Error = ""
+	if !reflect.DeepEqual(status.Addresses, addresses) {
+		now := metav1.Now()
+		status.Addresses = addresses
+		status.LastUpdateTime = &now
+		changed = true
+	}
+	return changed
+}
+
+// PruneStatus removes the status of sources which are no longer configured. Returns true if
+// anything changed.
+func PruneStatus(externalService *esov1alpha1.ExternalService) bool {
+	statuses := []esov1alpha1.AddressSourceStatus{}
+	for _, status := range externalService.Status.Sources {
+		if isConfigured(externalService, status.Name) {
+			statuses = append(statuses, status)
+		}
+	}
+
+	changed := len(statuses) != len(externalService.Status.Sources)
+	if changed {
+		externalService.Status.Sources = statuses
+	}
+	return setSyncedCondition(&externalService.Status) || changed
+}
+
+func isConfigured(externalService *esov1alpha1.ExternalService, name string) bool {
+	for index, source := range externalService.Spec.Sources {
+		if source.GetName(index) == name {
+			return true
+		}
+	}
+	return false
+}
+
+// setSyncedCondition sets the SourcesSynced condition or removes it if there are no sources
+func setSyncedCondition(status *esov1alpha1.ExternalServiceStatus) bool {
+	if len(status.Sources) == 0 {
+		return status.RemoveCondition(esov1alpha1.SourcesSynced)
+	}
+
+	failed := []string{}
+	for _, source := range status.Sources {
+		if source.Error != "" {
+			failed = append(failed, source.Name)
+		}
+	}
+
+	condition := esov1alpha1.ExternalServiceCondition{
+		Type:    esov1alpha1.SourcesSynced,
+		Status:  corev1.ConditionTrue,
+		Reason:  "DiscoverySucceeded",
+		Message: "All address sources are synced",
+	}
+	if len(failed) > 0 {
+		condition.Status = corev1.ConditionFalse
+		condition.Reason = "DiscoveryFailed"
+		condition.Message = fmt.Sprintf("Discovery of %v failed, keeping the last known addresses", strings.Join(failed, ", "))
+	}
+	return status.SetCondition(condition)
+}
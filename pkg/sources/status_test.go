@@ -0,0 +1,108 @@
+package sources
+
+import (
+	"context"
+	"testing"
+
+	esov1alpha1 "github.com/CrowdfoxGmbH/external-service-operator/pkg/apis/eso/v1alpha1"
+	"github.com/CrowdfoxGmbH/external-service-operator/pkg/testutils"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func getTestSourceExternalServiceCR(server string) *esov1alpha1.ExternalService {
+	return &esov1alpha1.ExternalService{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "TestService",
+			Namespace: "external-services",
+		},
+		Spec: esov1alpha1.ExternalServiceSpec{
+			Port: 389,
+			Sources: []esov1alpha1.AddressSource{
+				esov1alpha1.AddressSource{
+					Name:   "ldap",
+					DNSSRV: &esov1alpha1.DNSSRVSource{Name: "_ldap._tcp.example.com.", Server: server},
+				},
+			},
+		},
+	}
+}
+
+func getRuntimeExternalService(c client.Client, key types.NamespacedName) *esov1alpha1.ExternalService {
+	externalService := &esov1alpha1.ExternalService{}
+	c.Get(context.TODO(), key, externalService)
+	return externalService
+}
+
+func TestWorkerKeepsLastKnownAddresses(t *testing.T) {
+	// Given a source discovering three addresses
+	server := newTestDNSServerWithLDAP(t)
+	defer server.conn.Close()
+	instance := getTestSourceExternalServiceCR(server.address())
+	client := testutils.InitFakeClient(instance)
+	key := types.NamespacedName{Name: instance.Name, Namespace: instance.Namespace}
+
+	w := newWorker(client, key, "ldap", instance.Spec.Sources[0])
+	src, _ := newSource(client, instance.Namespace, w.config)
+	w.sync(src)
+
+	externalService := getRuntimeExternalService(client, key)
+	testutils.ExpectEqInt(int32(len(externalService.GetIps())), 3, t)
+	testutils.ExpectEqStr(externalService.Status.Sources[0].Addresses[0].IP, "10.0.0.1", t)
+	testutils.ExpectEqStr(string(externalService.Status.GetCondition(esov1alpha1.SourcesSynced).Status), string(corev1.ConditionTrue), t)
+
+	// When DNS fails
+	server.setFailing(true)
+	w.sync(src)
+
+	// Then the addresses are kept and the failure is reported
+	externalService = getRuntimeExternalService(client, key)
+	testutils.ExpectEqInt(int32(len(externalService.GetIps())), 3, t)
+	if externalService.Status.Sources[0].Error == "" {
+		t.Errorf("Expected the error of the discovery in the status")
+	}
+	testutils.ExpectEqStr(string(externalService.Status.GetCondition(esov1alpha1.SourcesSynced).Status), string(corev1.ConditionFalse), t)
+
+	// And when DNS recovers, the error is cleared
+	server.setFailing(false)
+	w.sync(src)
+
+	externalService = getRuntimeExternalService(client, key)
+	testutils.ExpectEqStr(externalService.Status.Sources[0].Error, "", t)
+	testutils.ExpectEqStr(string(externalService.Status.GetCondition(esov1alpha1.SourcesSynced).Status), string(corev1.ConditionTrue), t)
+}
+
+func TestApplyDiscoveryIgnoresEmptyResult(t *testing.T) {
+	status := &esov1alpha1.AddressSourceStatus{
+		Name:      "ldap",
+		Addresses: []esov1alpha1.ExternalServiceAddress{esov1alpha1.ExternalServiceAddress{IP: "10.0.0.1"}},
+	}
+
+	testutils.ExpectTrue(applyDiscovery(status, []esov1alpha1.ExternalServiceAddress{}, false, nil), t)
+	testutils.ExpectEqInt(int32(len(status.Addresses)), 1, t)
+	testutils.ExpectEqStr(status.Error, "source returned no addresses", t)
+
+	// the same failure again changes nothing
+	testutils.ExpectFalse(applyDiscovery(status, nil, false, nil), t)
+}
+
+func TestPruneStatusRemovesDeletedSources(t *testing.T) {
+	instance := getTestSourceExternalServiceCR("")
+	instance.Status.Sources = []esov1alpha1.AddressSourceStatus{
+		esov1alpha1.AddressSourceStatus{Name: "ldap"},
+		esov1alpha1.AddressSourceStatus{Name: "removed", Error: "timeout"},
+	}
+
+	testutils.ExpectTrue(PruneStatus(instance), t)
+	testutils.ExpectEqInt(int32(len(instance.Status.Sources)), 1, t)
+	testutils.ExpectEqStr(string(instance.Status.GetCondition(esov1alpha1.SourcesSynced).Status), string(corev1.ConditionTrue), t)
+
+	// without sources the condition is removed
+	instance.Spec.Sources = nil
+	testutils.ExpectTrue(PruneStatus(instance), t)
+	if instance.Status.GetCondition(esov1alpha1.SourcesSynced) != nil {
+		t.Errorf("Expected SourcesSynced condition to be removed")
+	}
+}
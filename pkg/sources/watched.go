@@ -0,0 +1,73 @@
+package sources
+
+import (
+	"context"
+	"time"
+
+	esov1alpha1 "github.com/CrowdfoxGmbH/external-service-operator/pkg/apis/eso/v1alpha1"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// IsWatched reports whether the source discovers addresses from objects of the cluster. Such
+// sources have no worker, the controller watches their objects and discovers them on every
+// reconcile with SyncWatchedSources.
+func IsWatched(config esov1alpha1.AddressSource) bool {
+	switch config.Kind() {
+	case esov1alpha1.NodesSourceKind, esov1alpha1.FileSourceKind:
+		return true
+	}
+	return false
+}
+
+// PollInterval returns the shortest interval of the file sources of the ExternalService, or 0
+// without any. Only labeled ConfigMaps and Secrets are watched, so the others are read again
+// at the interval.
+func PollInterval(externalService *esov1alpha1.ExternalService) time.Duration {
+	interval := time.Duration(0)
+	for _, config := range externalService.Spec.Sources {
+		if config.Kind() != esov1alpha1.FileSourceKind {
+			continue
+		}
+		if wait := waitTime(config); interval == 0 || wait < interval {
+			interval = wait
+		}
+	}
+	return interval
+}
+
+// SyncWatchedSources discovers the addresses of all watched sources and applies them to the
+// status of the ExternalService. Nodes are read with the cached client, as they are watched
+// anyway, ConfigMaps and Secrets with the direct one. Returns true if the status changed.
+func SyncWatchedSources(cached client.Client, direct client.Client, externalService *esov1alpha1.ExternalService) bool {
+	changed := false
+	for index, config := range externalService.Spec.Sources {
+		if !IsWatched(config) {
+			continue
+		}
+
+		c := direct
+		if config.Kind() == esov1alpha1.NodesSourceKind {
+			c = cached
+		}
+		name := config.GetName(index)
+		src, err := newSource(c, externalService.Namespace, config)
+		var addresses []esov1alpha1.ExternalServiceAddress
+		if err == nil {
+			addresses, err = src.discover(context.TODO())
+		}
+		sortAddresses(addresses)
+
+		status := externalService.Status.GetSourceStatus(name)
+		if status == nil {
+			externalService.Status.Sources = append(externalService.Status.Sources, esov1alpha1.AddressSourceStatus{Name: name})
+			status = &externalService.Status.Sources[len(externalService.Status.Sources)-1]
+			changed = true
+		}
+		// Nodes come from the cache of the watched cluster, no Node being ready is a real result
+		allowEmpty := config.Kind() == esov1alpha1.NodesSourceKind
+		changed = applyDiscovery(status, addresses, allowEmpty, err) || changed
+	}
+
+	return setSyncedCondition(&externalService.Status) || changed
+}
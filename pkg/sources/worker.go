@@ -0,0 +1,104 @@
+package sources
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	esov1alpha1 "github.com/CrowdfoxGmbH/external-service-operator/pkg/apis/eso/v1alpha1"
+
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/runtime/log"
+)
+
+const defaultIntervalSeconds = 30
+
+// worker periodically discovers the addresses of a single source
+type worker struct {
+	client         client.Client
+	namespacedName types.NamespacedName
+	name           string
+	config         esov1alpha1.AddressSource
+	stopCh         chan struct{}
+	logger         logr.Logger
+}
+
+func newWorker(client client.Client, key types.NamespacedName, name string, config esov1alpha1.AddressSource) *worker {
+	return &worker{
+		client:         client,
+		namespacedName: key,
+		name:           name,
+		config:         config,
+		stopCh:         make(chan struct{}),
+		logger:         logf.Log.WithName("source").WithValues("externalservice", key.Name, "namespace", key.Namespace, "source", name),
+	}
+}
+
+// waitTime returns the interval between two discoveries of the source
+func waitTime(config esov1alpha1.AddressSource) time.Duration {
+	if config.IntervalSeconds <= 0 {
+		return defaultIntervalSeconds * time.Second
+	}
+	return time.Duration(config.IntervalSeconds) * time.Second
+}
+
+func (w *worker) run() {
+	ticker := time.NewTicker(waitTime(w.config))
+	defer ticker.Stop()
+
+	src, err := newSource(w.client, w.namespacedName.Namespace, w.config)
+	for {
+		synced := false
+		if err == nil {
+			synced = w.sync(src)
+		} else if err := updateSourceStatus(w.client, w.namespacedName, w.name, nil, err); err != nil {
+			w.logger.Error(err, "Could not update status of ExternalService")
+		}
+
+		if blocking, ok := src.(blockingSource); ok && synced && blocking.blocking() {
+			select {
+			case <-w.stopCh:
+				return
+			default:
+				continue
+			}
+		}
+
+		select {
+		case <-w.stopCh:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// sync discovers the addresses once and writes them to the status. Returns true if the
+// discovery succeeded.
+func (w *worker) sync(src source) bool {
+	// blocking sources wait up to the interval for changes, so give them some more time
+	ctx, cancel := context.WithTimeout(context.Background(), 2*waitTime(w.config))
+	defer cancel()
+
+	addresses, err := src.discover(ctx)
+	if err != nil {
+		w.logger.Info("Discovery failed, keeping the last known addresses", "error", err.Error())
+	}
+
+	sortAddresses(addresses)
+
+	if err := updateSourceStatus(w.client, w.namespacedName, w.name, addresses, err); err != nil {
+		w.logger.Error(err, "Could not update status of ExternalService")
+	}
+	return err == nil
+}
+
+// sortAddresses sorts the addresses by IP, as sources may return them in any order, e.g. DNS round robin
+func sortAddresses(addresses []esov1alpha1.ExternalServiceAddress) {
+	sort.Slice(addresses, func(i, j int) bool { return addresses[i].IP < addresses[j].IP })
+}
+
+func (w *worker) stop() {
+	close(w.stopCh)
+}
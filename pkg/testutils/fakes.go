@@ -12,7 +12,7 @@ func InitFakeClient(objs ...runtime.Object) client.Client {
 	dummy := esov1alpha1.ExternalService{}
 
 	s := scheme.Scheme
-	s.AddKnownTypes(esov1alpha1.SchemeGroupVersion, &dummy)
+	s.AddKnownTypes(esov1alpha1.SchemeGroupVersion, &dummy, &esov1alpha1.ExternalServiceList{})
 
 	//I hate it when somebody uses globals instead ob requiring values via arguments
 	return fake.NewFakeClient(objs...)